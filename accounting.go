@@ -0,0 +1,49 @@
+// © 2014 Steve McCoy.
+
+package table
+
+import (
+	"reflect"
+	"strings"
+)
+
+// accounting reports whether tg, the tag of f, has the accounting option,
+// and returns tg without it. It is an error for a field that is not a
+// number, or a pointer to one.
+func accounting(f reflect.StructField, tg Tag) (Tag, bool, error) {
+	if _, ok := tg.Lookup("accounting"); !ok {
+		return tg, false, nil
+	}
+	t := f.Type
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if !isNumber(t.Kind()) {
+		return tg, false, tagError(f, "accounting applies only to numbers")
+	}
+	return tg.without("accounting"), true, nil
+}
+
+// modAccounting returns a function that decodes a number in parentheses,
+// as in (1,234.56), as its negative, and others as they are, with mod.
+func modAccounting(mod func(*reflect.Value, string) error) func(*reflect.Value, string) error {
+	return func(v *reflect.Value, f string) error {
+		s := strings.TrimSpace(f)
+		if len(s) > 2 && s[0] == '(' && s[len(s)-1] == ')' {
+			f = "-" + strings.TrimSpace(s[1:len(s)-1])
+		}
+		return mod(v, f)
+	}
+}
+
+// formatAccounting returns a function that formats numbers with fm, and
+// writes the negative ones in parentheses, without their minus signs.
+func formatAccounting(fm func(reflect.Value) (string, error)) func(reflect.Value) (string, error) {
+	return func(v reflect.Value) (string, error) {
+		s, err := fm(v)
+		if err != nil || !strings.HasPrefix(s, "-") {
+			return s, err
+		}
+		return "(" + s[1:] + ")", nil
+	}
+}
@@ -0,0 +1,156 @@
+// © 2014 Steve McCoy.
+
+package table
+
+import (
+	"errors"
+	"sync"
+)
+
+// ErrClosed is returned by an AsyncEncoder's methods after it is closed.
+var ErrClosed = errors.New("table: AsyncEncoder is closed")
+
+// An AsyncEncoder encodes rows on its own goroutine, so that a producer,
+// such as a decode loop, is not stalled by a slow FieldWriter. Rows wait
+// in a queue of bounded size; Encode blocks only while the queue is full.
+// The goroutine encodes the rows in the queue in batches, flushing the
+// FieldWriter, if it can be flushed as a csv.Writer can, after each.
+//
+// The first error from encoding, writing, or flushing stops the
+// AsyncEncoder: later rows are discarded, and the error is returned by
+// the next call to Encode, Flush, or Close.
+type AsyncEncoder struct {
+	enc   Encoder
+	queue chan interface{} // rows, and the flushRequest of each Flush
+	done  chan struct{}    // closed when the goroutine returns
+
+	mu     sync.RWMutex // held for writing only by Close
+	closed bool
+
+	errMu sync.Mutex
+	err   error // the first error, if any
+}
+
+// NewAsyncEncoder returns an AsyncEncoder that encodes rows with e, and
+// queues as many as size of them before Encode blocks. Its goroutine
+// runs until it is closed.
+func NewAsyncEncoder(e Encoder, size int) *AsyncEncoder {
+	a := &AsyncEncoder{enc: e, queue: make(chan interface{}, size), done: make(chan struct{})}
+	go a.run()
+	return a
+}
+
+// Encode queues v to be encoded, as by Encoder.Encode, and returns the
+// error that stopped the AsyncEncoder, if any. v is encoded later, so
+// neither it nor what it points to may be changed after Encode returns.
+func (a *AsyncEncoder) Encode(v interface{}) error {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	if a.closed {
+		return ErrClosed
+	}
+	if err := a.Err(); err != nil {
+		return err
+	}
+	a.queue <- v
+	return nil
+}
+
+// Flush waits until every row queued so far is encoded and the
+// FieldWriter flushed, then returns the error that stopped the
+// AsyncEncoder, if any.
+func (a *AsyncEncoder) Flush() error {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	if a.closed {
+		return ErrClosed
+	}
+	return a.flush()
+}
+
+// A flushRequest is queued by Flush, and receives its result.
+type flushRequest chan error
+
+func (a *AsyncEncoder) flush() error {
+	c := make(flushRequest, 1)
+	a.queue <- c
+	return <-c
+}
+
+// Close flushes the AsyncEncoder, as by Flush, and stops its goroutine.
+// It does not close the FieldWriter.
+func (a *AsyncEncoder) Close() error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.closed {
+		return ErrClosed
+	}
+	a.closed = true
+	err := a.flush()
+	close(a.queue)
+	<-a.done
+	return err
+}
+
+// Err returns the error that stopped the AsyncEncoder, if any,
+// without waiting for the rows in its queue.
+func (a *AsyncEncoder) Err() error {
+	a.errMu.Lock()
+	defer a.errMu.Unlock()
+	return a.err
+}
+
+func (a *AsyncEncoder) fail(err error) {
+	a.errMu.Lock()
+	defer a.errMu.Unlock()
+	if a.err == nil {
+		a.err = err
+	}
+}
+
+// run encodes the rows in a's queue until it is closed. Each batch is
+// the rows queued by the time the goroutine gets to them.
+func (a *AsyncEncoder) run() {
+	defer close(a.done)
+	for v := range a.queue {
+		a.handle(v)
+	batch:
+		for {
+			select {
+			case v, ok := <-a.queue:
+				if !ok {
+					break batch
+				}
+				a.handle(v)
+			default:
+				break batch
+			}
+		}
+		a.end()
+	}
+}
+
+// handle encodes v, or answers it if it is a Flush's flushRequest.
+func (a *AsyncEncoder) handle(v interface{}) {
+	if c, ok := v.(flushRequest); ok {
+		a.end()
+		c <- a.Err()
+		return
+	}
+	if a.Err() != nil {
+		return
+	}
+	if err := a.enc.Encode(v); err != nil {
+		a.fail(err)
+	}
+}
+
+// end flushes the FieldWriter at the end of a batch.
+func (a *AsyncEncoder) end() {
+	if a.Err() != nil {
+		return
+	}
+	if err := flush(a.enc.w); err != nil {
+		a.fail(err)
+	}
+}
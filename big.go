@@ -0,0 +1,74 @@
+// © 2014 Steve McCoy.
+
+package table
+
+import (
+	"encoding"
+	"math/big"
+	"reflect"
+)
+
+var (
+	bigIntType   = reflect.TypeOf(big.Int{})
+	bigFloatType = reflect.TypeOf(big.Float{})
+	bigRatType   = reflect.TypeOf(big.Rat{})
+)
+
+// isBig reports whether t is big.Int, big.Float, or big.Rat.
+func isBig(t reflect.Type) bool {
+	return t == bigIntType || t == bigFloatType || t == bigRatType
+}
+
+// modBigFloat sets v, a big.Float, to f, with enough precision to keep
+// every digit of f, rather than the 64 bits that UnmarshalText gives.
+func modBigFloat(v *reflect.Value, f string) error {
+	prec := uint(len(f)) * 4 // over log2(10) bits a digit
+	if prec < 64 {
+		prec = 64
+	}
+	x := v.Addr().Interface().(*big.Float)
+	x.SetPrec(prec).SetMode(big.ToNearestEven)
+	_, _, err := x.Parse(f, 0)
+	return err
+}
+
+// formatBig formats v, a big.Int, big.Float, or big.Rat, which need not
+// be addressable. Rats whose decimal expansions end, such as 1/8, are
+// written as decimals, as 0.125, and others as fractions, as 1/3.
+func formatBig(v reflect.Value) (string, error) {
+	if !v.CanAddr() {
+		p := reflect.New(v.Type()).Elem()
+		p.Set(v)
+		v = p
+	}
+	if r, ok := v.Addr().Interface().(*big.Rat); ok {
+		if digits, ok := decimalDigits(r.Denom()); ok {
+			return r.FloatString(digits), nil
+		}
+		return r.RatString(), nil
+	}
+	b, err := v.Addr().Interface().(encoding.TextMarshaler).MarshalText()
+	return string(b), err
+}
+
+// decimalDigits returns the number of digits after the decimal point
+// that a fraction with denominator d needs, and whether that number
+// is finite, as it is when d's only prime factors are 2 and 5.
+func decimalDigits(d *big.Int) (int, bool) {
+	d = new(big.Int).Set(d)
+	two, five := big.NewInt(2), big.NewInt(5)
+	var m big.Int
+	twos, fives := 0, 0
+	for d.Sign() != 0 && m.Mod(d, two).Sign() == 0 {
+		d.Quo(d, two)
+		twos++
+	}
+	for d.Sign() != 0 && m.Mod(d, five).Sign() == 0 {
+		d.Quo(d, five)
+		fives++
+	}
+	if d.Cmp(big.NewInt(1)) != 0 {
+		return 0, false
+	}
+	return max(twos, fives), true
+}
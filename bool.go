@@ -0,0 +1,58 @@
+// © 2014 Steve McCoy.
+
+package table
+
+import (
+	"reflect"
+	"strings"
+)
+
+// boolTokens returns the tokens given by the bool option of tg, the tag
+// of f, as in bool=yes/no, the first of which is true and the second
+// false, and tg without that option. It reports false if tg has no bool
+// option. It is an error for a field that is not a bool, or a pointer to one.
+func boolTokens(f reflect.StructField, tg Tag) (tokens [2]string, rest Tag, ok bool, err error) {
+	s, ok := tg.Lookup("bool")
+	if !ok {
+		return tokens, tg, false, nil
+	}
+	t := f.Type
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Bool {
+		return tokens, tg, false, tagError(f, "bool applies only to bools")
+	}
+	yes, no, ok := strings.Cut(s, "/")
+	if !ok || yes == "" || no == "" || strings.EqualFold(yes, no) {
+		return tokens, tg, false, tagError(f, "bool must be a true and false token, as in bool=yes/no")
+	}
+	return [2]string{yes, no}, tg.without("bool"), true, nil
+}
+
+// modBool returns a function that decodes the cells that are keys of
+// tokens, regardless of case, as their values, and others with mod.
+func modBool(tokens map[string]bool, mod func(*reflect.Value, string) error) func(*reflect.Value, string) error {
+	lower := make(map[string]bool, len(tokens))
+	for k, b := range tokens {
+		lower[strings.ToLower(k)] = b
+	}
+	return func(v *reflect.Value, f string) error {
+		if b, ok := lower[strings.ToLower(strings.TrimSpace(f))]; ok {
+			v.SetBool(b)
+			return nil
+		}
+		return mod(v, f)
+	}
+}
+
+// formatBool returns a function that writes bools as tokens, the first
+// of which is true and the second false.
+func formatBool(tokens [2]string) func(reflect.Value) (string, error) {
+	return func(v reflect.Value) (string, error) {
+		if v.Bool() {
+			return tokens[0], nil
+		}
+		return tokens[1], nil
+	}
+}
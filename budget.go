@@ -0,0 +1,53 @@
+// © 2014 Steve McCoy.
+
+package table
+
+import (
+	"io"
+)
+
+// minRateRows is the number of rows that must be read before a Decoder's
+// MaxErrorRate is checked, short of the end of the stream, so that the
+// first few rows cannot abort an import on their own.
+const minRateRows = 100
+
+// budget is the count of rows and errors kept for MaxErrors and MaxErrorRate.
+type budget struct {
+	rows, errs int
+	exceeded   error // the BudgetError returned once the budget is exceeded
+}
+
+// limited reports whether d has an error budget.
+func (d *Decoder) limited() bool {
+	return d.MaxErrors > 0 || d.MaxErrorRate > 0
+}
+
+// spend counts the row for which Decode returned err, and returns err,
+// or a BudgetError if d's budget is now exceeded.
+func (d *Decoder) spend(err error) error {
+	if err == io.EOF {
+		if d.budget.rows > 0 && d.overRate() {
+			d.budget.exceeded = BudgetError{d.budget.rows, d.budget.errs, nil}
+			return d.budget.exceeded
+		}
+		return err
+	}
+
+	d.budget.rows++
+	if err == nil && !d.dropped {
+		return nil
+	}
+	d.budget.errs++
+	if d.MaxErrors > 0 && d.budget.errs > d.MaxErrors ||
+		d.budget.rows >= minRateRows && d.overRate() {
+		d.budget.exceeded = BudgetError{d.budget.rows, d.budget.errs, err}
+		return d.budget.exceeded
+	}
+	return err
+}
+
+// overRate reports whether the proportion of rows with errors exceeds
+// d.MaxErrorRate.
+func (d *Decoder) overRate() bool {
+	return d.MaxErrorRate > 0 && float64(d.budget.errs)/float64(d.budget.rows) > d.MaxErrorRate
+}
@@ -0,0 +1,69 @@
+// © 2014 Steve McCoy.
+
+package table
+
+import (
+	"reflect"
+	"unsafe"
+)
+
+// ByteFieldReader is implemented by readers that return the fields of
+// each row as byte slices, such as parsers of multi-gigabyte files that
+// slice their fields from a buffer instead of copying each into a string.
+// The slices need only be valid until the next call of ReadBytes, so
+// that the buffer may be reused.
+type ByteFieldReader interface {
+	ReadBytes() ([][]byte, error)
+}
+
+// ByteFields returns a FieldReader that reads the rows of r, for a
+// Decoder. The cells of each row share a single string, which is the only
+// copy made of the row, however many fields it has; converters registered
+// by RegisterByteConverter are given views of them without copying.
+func ByteFields(r ByteFieldReader) FieldReader {
+	return &byteFields{r: r}
+}
+
+// byteFields is the FieldReader returned by ByteFields.
+type byteFields struct {
+	r   ByteFieldReader
+	buf []byte
+}
+
+func (b *byteFields) Read() ([]string, error) {
+	row, err := b.r.ReadBytes()
+	if row == nil {
+		return nil, err
+	}
+	b.buf = b.buf[:0]
+	for _, f := range row {
+		b.buf = append(b.buf, f...)
+	}
+	s := string(b.buf)
+	fields := make([]string, len(row))
+	for i, f := range row {
+		fields[i], s = s[:len(f)], s[len(f):]
+	}
+	return fields, err
+}
+
+// RegisterByteConverter is like RegisterConverter, but convert is given
+// the bytes of each cell, so that it can parse them without converting
+// them to a string. The bytes are a view of the cell's text, not a copy,
+// so convert must neither change them nor keep them.
+func RegisterByteConverter[T any](convert func([]byte) (T, error)) {
+	t := reflect.TypeOf((*T)(nil)).Elem()
+	mod := func(v *reflect.Value, f string) error {
+		x, err := convert(unsafe.Slice(unsafe.StringData(f), len(f)))
+		if err != nil {
+			return err
+		}
+		if v.CanAddr() {
+			*v.Addr().Interface().(*T) = x
+			return nil
+		}
+		v.Set(reflect.ValueOf(&x).Elem())
+		return nil
+	}
+	registerConverter(t, mod)
+}
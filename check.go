@@ -0,0 +1,47 @@
+// © 2014 Steve McCoy.
+
+package table
+
+import (
+	"reflect"
+)
+
+// Check reports whether values of v's type can be decoded by a Decoder
+// configured with opts, without reading any rows. It is meant to be called
+// in tests or at startup, so that a struct with, say, a complex64 field is
+// caught before the first row of production data is.
+//
+// v may be a struct, a map[string]interface{}, or a pointer to either.
+// Every problem found is returned in an Errors, rather than just the first.
+// For maps, the Kinds given by opts are checked. If v is nil, Check
+// returns an InvalidDecodeError, as Decode does.
+func Check(v interface{}, opts ...Option) error {
+	d := NewDecoder(nil, opts...)
+
+	t := reflect.TypeOf(v)
+	if t == nil {
+		return InvalidDecodeError{nil}
+	}
+	for t != nil && t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	var errs Errors
+	switch {
+	case t == reflect.TypeOf(map[string]interface{}(nil)):
+		for name := range d.Kinds {
+			if _, _, err := d.columnKind(name); err != nil {
+				errs = append(errs, err)
+			}
+		}
+	case t != nil && t.Kind() == reflect.Struct:
+		_, errs = d.plan(t)
+	default:
+		errs = Errors{decodeError(reflect.TypeOf(v).String())}
+	}
+
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
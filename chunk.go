@@ -0,0 +1,135 @@
+// © 2014 Steve McCoy.
+
+package table
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+)
+
+// A ChunkReader reads one table that is sent in several chunks, such as
+// the pages of an HTTP API that returns a continuation token with each,
+// as a single stream, for a csv.Reader or the like to read into a Decoder.
+//
+// Each chunk may begin with the same header as the first, which is read
+// only once, and may end partway through a row, which is continued by
+// the next chunk: a chunk that does not end with a newline has its last
+// row stitched to the first of the next.
+type ChunkReader struct {
+	// Next returns the chunk after the one whose continuation token is
+	// token, or the first chunk for "", along with the token of the
+	// chunk that follows it, or "" if it is the last.
+	Next func(token string) (chunk io.ReadCloser, next string, err error)
+
+	// HeaderLines is the number of lines that begin each chunk that
+	// repeats the header, including any lines of metadata before it.
+	// Those lines are read from the first chunk, and dropped from the
+	// others that begin with the same lines. If it is 0, chunks are
+	// read as they are.
+	HeaderLines int
+
+	chunk   io.ReadCloser
+	r       *bufio.Reader
+	token   string
+	header  []byte // the lines that begin the first chunk
+	pending []byte // lines read from the current chunk but not yet returned
+	chunks  int    // chunks opened so far
+	done    bool   // the last chunk has been opened
+	err     error
+}
+
+// NewChunkReader returns a ChunkReader that reads the chunks returned by
+// next, each of which begins with a header of headerLines lines.
+func NewChunkReader(next func(token string) (io.ReadCloser, string, error), headerLines int) *ChunkReader {
+	return &ChunkReader{Next: next, HeaderLines: headerLines}
+}
+
+// Read reads from the current chunk, opening the next one when it is done.
+func (c *ChunkReader) Read(p []byte) (int, error) {
+	for c.err == nil {
+		if len(c.pending) > 0 {
+			n := copy(p, c.pending)
+			c.pending = c.pending[n:]
+			return n, nil
+		}
+		if c.chunk == nil {
+			if c.done {
+				c.err = io.EOF
+				break
+			}
+			c.err = c.open()
+			continue
+		}
+		n, err := c.r.Read(p)
+		if err == io.EOF {
+			err = c.chunk.Close()
+			c.chunk = nil
+		}
+		if n > 0 || err != nil {
+			c.err = err
+			return n, nil
+		}
+	}
+	return 0, c.err
+}
+
+// Close closes the current chunk, if any, and makes Read return io.EOF,
+// so that the rest of the chunks are not requested.
+func (c *ChunkReader) Close() error {
+	c.done = true
+	c.pending = nil
+	if c.err == nil {
+		c.err = io.EOF
+	}
+	if c.chunk == nil {
+		return nil
+	}
+	err := c.chunk.Close()
+	c.chunk = nil
+	return err
+}
+
+// Token returns the continuation token of the next chunk to be opened,
+// or "" if there is none, so that reading may be resumed later by a
+// ChunkReader whose Next begins from it.
+func (c *ChunkReader) Token() string {
+	return c.token
+}
+
+// open opens the next chunk and reads its header lines, keeping those
+// of the first chunk as the header, and dropping those of later chunks
+// that match it.
+func (c *ChunkReader) open() error {
+	chunk, next, err := c.Next(c.token)
+	if err != nil {
+		return err
+	}
+	c.chunk, c.token, c.done = chunk, next, next == ""
+	if c.r == nil {
+		c.r = bufio.NewReader(chunk)
+	} else {
+		c.r.Reset(chunk)
+	}
+	c.chunks++
+
+	var lines []byte
+	for i := 0; i < c.HeaderLines; i++ {
+		line, err := c.r.ReadBytes('\n')
+		lines = append(lines, line...)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+	}
+	switch {
+	case c.chunks == 1:
+		c.header = lines
+	case bytes.Equal(lines, c.header):
+		lines = nil
+	}
+	c.pending = lines
+	return nil
+}
@@ -0,0 +1,89 @@
+// © 2014 Steve McCoy.
+
+package table
+
+import (
+	"errors"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// modClamp returns a function that decodes numbers with mod, and sets
+// those out of the range of their type to its nearest bound, as for
+// d.Clamp, keeping an OverflowError for each to pass to d.OnClamp.
+func (d *Decoder) modClamp(mod func(*reflect.Value, string) error) func(*reflect.Value, string) error {
+	return func(v *reflect.Value, f string) error {
+		err := mod(v, f)
+		if err == nil || !clamp(v, f, err) {
+			return err
+		}
+		d.clamped = append(d.clamped, OverflowError{Value: f, Type: v.Type(), Err: err})
+		return nil
+	}
+}
+
+// clamp sets v, a number, to the bound of its type nearest to f, for
+// which decoding v failed with err, and reports whether it did, which it
+// does only if err is for a value out of range, or f is a negative number
+// and v is unsigned.
+func clamp(v *reflect.Value, f string, err error) bool {
+	s := strings.TrimSpace(f)
+	neg := strings.HasPrefix(s, "-")
+	if !errors.Is(err, strconv.ErrRange) {
+		if _, perr := strconv.ParseFloat(s, 64); perr != nil || !neg || !isUnsigned(v.Kind()) {
+			return false
+		}
+	}
+	switch k := v.Kind(); {
+	case isUnsigned(k):
+		if neg {
+			v.SetUint(0)
+		} else {
+			v.SetUint(math.MaxUint64 >> (64 - v.Type().Bits()))
+		}
+	case isInteger(k):
+		if neg {
+			v.SetInt(math.MinInt64 >> (64 - v.Type().Bits()))
+		} else {
+			v.SetInt(math.MaxInt64 >> (64 - v.Type().Bits()))
+		}
+	case k == reflect.Float32 || k == reflect.Float64:
+		max := math.MaxFloat64
+		if k == reflect.Float32 {
+			max = math.MaxFloat32
+		}
+		switch n, perr := strconv.ParseFloat(s, 64); {
+		case perr == nil && !math.IsInf(n, 0) && math.Abs(n) <= max:
+			v.SetFloat(n) // too small for the type, rather than too large
+		case neg:
+			v.SetFloat(-max)
+		default:
+			v.SetFloat(max)
+		}
+	default:
+		return false
+	}
+	return true
+}
+
+// clampedField gives the OverflowErrors for the values that d.modClamp
+// clamped in decoding the named field or column at col their positions.
+func (d *Decoder) clampedField(name string, col int) {
+	for i := range d.clamped {
+		if o := &d.clamped[i]; o.Field == "" {
+			o.Field, o.Column, o.Record, o.Line = name, col, d.records, d.line(col)
+		}
+	}
+}
+
+// reportClamped passes row, and the OverflowErrors for the values that
+// were clamped in decoding it, if any, to d.OnClamp.
+func (d *Decoder) reportClamped(row []string) {
+	clamped := d.clamped
+	d.clamped = nil
+	if len(clamped) > 0 && d.OnClamp != nil {
+		d.OnClamp(row, clamped)
+	}
+}
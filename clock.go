@@ -0,0 +1,30 @@
+// © 2014 Steve McCoy.
+
+package table
+
+import (
+	"time"
+)
+
+// A Clock tells the time and waits. A Decoder uses its Clock wherever
+// it depends on the time, so that it can be made reproducible in tests.
+type Clock interface {
+	Now() time.Time
+	Sleep(d time.Duration)
+}
+
+// SystemClock is the Clock of the system, from package time.
+var SystemClock Clock = systemClock{}
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time        { return time.Now() }
+func (systemClock) Sleep(d time.Duration) { time.Sleep(d) }
+
+// clock returns d.Clock, or SystemClock if it is nil.
+func (d *Decoder) clock() Clock {
+	if d.Clock == nil {
+		return SystemClock
+	}
+	return d.Clock
+}
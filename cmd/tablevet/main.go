@@ -0,0 +1,21 @@
+// © 2014 Steve McCoy.
+
+// Tablevet reports struct types that package table cannot decode.
+//
+// Usage:
+//
+//	tablevet [packages]
+//
+// It can also be run by go vet:
+//
+//	go vet -vettool=$(which tablevet) [packages]
+package main
+
+import (
+	"golang.org/x/tools/go/analysis/singlechecker"
+	"mccoy.space/g/table/tablevet"
+)
+
+func main() {
+	singlechecker.Main(tablevet.Analyzer)
+}
@@ -0,0 +1,76 @@
+// © 2014 Steve McCoy.
+
+package table
+
+import (
+	"errors"
+	"reflect"
+	"sync"
+	"time"
+)
+
+// A Combiner builds a single field from several adjacent columns,
+// such as a Point from latitude and longitude columns.
+type Combiner struct {
+	// Columns is the number of columns that the field is built from.
+	Columns int
+
+	// Type is the type of field the Combiner applies to.
+	Type reflect.Type
+
+	// Combine sets v from the cells of its columns.
+	Combine func(v *reflect.Value, cells []string) error
+
+	// Split is the inverse of Combine, returning the cells for v.
+	Split func(v reflect.Value) ([]string, error)
+}
+
+var (
+	combinersMu sync.RWMutex
+	combiners   = map[string]Combiner{
+		"datetime": {
+			Columns: 2,
+			Type:    timeType,
+			Combine: func(v *reflect.Value, cells []string) error {
+				t, err := time.Parse("2006-01-02 15:04:05", cells[0]+" "+cells[1])
+				if err != nil {
+					return err
+				}
+				v.Set(reflect.ValueOf(t))
+				return nil
+			},
+			Split: func(v reflect.Value) ([]string, error) {
+				t := v.Interface().(time.Time)
+				return []string{t.Format("2006-01-02"), t.Format("15:04:05")}, nil
+			},
+		},
+	}
+)
+
+// RegisterCombiner makes c available to fields whose `table` tag has the
+// option combine=name. The "datetime" Combiner, which builds a time.Time
+// from a date column (2006-01-02) and a time column (15:04:05), is
+// registered by default. RegisterCombiner is safe to call concurrently.
+func RegisterCombiner(name string, c Combiner) {
+	if c.Columns < 1 || c.Type == nil || c.Combine == nil {
+		panic(errors.New("table: RegisterCombiner of incomplete Combiner " + name))
+	}
+	combinersMu.Lock()
+	defer combinersMu.Unlock()
+	combiners[name] = c
+	forgetLayouts()
+}
+
+// combiner returns the Combiner named by the combine option of f's tag.
+func combiner(f reflect.StructField, name string) (Combiner, error) {
+	combinersMu.RLock()
+	c, ok := combiners[name]
+	combinersMu.RUnlock()
+	if !ok {
+		return Combiner{}, tagError(f, "unknown combiner "+name)
+	}
+	if c.Type != f.Type {
+		return Combiner{}, tagError(f, "combiner "+name+" applies only to "+c.Type.String())
+	}
+	return c, nil
+}
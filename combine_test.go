@@ -0,0 +1,73 @@
+// © 2014 Steve McCoy.
+
+package table
+
+import (
+	"encoding/csv"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+type point struct {
+	Lat, Lon float64
+}
+
+func TestDecodeCombined(t *testing.T) {
+	RegisterCombiner("latlon", Combiner{
+		Columns: 2,
+		Type:    reflect.TypeOf(point{}),
+		Combine: func(v *reflect.Value, cells []string) error {
+			lat, err := strconv.ParseFloat(cells[0], 64)
+			if err != nil {
+				return err
+			}
+			lon, err := strconv.ParseFloat(cells[1], 64)
+			if err != nil {
+				return err
+			}
+			v.Set(reflect.ValueOf(point{lat, lon}))
+			return nil
+		},
+	})
+
+	type X struct {
+		Name  string
+		Where point     `table:",combine=latlon"`
+		When  time.Time `table:",combine=datetime"`
+		N     int
+	}
+	lines := `
+home,43.1,-77.6,2014-03-01,10:11:12,7
+`
+	dec := NewDecoder(csv.NewReader(strings.NewReader(lines)))
+	var x X
+	if err := dec.Decode(&x); err != nil {
+		t.Fatal("Expected no error, got", err)
+	}
+	if x.Name != "home" || x.N != 7 {
+		t.Error("Unexpected Name or N:", x.Name, x.N)
+	}
+	if x.Where != (point{43.1, -77.6}) {
+		t.Error("Unexpected Where:", x.Where)
+	}
+	if !x.When.Equal(time.Date(2014, 3, 1, 10, 11, 12, 0, time.UTC)) {
+		t.Error("Unexpected When:", x.When)
+	}
+
+	dec = NewDecoder(csv.NewReader(strings.NewReader("home,43.1,-77.6,2014-03-01\n")))
+	err := dec.Decode(&x)
+	if re, ok := err.(RowError); !ok || re.StructLen != 5 || re.MissingField != "When" {
+		t.Error("Expected a RowError for When, got", err)
+	}
+
+	type Bad struct {
+		A int `table:",combine=latlon"`
+		B int `table:",combine=nope"`
+	}
+	if errs, ok := Check(Bad{}).(Errors); !ok || len(errs) != 2 {
+		t.Error("Expected 2 errors, got", errs)
+	}
+}
@@ -0,0 +1,55 @@
+// © 2014 Steve McCoy.
+
+package table
+
+import (
+	"context"
+	"reflect"
+)
+
+// ContextUnmarshaler is implemented by types that decode themselves from
+// the text of a cell with the help of request-scoped values, such as a
+// tenant's parsing rules. Decode calls UnmarshalFieldContext, with the
+// Decoder's Context, for a field whose type, or a pointer to it, is a
+// ContextUnmarshaler, in preference to any other way of decoding it.
+type ContextUnmarshaler interface {
+	UnmarshalFieldContext(ctx context.Context, s string) error
+}
+
+var contextUnmarshalerType = reflect.TypeOf((*ContextUnmarshaler)(nil)).Elem()
+
+// DecodeContext is like Decode, but for the ctx of a request: ctx is
+// returned by d.Context while the row is decoded, so that the functions
+// in d.Modify and d.ModifyFields, d.Validate, and ContextUnmarshalers
+// can use its values, and if ctx is done before a row is read,
+// DecodeContext returns ctx.Err(). As with a RowTimeout, the Read goes on,
+// and the next Decode waits for its record, rather than reading again.
+func (d *Decoder) DecodeContext(ctx context.Context, s interface{}) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	outer := d.ctx
+	d.ctx = ctx
+	defer func() { d.ctx = outer }()
+	return d.Decode(s)
+}
+
+// Context returns the context of the DecodeContext call in progress,
+// or context.Background if there is none.
+func (d *Decoder) Context() context.Context {
+	if d.ctx == nil {
+		return context.Background()
+	}
+	return d.ctx
+}
+
+// modFieldContext sets v, whose pointer type is a ContextUnmarshaler,
+// by calling its UnmarshalFieldContext with d's Context and f.
+func (d *Decoder) modFieldContext(v *reflect.Value, f string) error {
+	return v.Addr().Interface().(ContextUnmarshaler).UnmarshalFieldContext(d.Context(), f)
+}
+
+// canceled reports whether err is the error of d's Context.
+func (d *Decoder) canceled(err error) bool {
+	return d.ctx != nil && err != nil && err == d.ctx.Err()
+}
@@ -0,0 +1,74 @@
+// © 2014 Steve McCoy.
+
+package table
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"strings"
+	"testing"
+)
+
+type tenantKey struct{}
+
+// tenantPrice is a price whose decimal separator depends on the tenant.
+type tenantPrice string
+
+func (p *tenantPrice) UnmarshalFieldContext(ctx context.Context, s string) error {
+	if ctx.Value(tenantKey{}) == "de" {
+		s = strings.Replace(s, ",", ".", 1)
+	}
+	*p = tenantPrice(s)
+	return nil
+}
+
+func TestDecodeContext(t *testing.T) {
+	type X struct {
+		Name  string
+		Price tenantPrice
+	}
+	lines := "a,\"1,5\"\nb,-2\n"
+	dec := NewDecoder(csv.NewReader(strings.NewReader(lines)), WithValidate(func(ctx context.Context, v interface{}) error {
+		if ctx.Value(tenantKey{}) != "de" {
+			return errors.New("no tenant")
+		}
+		if strings.HasPrefix(string(v.(*X).Price), "-") {
+			return errors.New("negative price")
+		}
+		return nil
+	}))
+	ctx := context.WithValue(context.Background(), tenantKey{}, "de")
+	var x X
+	if err := dec.DecodeContext(ctx, &x); err != nil {
+		t.Fatal("Expected no error, got", err)
+	}
+	if x.Price != "1.5" {
+		t.Errorf("Expected the tenant's price, got %+v", x)
+	}
+	err := dec.DecodeContext(ctx, &x)
+	if ve, ok := err.(ValidationError); !ok || ve.Err.Error() != "negative price" || ve.Record != 2 {
+		t.Error("Expected a ValidationError for record 2, got", err)
+	}
+	if dec.Context() != context.Background() {
+		t.Error("Expected the background context after DecodeContext")
+	}
+
+	canceled, cancel := context.WithCancel(ctx)
+	cancel()
+	if err := dec.DecodeContext(canceled, &x); err != context.Canceled {
+		t.Error("Expected context.Canceled, got", err)
+	}
+
+	slow := make(slowReader)
+	dec = NewDecoder(slow)
+	canceled, cancel = context.WithCancel(ctx)
+	go cancel()
+	if err := dec.DecodeContext(canceled, &x); err != context.Canceled {
+		t.Error("Expected context.Canceled while waiting for a row, got", err)
+	}
+	slow <- []string{"c", "3"}
+	if err := dec.Decode(&x); err != nil || x.Name != "c" {
+		t.Errorf("Expected the row that was waited for, got %+v, %v", x, err)
+	}
+}
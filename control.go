@@ -0,0 +1,140 @@
+// © 2014 Steve McCoy.
+
+package table
+
+import (
+	"encoding/json"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// A ControlPolicy says what a Decoder does with the control characters,
+// such as newlines, tabs, and escapes, in the cells of the rows it reads.
+type ControlPolicy int
+
+const (
+	ControlAccept   ControlPolicy = iota // control characters are decoded like any others
+	ControlStrip                         // control characters are removed
+	ControlReject                        // a ControlCharError is returned
+	ControlUnescape                      // backslash escapes, as written by an Encoder with EscapeControls, are replaced by what they stand for
+)
+
+// ControlCharError is returned from Decode for a cell that has a control
+// character when the Decoder's ControlChars is ControlReject.
+type ControlCharError struct {
+	Char   rune // the first control character in the cell
+	Column int  // column of the cell
+	Record int  // number of the row among those read
+	Line   int  // line on which the row begins, if the FieldReader reports it
+}
+
+func (e ControlCharError) Error() string {
+	return position(e.Record, e.Line) + "column " + strconv.Itoa(e.Column) +
+		": control character " + strconv.QuoteRune(e.Char)
+}
+
+// MarshalJSON encodes e as an object with the members "record" and
+// "line", each if known, "column", "code", and "message".
+func (e ControlCharError) MarshalJSON() ([]byte, error) {
+	return json.Marshal(e.asJSON())
+}
+
+func (e ControlCharError) asJSON() errorJSON {
+	return errorJSON{Record: e.Record, Line: e.Line, Column: &e.Column, Code: e.Code(), Message: e.Error()}
+}
+
+// Code returns CodeControlChar.
+func (e ControlCharError) Code() Code {
+	return CodeControlChar
+}
+
+// control applies d.ControlChars to the cells of fields, the row just
+// read, in place.
+func (d *Decoder) control(fields []string) error {
+	if d.ControlChars == ControlAccept {
+		return nil
+	}
+	for i, f := range fields {
+		if d.ControlChars == ControlUnescape {
+			fields[i] = unescapeControls(f)
+			continue
+		}
+		c := strings.IndexFunc(f, unicode.IsControl)
+		if c < 0 {
+			continue
+		}
+		if d.ControlChars == ControlReject {
+			r, _ := utf8.DecodeRuneInString(f[c:])
+			return ControlCharError{r, i, d.records, d.line(i)}
+		}
+		fields[i] = strings.Map(func(r rune) rune {
+			if unicode.IsControl(r) {
+				return -1
+			}
+			return r
+		}, f)
+	}
+	return nil
+}
+
+// escapeControls returns s with its backslashes doubled, and its control
+// characters replaced by backslash escapes: \n, \r, and \t, and otherwise
+// \xHH or \uHHHH, as strconv.Quote would write them.
+func escapeControls(s string) string {
+	if strings.IndexFunc(s, func(r rune) bool { return r == '\\' || unicode.IsControl(r) }) < 0 {
+		return s
+	}
+	var b strings.Builder
+	for _, r := range s {
+		switch {
+		case r == '\\':
+			b.WriteString(`\\`)
+		case unicode.IsControl(r):
+			q := strconv.QuoteRune(r)
+			b.WriteString(q[1 : len(q)-1])
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// unescapeControls returns s with the escapes written by escapeControls
+// replaced by what they stand for. Other backslashes are kept as they are.
+func unescapeControls(s string) string {
+	if !strings.Contains(s, `\`) {
+		return s
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] != '\\' || i+1 == len(s) {
+			b.WriteByte(s[i])
+			continue
+		}
+		n := 2 // length of the escape
+		switch s[i+1] {
+		case 'x':
+			n = 4
+		case 'u':
+			n = 6
+		case '\\', 'a', 'b', 'f', 'n', 'r', 't', 'v':
+		default:
+			b.WriteByte(s[i])
+			continue
+		}
+		if i+n > len(s) {
+			b.WriteByte(s[i])
+			continue
+		}
+		r, _, _, err := strconv.UnquoteChar(s[i:i+n], 0)
+		if err != nil || r != '\\' && !unicode.IsControl(r) {
+			b.WriteByte(s[i])
+			continue
+		}
+		b.WriteRune(r)
+		i += n - 1
+	}
+	return b.String()
+}
@@ -0,0 +1,48 @@
+// © 2014 Steve McCoy.
+
+package table
+
+import (
+	"reflect"
+	"sync"
+)
+
+var (
+	convertersMu sync.RWMutex
+	converters   = map[reflect.Type]func(*reflect.Value, string) error{}
+)
+
+// RegisterConverter makes convert decode the cells of fields of type T,
+// and of pointers to T, for every Decoder, in place of what would decode
+// them otherwise, such as the function in its Modify map for T's Kind or
+// T's UnmarshalText. Only a Decoder's ModifyFields and the json tag option
+// take precedence. It replaces any converter already registered for T.
+// RegisterConverter is safe to call concurrently.
+func RegisterConverter[T any](convert func(string) (T, error)) {
+	t := reflect.TypeOf((*T)(nil)).Elem()
+	mod := func(v *reflect.Value, f string) error {
+		x, err := convert(f)
+		if err != nil {
+			return err
+		}
+		v.Set(reflect.ValueOf(&x).Elem())
+		return nil
+	}
+	registerConverter(t, mod)
+}
+
+// registerConverter makes mod the converter for t.
+func registerConverter(t reflect.Type, mod func(*reflect.Value, string) error) {
+	convertersMu.Lock()
+	defer convertersMu.Unlock()
+	converters[t] = mod
+	forgetLayouts()
+}
+
+// converter returns the function registered by RegisterConverter for t.
+func converter(t reflect.Type) (func(*reflect.Value, string) error, bool) {
+	convertersMu.RLock()
+	defer convertersMu.RUnlock()
+	m, ok := converters[t]
+	return m, ok
+}
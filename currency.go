@@ -0,0 +1,96 @@
+// © 2014 Steve McCoy.
+
+package table
+
+import (
+	"reflect"
+	"strings"
+	"unicode"
+)
+
+// currency reports whether tg, the tag of f, has the currency option, and
+// returns tg without it. It is an error for a field that is not a number,
+// or a pointer to one.
+func currency(f reflect.StructField, tg Tag) (Tag, bool, error) {
+	if _, ok := tg.Lookup("currency"); !ok {
+		return tg, false, nil
+	}
+	t := f.Type
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if !isNumber(t.Kind()) {
+		return tg, false, tagError(f, "currency applies only to numbers")
+	}
+	return tg.without("currency"), true, nil
+}
+
+// currencyField returns the index, within the struct type t, of the string
+// field named by the currency option of tg, the tag of its field f, which
+// receives the currencies of f's cells, or nil if it names none.
+func currencyField(t reflect.Type, f reflect.StructField, tg Tag) ([]int, error) {
+	name, _ := tg.Lookup("currency")
+	if name == "" {
+		return nil, nil
+	}
+	sf, ok := t.FieldByName(name)
+	if !ok || sf.PkgPath != "" || sf.Type.Kind() != reflect.String {
+		return nil, tagError(f, "currency names no string field "+name)
+	}
+	return sf.Index, nil
+}
+
+// currencySiblings returns the names of the fields of the struct type t
+// that receive the currencies of other fields, and so are not bound to
+// columns of their own.
+func currencySiblings(t reflect.Type) map[string]bool {
+	var names map[string]bool
+	for i := 0; i < t.NumField(); i++ {
+		tg, err := ParseTag(t.Field(i).Tag.Get("table"))
+		if err != nil {
+			continue
+		}
+		if name, _ := tg.Lookup("currency"); name != "" {
+			if names == nil {
+				names = map[string]bool{}
+			}
+			names[name] = true
+		}
+	}
+	return names
+}
+
+// splitCurrency splits s into a number and the currency symbol or code,
+// such as $, €, or USD, before or after it, if any, which is removed
+// along with the spaces between it and the number. A sign before the
+// currency is kept, as in -$5 or -5.
+func splitCurrency(s string) (num, code string) {
+	s = strings.TrimSpace(s)
+	sign := ""
+	if len(s) > 1 && (s[0] == '-' || s[0] == '+') && isCurrency(rune(s[1])) {
+		sign, s = s[:1], s[1:]
+	}
+	i := strings.IndexFunc(s, func(r rune) bool { return !isCurrency(r) && !unicode.IsSpace(r) })
+	if i < 0 {
+		return sign + s, ""
+	}
+	if code = strings.TrimSpace(s[:i]); code != "" {
+		return sign + s[i:], code
+	}
+	j := strings.LastIndexFunc(s, func(r rune) bool { return !isCurrency(r) && !unicode.IsSpace(r) })
+	return sign + s[:j+1], strings.TrimSpace(s[j+1:])
+}
+
+// isCurrency reports whether r may be part of a currency symbol or code.
+func isCurrency(r rune) bool {
+	return unicode.Is(unicode.Sc, r) || unicode.IsLetter(r)
+}
+
+// modCurrency returns a function that decodes a number with mod, after
+// removing its currency.
+func modCurrency(mod func(*reflect.Value, string) error) func(*reflect.Value, string) error {
+	return func(v *reflect.Value, f string) error {
+		num, _ := splitCurrency(f)
+		return mod(v, num)
+	}
+}
@@ -3,12 +3,24 @@
 package table
 
 import (
+	"bytes"
 	"fmt"
 	"io"
 	"encoding/csv"
+	"errors"
+	"math"
+	"math/big"
+	"net"
+	"net/netip"
+	"net/url"
 	"os"
+	"reflect"
+	"strconv"
 	"strings"
+	"slices"
 	"testing"
+	"unsafe"
+	"time"
 )
 
 func ExampleDecoder_Decode() {
@@ -48,12 +60,28 @@ func TestDecodeNonstruct(t *testing.T) {
 	dec := NewDecoder(csv.NewReader(strings.NewReader(lines)))
 	var x int
 	err := dec.Decode(&x)
-	if err != nil {
-		t.Error("Expected no error, got", err)
+	if err != (InvalidDecodeError{reflect.TypeOf(&x)}) {
+		t.Error("Expected an InvalidDecodeError, got", err)
 	}
 	if x != 0 {
 		t.Error("Something touched x:", x)
 	}
+
+	type Y struct {
+		A int
+		B string
+	}
+	var y Y
+	var np *Y
+	if err := dec.Decode(y); err != (InvalidDecodeError{reflect.TypeOf(y)}) {
+		t.Error("Expected an InvalidDecodeError for a non-pointer, got", err)
+	}
+	if err := dec.Decode(np); err != (InvalidDecodeError{reflect.TypeOf(np)}) {
+		t.Error("Expected an InvalidDecodeError for a nil pointer, got", err)
+	}
+	if err := dec.Decode(&y); err != nil || y.A != 1 {
+		t.Error("Expected the first row to be left for a valid destination, got", y, err)
+	}
 }
 
 func TestShortRow(t *testing.T) {
@@ -84,7 +112,10 @@ func TestShortRow(t *testing.T) {
 		if re.MissingField != "C" {
 			t.Error("Expected MissingField of C, got", re.MissingField)
 		}
-		if re.Error() != "row mismatch: row length = 2, but struct length = 3 (field C)" {
+		if re.Record != 1 || re.Line != 2 {
+			t.Error("Expected record 1 on line 2, got", re.Record, re.Line)
+		}
+		if re.Error() != "record 1 (line 2): row mismatch: row length = 2, but struct length = 3 (field C)" {
 			t.Error("Unexpected re.Error():", re.Error())
 		}
 	} else {
@@ -120,7 +151,7 @@ func TestLongRow(t *testing.T) {
 		if re.MissingField != "" {
 			t.Error("Expected empty MissingField, got", re.MissingField)
 		}
-		if re.Error() != "row mismatch: row length = 3, but struct length = 2" {
+		if re.Error() != "record 1 (line 2): row mismatch: row length = 3, but struct length = 2" {
 			t.Error("Unexpected re.Error():", re.Error())
 		}
 	} else {
@@ -131,7 +162,7 @@ func TestLongRow(t *testing.T) {
 func TestDecodeError(t *testing.T) {
 	type X struct {
 		A int
-		B complex64
+		B uintptr
 	}
 	lines := `
 1,blonde
@@ -146,11 +177,20 @@ func TestDecodeError(t *testing.T) {
 	}
 	if de, ok := err.(DecodeError); !ok {
 		t.Error("Expected a DecodeError, got", err)
-	} else if de != "complex64" {
-		t.Error("Expected the error to be on complex64, got", de)
-	} else if de.Error() != "complex64 is not decodable" {
+	} else if de.Type != "uintptr" || de.Field != "B" || de.Column != 1 || de.Value != "blonde" || de.Record != 1 || de.Line != 2 {
+		t.Error("Unexpected DecodeError:", de)
+	} else if !errors.Is(err, errors.ErrUnsupported) {
+		t.Error("Expected the error to wrap errors.ErrUnsupported, got", de.Err)
+	} else if de.Error() != "record 1 (line 2): uintptr is not decodable (field B, column 1)" {
 		t.Error("Unexpected de.Error():", de.Error())
 	}
+
+	dec = NewDecoder(csv.NewReader(strings.NewReader("a,b\n1,2\n")),
+		WithKinds(map[string]reflect.Kind{"b": reflect.Uintptr}))
+	m := map[string]interface{}{}
+	if err := dec.Decode(m); err != (DecodeError{"uintptr", "b", 1, "2", errors.ErrUnsupported, 2, 2}) {
+		t.Error("Expected a DecodeError for column b, got", err)
+	}
 }
 
 func TestVariousParses(t *testing.T) {
@@ -225,3 +265,2086 @@ func TestVariousParses(t *testing.T) {
 		}
 	}
 }
+
+func TestDecodeMap(t *testing.T) {
+	lines := `name,age,cool
+blonde,3,true
+on,4,false
+`
+	dec := NewDecoder(csv.NewReader(strings.NewReader(lines)))
+	dec.Kinds = map[string]reflect.Kind{
+		"age":  reflect.Int,
+		"cool": reflect.Bool,
+	}
+
+	var m map[string]interface{}
+	if err := dec.Decode(&m); err != nil {
+		t.Fatal("Expected no error, got", err)
+	}
+	if m["name"] != "blonde" {
+		t.Error("Expected name to be blonde, got", m["name"])
+	}
+	if m["age"] != 3 {
+		t.Error("Expected age to be 3, got", m["age"])
+	}
+	if m["cool"] != true {
+		t.Error("Expected cool to be true, got", m["cool"])
+	}
+
+	m = map[string]interface{}{}
+	if err := dec.Decode(m); err != nil {
+		t.Fatal("Expected no error, got", err)
+	}
+	if m["name"] != "on" || m["age"] != 4 || m["cool"] != false {
+		t.Error("Unexpected second row:", m)
+	}
+
+	if err := dec.Decode(m); err != io.EOF {
+		t.Error("Expected io.EOF, got", err)
+	}
+}
+
+func TestDecodeInterface(t *testing.T) {
+	type X struct {
+		A interface{}
+		B interface{}
+		C interface{}
+		D interface{}
+		E interface{}
+	}
+	lines := `
+-7,2.5,true,2014-03-01,blonde
+`
+	dec := NewDecoder(csv.NewReader(strings.NewReader(lines)))
+	var x X
+	if err := dec.Decode(&x); err != nil {
+		t.Fatal("Expected no error, got", err)
+	}
+	if x.A != int64(-7) {
+		t.Errorf("Expected A to be int64 -7, got %T %v", x.A, x.A)
+	}
+	if x.B != 2.5 {
+		t.Errorf("Expected B to be float64 2.5, got %T %v", x.B, x.B)
+	}
+	if x.C != true {
+		t.Errorf("Expected C to be true, got %T %v", x.C, x.C)
+	}
+	if d, ok := x.D.(time.Time); !ok || !d.Equal(time.Date(2014, 3, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("Expected D to be 2014-03-01, got %T %v", x.D, x.D)
+	}
+	if x.E != "blonde" {
+		t.Errorf("Expected E to be blonde, got %T %v", x.E, x.E)
+	}
+}
+
+func TestAllErrors(t *testing.T) {
+	type X struct {
+		A int
+		B float64
+		C string
+	}
+	r := csv.NewReader(strings.NewReader("1,2.5,blonde\nx,y,on\n3,4,red,extra\n5,6,green\n"))
+	r.FieldsPerRecord = -1
+	dec := NewDecoder(r, WithAllErrors())
+	xs, err := DecodeEvery[X](dec)
+	if !reflect.DeepEqual(xs, []X{{1, 2.5, "blonde"}, {5, 6, "green"}}) {
+		t.Error("Unexpected rows:", xs)
+	}
+	errs, ok := err.(Errors)
+	if !ok || len(errs) != 2 {
+		t.Fatal("Expected the errors of two rows, got", err)
+	}
+	row, ok := errs[0].(Errors)
+	if !ok || len(row) != 2 {
+		t.Fatal("Expected two errors in the second row, got", errs[0])
+	}
+	if fe, ok := row[1].(FieldError); !ok || fe.Field != "B" || fe.Value != "y" || fe.Record != 2 {
+		t.Error("Expected a FieldError for B, got", row[1])
+	}
+	if !errors.As(errs[1], new(RowError)) {
+		t.Error("Expected a RowError in the third row, got", errs[1])
+	}
+
+	type Bad struct {
+		A uintptr
+	}
+	dec = NewDecoder(csv.NewReader(strings.NewReader("1\n2\n")))
+	if bad, err := DecodeEvery[Bad](dec); len(bad) != 0 || err == nil || len(err.(Errors)) != 1 {
+		t.Error("Expected DecodeEvery to stop at a DecodeError, got", bad, err)
+	}
+
+	dec = NewDecoder(csv.NewReader(strings.NewReader("a,b\nx,1\n")), WithAllErrors(),
+		WithKinds(map[string]reflect.Kind{"a": reflect.Int, "b": reflect.Int}))
+	m := map[string]interface{}{}
+	err = dec.Decode(m)
+	if errs, ok := err.(Errors); !ok || len(errs) != 1 || m["b"] != 1 {
+		t.Error("Expected the error for a and b to be decoded, got", m, err)
+	}
+}
+
+func TestOnBadRow(t *testing.T) {
+	type X struct {
+		A int
+		B string
+	}
+	var bad [][]string
+	var errs []error
+	sink := func(row []string, err error) {
+		bad = append(bad, row)
+		errs = append(errs, err)
+	}
+	r := csv.NewReader(strings.NewReader("1,blonde\nx,on\n3\n4,red\n"))
+	r.FieldsPerRecord = -1
+	dec := NewDecoder(r, WithStrict(), WithOnBadRow(sink))
+	xs, err := DecodeAll[X](dec)
+	if err != nil || !reflect.DeepEqual(xs, []X{{1, "blonde"}, {4, "red"}}) {
+		t.Error("Expected the good rows, got", xs, err)
+	}
+	if !reflect.DeepEqual(bad, [][]string{{"x", "on"}, {"3"}}) {
+		t.Error("Unexpected bad rows:", bad)
+	}
+	if len(errs) != 2 || ErrorCode(errs[0]) != CodeParseFailure || ErrorCode(errs[1]) != CodeShortRow {
+		t.Error("Unexpected errors:", errs)
+	}
+
+	bad = nil
+	r = csv.NewReader(strings.NewReader("x,on\ny,on\n3,red\n"))
+	dec = NewDecoder(r, WithStrict(), WithOnBadRow(sink), WithMaxErrors(1))
+	var x X
+	if err := dec.Decode(&x); !errors.As(err, new(BudgetError)) || len(bad) != 1 {
+		t.Error("Expected a BudgetError after one bad row, got", bad, err)
+	}
+}
+
+func TestSkipBadLines(t *testing.T) {
+	type X struct {
+		A int
+		B string
+	}
+	lines := "1,blonde\nINFO: this is a \"log\" line\nstarting up\n2,on\n"
+	var bad [][]string
+	sink := func(row []string, err error) {
+		if !errors.As(err, new(*csv.ParseError)) {
+			t.Error("Expected a *csv.ParseError, got", err)
+		}
+		bad = append(bad, row)
+	}
+	dec := NewDecoder(csv.NewReader(strings.NewReader(lines)), WithStrict(), WithSkipBadLines(), WithOnBadRow(sink))
+	xs, err := DecodeAll[X](dec)
+	if err != nil || !reflect.DeepEqual(xs, []X{{1, "blonde"}, {2, "on"}}) {
+		t.Error("Expected the good rows, got", xs, err)
+	}
+	if !reflect.DeepEqual(bad, [][]string{nil, {"starting up"}}) {
+		t.Error("Unexpected bad lines:", bad)
+	}
+
+	dec = NewDecoder(csv.NewReader(strings.NewReader(lines)))
+	if _, err := DecodeAll[X](dec); !errors.As(err, new(*csv.ParseError)) {
+		t.Error("Expected a *csv.ParseError without SkipBadLines, got", err)
+	}
+}
+
+// slowReader returns the rows it is sent, one per Read.
+type slowReader chan []string
+
+func (r slowReader) Read() ([]string, error) {
+	row, ok := <-r
+	if !ok {
+		return nil, io.EOF
+	}
+	return row, nil
+}
+
+func TestRowTimeout(t *testing.T) {
+	type X struct {
+		A int
+	}
+	r := make(slowReader)
+	dec := NewDecoder(r, WithRowTimeout(10*time.Millisecond), WithMaxErrors(1), WithStrict())
+	var x X
+	err := dec.Decode(&x)
+	if err != (TimeoutError{10 * time.Millisecond}) || ErrorCode(err) != CodeTimeout {
+		t.Fatal("Expected a TimeoutError, got", err)
+	}
+	if err := dec.Decode(&x); err == nil {
+		t.Fatal("Expected another TimeoutError, got", err)
+	}
+	r <- []string{"1"}
+	if err := dec.Decode(&x); err != nil || x.A != 1 {
+		t.Error("Expected the row read after the timeouts, got", x, err)
+	}
+	close(r)
+	if err := dec.Decode(&x); err != io.EOF {
+		t.Error("Expected io.EOF, got", err)
+	}
+}
+
+func TestAllowLongAndShortRows(t *testing.T) {
+	type X struct {
+		A int
+		B string
+	}
+	r := csv.NewReader(strings.NewReader("1,blonde,junk\n2,on\n"))
+	r.FieldsPerRecord = -1
+	dec := NewDecoder(r, WithAllowLongRows(), WithStrict())
+	xs, err := DecodeAll[X](dec)
+	if err != nil || !reflect.DeepEqual(xs, []X{{1, "blonde"}, {2, "on"}}) {
+		t.Error("Expected the surplus cell to be ignored, got", xs, err)
+	}
+
+	r = csv.NewReader(strings.NewReader("1,blonde,junk\n2\n"))
+	r.FieldsPerRecord = -1
+	dec = NewDecoder(r, WithAllowLongRows(), WithAllowShortRows(), WithStrict())
+	xs, err = DecodeAll[X](dec)
+	if err != nil || !reflect.DeepEqual(xs, []X{{1, "blonde"}, {2, ""}}) {
+		t.Error("Expected the missing cell to be zero, got", xs, err)
+	}
+
+	r = csv.NewReader(strings.NewReader("A,B\n1,blonde,junk,more\n"))
+	r.FieldsPerRecord = -1
+	dec = NewDecoder(r, WithUseHeader(), WithAllowLongRows())
+	m := map[string]interface{}{}
+	if err := dec.Decode(m); err != nil || !reflect.DeepEqual(m, map[string]interface{}{"A": "1", "B": "blonde"}) {
+		t.Error("Expected the surplus cells to be ignored, got", m, err)
+	}
+}
+
+func TestPrime(t *testing.T) {
+	type X struct {
+		A int
+		B time.Time `table:"b,layout=2006"`
+	}
+	type Bad struct {
+		A uintptr
+	}
+	dec := NewDecoder(csv.NewReader(strings.NewReader("1,blonde\n")))
+	if err := dec.Prime(X{}, &X{}); err != nil {
+		t.Error("Expected no error, got", err)
+	}
+	err := dec.Prime(&Bad{}, 7, nil)
+	errs, ok := err.(Errors)
+	if !ok || len(errs) != 3 {
+		t.Fatal("Expected three errors, got", err)
+	}
+	if errs[1] != decodeError("int") || errs[2] != (InvalidDecodeError{nil}) {
+		t.Error("Unexpected errors for int and nil:", errs[1:])
+	}
+
+	plan, _ := cachedLayout(reflect.TypeOf(X{}), false)
+	plan[0].column = 7
+	if again, _ := cachedLayout(reflect.TypeOf(X{}), false); again[0].column != 0 {
+		t.Error("Changing a cached layout changed the cache")
+	}
+}
+
+func TestTextColumns(t *testing.T) {
+	type X struct {
+		ID    interface{} `table:",text"`
+		Zip   interface{}
+		Count interface{}
+		Name  string `table:",text"`
+	}
+	dec := NewDecoder(csv.NewReader(strings.NewReader("007,02134,3,blonde\n")), WithTextColumns("Zip"))
+	var x X
+	if err := dec.Decode(&x); err != nil {
+		t.Fatal("Expected no error, got", err)
+	}
+	if x != (X{"007", "02134", int64(3), "blonde"}) {
+		t.Errorf("Unexpected result: %#v", x)
+	}
+
+	type Bad struct {
+		N int `table:",text"`
+	}
+	if err := Check(Bad{}); err == nil || !strings.Contains(err.Error(), "text applies only to strings") {
+		t.Error("Expected a TagError for text on an int, got", err)
+	}
+
+	dec = NewDecoder(csv.NewReader(strings.NewReader("id,n\n0042,0042\n")), WithTextColumns("id"),
+		WithKinds(map[string]reflect.Kind{"id": reflect.Interface, "n": reflect.Interface}))
+	m := map[string]interface{}{}
+	if err := dec.Decode(m); err != nil {
+		t.Fatal("Expected no error, got", err)
+	}
+	if !reflect.DeepEqual(m, map[string]interface{}{"id": "0042", "n": int64(42)}) {
+		t.Errorf("Unexpected result: %#v", m)
+	}
+}
+
+type embeddedMeta struct {
+	ID   int
+	Note string
+}
+
+type embeddedData struct {
+	Value float64
+}
+
+func TestDecodeEmbedded(t *testing.T) {
+	type X struct {
+		embeddedMeta
+		embeddedData
+		Tail string
+	}
+	dec := NewDecoder(csv.NewReader(strings.NewReader("1,blonde,2.5,on\n")), WithStrict())
+	var x X
+	if err := dec.Decode(&x); err != nil {
+		t.Fatal("Expected no error, got", err)
+	}
+	if x.ID != 1 || x.Note != "blonde" || x.Value != 2.5 || x.Tail != "on" {
+		t.Errorf("Unexpected result: %+v", x)
+	}
+
+	type P struct {
+		*embeddedData
+		A int
+	}
+	var p P
+	dec = NewDecoder(csv.NewReader(strings.NewReader("1\n")), WithStrict())
+	if err := dec.Decode(&p); err != UnexportedFieldError("embeddedData") {
+		t.Error("Expected an UnexportedFieldError for a pointer to an unexported struct, got", err)
+	}
+
+	type Y struct {
+		Meta embeddedMeta `table:"-"`
+		embeddedMeta
+		E    time.Time
+		Data embeddedData `table:"-"`
+	}
+	type Z struct {
+		Head string
+		Y
+	}
+	dec = NewDecoder(csv.NewReader(strings.NewReader("a,1,blonde,2014-03-01T00:00:00Z\n")), WithStrict())
+	var z Z
+	if err := dec.Decode(&z); err != nil {
+		t.Fatal("Expected no error, got", err)
+	}
+	if z.Head != "a" || z.ID != 1 || z.Note != "blonde" || z.E.Day() != 1 {
+		t.Errorf("Unexpected result: %+v", z)
+	}
+}
+
+func TestDecodeEmbeddedPointer(t *testing.T) {
+	type Data struct {
+		Value float64
+	}
+	type X struct {
+		A int
+		*Data
+	}
+	dec := NewDecoder(csv.NewReader(strings.NewReader("1,2.5\n")), WithStrict())
+	var x X
+	if err := dec.Decode(&x); err != nil {
+		t.Fatal("Expected no error, got", err)
+	}
+	if x.A != 1 || x.Data == nil || x.Value != 2.5 {
+		t.Errorf("Unexpected result: %+v", x)
+	}
+}
+
+func TestDecodeNested(t *testing.T) {
+	type Address struct {
+		Street string
+		City   string `table:"city"`
+	}
+	type X struct {
+		Name string   `table:"name"`
+		Home Address  `table:",prefix=home_"`
+		Work *Address `table:",prefix=work_"`
+	}
+	lines := "name,work_city,work_street,home_city,home_street\nBrian,Tulsa,Main,Oslo,Ring\n"
+	dec := NewDecoder(csv.NewReader(strings.NewReader(lines)), WithUseHeader(), WithStrict())
+	var x X
+	if err := dec.Decode(&x); err != nil {
+		t.Fatal("Expected no error, got", err)
+	}
+	if x.Name != "Brian" || x.Home != (Address{"Ring", "Oslo"}) || x.Work == nil || *x.Work != (Address{"Main", "Tulsa"}) {
+		t.Errorf("Unexpected result: %+v", x)
+	}
+
+	dec = NewDecoder(csv.NewReader(strings.NewReader("Brian,Ring,Oslo,Main,Tulsa\n")))
+	x = X{}
+	if err := dec.Decode(&x); err != nil {
+		t.Fatal("Expected no error, got", err)
+	}
+	if x.Home != (Address{"Ring", "Oslo"}) || x.Work == nil || x.Work.City != "Tulsa" {
+		t.Errorf("Unexpected result without a header: %+v", x)
+	}
+
+	type Bad struct {
+		A int `table:",prefix=a_"`
+	}
+	dec = NewDecoder(csv.NewReader(strings.NewReader("1\n")))
+	var b Bad
+	if err := dec.Decode(&b); err == nil || !strings.Contains(err.Error(), "prefix applies only to structs") {
+		t.Error("Expected a TagError for a prefix on an int, got", err)
+	}
+}
+
+func TestDecodeRest(t *testing.T) {
+	type X struct {
+		Site string
+		Day  int
+		Obs  []float64 `table:",rest"`
+	}
+	r := csv.NewReader(strings.NewReader("a,1,2.5,3,4\nb,2\nc,3,x\n"))
+	r.FieldsPerRecord = -1
+	dec := NewDecoder(r, WithStrict())
+	var x X
+	if err := dec.Decode(&x); err != nil {
+		t.Fatal("Expected no error, got", err)
+	}
+	if x.Site != "a" || x.Day != 1 || !reflect.DeepEqual(x.Obs, []float64{2.5, 3, 4}) {
+		t.Errorf("Unexpected result: %+v", x)
+	}
+	if err := dec.Decode(&x); err != nil || x.Obs == nil || len(x.Obs) != 0 {
+		t.Errorf("Expected no observations, got %+v, %v", x, err)
+	}
+	err := dec.Decode(&x)
+	if fe, ok := err.(FieldError); !ok || fe.Field != "Obs" || fe.Column != 2 || fe.Value != "x" {
+		t.Error("Expected a FieldError for column 2, got", err)
+	}
+
+	lines := "t1,site,t2,day\n2.5,a,3,1\n"
+	dec = NewDecoder(csv.NewReader(strings.NewReader(lines)), WithUseHeader())
+	if err := dec.Decode(&x); err != nil {
+		t.Fatal("Expected no error, got", err)
+	}
+	if x.Site != "a" || x.Day != 1 || !reflect.DeepEqual(x.Obs, []float64{2.5, 3}) {
+		t.Errorf("Unexpected result with a header: %+v", x)
+	}
+
+	type NotSlice struct {
+		A int `table:",rest"`
+	}
+	type NotLast struct {
+		A []int `table:",rest"`
+		B int
+	}
+	for _, v := range []interface{}{&NotSlice{}, &NotLast{}} {
+		dec = NewDecoder(csv.NewReader(strings.NewReader("1\n")))
+		if err := dec.Decode(v); ErrorCode(err) != CodeInvalidTag {
+			t.Errorf("Expected a TagError for %T, got %v", v, err)
+		}
+	}
+}
+
+func TestDecodeRestMap(t *testing.T) {
+	type X struct {
+		Name  string            `table:"name"`
+		Extra map[string]string `table:",rest"`
+	}
+	lines := "color,name,size\nred,Brian,L\n"
+	dec := NewDecoder(csv.NewReader(strings.NewReader(lines)), WithUseHeader())
+	var x X
+	if err := dec.Decode(&x); err != nil {
+		t.Fatal("Expected no error, got", err)
+	}
+	if x.Name != "Brian" || !reflect.DeepEqual(x.Extra, map[string]string{"color": "red", "size": "L"}) {
+		t.Errorf("Unexpected result: %+v", x)
+	}
+
+	dec = NewDecoder(csv.NewReader(strings.NewReader("Brian,red,L\n")))
+	if err := dec.Decode(&x); err != nil {
+		t.Fatal("Expected no error, got", err)
+	}
+	if !reflect.DeepEqual(x.Extra, map[string]string{"1": "red", "2": "L"}) {
+		t.Errorf("Unexpected result without a header: %+v", x)
+	}
+
+	type Y struct {
+		Counts map[string]int `table:",rest"`
+	}
+	dec = NewDecoder(csv.NewReader(strings.NewReader("a,b\n1,x\n")), WithUseHeader(), WithStrict())
+	var y Y
+	err := dec.Decode(&y)
+	if fe, ok := err.(FieldError); !ok || fe.Column != 1 || fe.Value != "x" {
+		t.Error("Expected a FieldError for column b, got", err)
+	}
+}
+
+func TestDecodeSplit(t *testing.T) {
+	type X struct {
+		Tags  []string    `table:",split=;"`
+		Sizes []int       `table:",split=\\,"`
+		Days  []time.Time `table:",split=|,layout=2006-01-02"`
+	}
+	lines := `a;b,"1,2,3",2014-03-01|2014-03-02
+,,
+`
+	dec := NewDecoder(csv.NewReader(strings.NewReader(lines)), WithStrict())
+	var x X
+	if err := dec.Decode(&x); err != nil {
+		t.Fatal("Expected no error, got", err)
+	}
+	if !reflect.DeepEqual(x.Tags, []string{"a", "b"}) || !reflect.DeepEqual(x.Sizes, []int{1, 2, 3}) || len(x.Days) != 2 || x.Days[1].Day() != 2 {
+		t.Errorf("Unexpected result: %+v", x)
+	}
+	if err := dec.Decode(&x); err != nil || x.Tags != nil || x.Sizes != nil || x.Days != nil {
+		t.Errorf("Expected nil slices for empty cells, got %+v, %v", x, err)
+	}
+
+	type M struct {
+		Attrs  map[string]string `table:",split=;"`
+		Counts map[string]int    `table:",split=&,kv=:"`
+	}
+	dec = NewDecoder(csv.NewReader(strings.NewReader("a=1;b=x=y,n:2&m:3\n,a\n")), WithStrict())
+	var m M
+	if err := dec.Decode(&m); err != nil {
+		t.Fatal("Expected no error, got", err)
+	}
+	if !reflect.DeepEqual(m.Attrs, map[string]string{"a": "1", "b": "x=y"}) || !reflect.DeepEqual(m.Counts, map[string]int{"n": 2, "m": 3}) {
+		t.Errorf("Unexpected result: %+v", m)
+	}
+	if err := dec.Decode(&m); ErrorCode(err) != CodeParseFailure {
+		t.Error("Expected a FieldError for a pair with no separator, got", err)
+	}
+
+	type Bad struct {
+		A int `table:",split=;"`
+	}
+	dec = NewDecoder(csv.NewReader(strings.NewReader("1\n")))
+	if err := dec.Decode(&Bad{}); ErrorCode(err) != CodeInvalidTag {
+		t.Error("Expected a TagError for split on an int, got", err)
+	}
+}
+
+// protoUser is shaped like a message generated by protoc-gen-go.
+type protoUser struct {
+	state         struct{}
+	sizeCache     int32
+	unknownFields []byte
+
+	UserId  int64          `protobuf:"varint,1,opt,name=user_id,json=userId,proto3" json:"user_id,omitempty"`
+	Name    *string        `protobuf:"bytes,2,opt,name=name" json:"name,omitempty"`
+	Manager *protoUser     `protobuf:"bytes,3,opt,name=manager,proto3" json:"manager,omitempty"`
+	Contact isUser_Contact `protobuf_oneof:"contact"`
+
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (*protoUser) ProtoMessage() {}
+
+type isUser_Contact interface{ isUser_Contact() }
+
+func TestDecodeProto(t *testing.T) {
+	lines := "name,user_id\nBrian,7\n"
+	dec := NewDecoder(csv.NewReader(strings.NewReader(lines)), WithUseHeader(), WithStrict())
+	var u protoUser
+	if err := dec.Decode(&u); err != nil {
+		t.Fatal("Expected no error, got", err)
+	}
+	if u.UserId != 7 || u.Name == nil || *u.Name != "Brian" {
+		t.Errorf("Unexpected result: %+v", u)
+	}
+
+	dec = NewDecoder(csv.NewReader(strings.NewReader("8,Stewie\n")), WithStrict())
+	u = protoUser{}
+	if err := dec.Decode(&u); err != nil {
+		t.Fatal("Expected no error, got", err)
+	}
+	if u.UserId != 8 || u.Name == nil || *u.Name != "Stewie" {
+		t.Errorf("Unexpected result without a header: %+v", u)
+	}
+}
+
+func TestDecodeJSON(t *testing.T) {
+	type Payload struct {
+		User string `json:"user"`
+		N    int    `json:"n"`
+	}
+	type X struct {
+		Level   string
+		Payload Payload        `table:"payload,json"`
+		Tags    []string       `table:",json"`
+		Meta    map[string]any `table:",json"`
+		Extra   *Payload       `table:",json"`
+	}
+	lines := `info,"{""user"":""brian"",""n"":2}","[""a"",""b""]","{""k"":1}",
+warn,{,,,
+`
+	dec := NewDecoder(csv.NewReader(strings.NewReader(lines)), WithStrict())
+	var x X
+	if err := dec.Decode(&x); err != nil {
+		t.Fatal("Expected no error, got", err)
+	}
+	if x.Payload != (Payload{"brian", 2}) || !reflect.DeepEqual(x.Tags, []string{"a", "b"}) ||
+		!reflect.DeepEqual(x.Meta, map[string]any{"k": 1.0}) || x.Extra != nil {
+		t.Errorf("Unexpected result: %+v", x)
+	}
+	err := dec.Decode(&x)
+	if fe, ok := err.(FieldError); !ok || fe.Field != "Payload" {
+		t.Error("Expected a FieldError for malformed JSON, got", err)
+	}
+}
+
+func TestDuplicateRows(t *testing.T) {
+	type X struct {
+		A int
+		B string
+	}
+	lines := "1,a\n2,b\n1,a\n1,a\n3,c\n"
+	tests := []struct {
+		policy DuplicateRowPolicy
+		rows   int
+		seen   int
+	}{
+		{DuplicateRowDecode, 5, 0},
+		{DuplicateRowCount, 5, 2},
+		{DuplicateRowSkip, 3, 2},
+	}
+	for _, test := range tests {
+		dec := NewDecoder(csv.NewReader(strings.NewReader(lines)), WithDuplicateRows(test.policy))
+		xs, err := DecodeAll[X](dec)
+		if err != nil {
+			t.Fatal("Expected no error, got", err)
+		}
+		if len(xs) != test.rows || dec.DuplicateRowsSeen() != test.seen {
+			t.Errorf("Policy %d: expected %d rows and %d duplicates, got %d and %d", test.policy, test.rows, test.seen, len(xs), dec.DuplicateRowsSeen())
+		}
+	}
+
+	dec := NewDecoder(csv.NewReader(strings.NewReader(lines)), WithDuplicateRows(DuplicateRowReject))
+	xs, err := DecodeAll[X](dec)
+	if err != (DuplicateRowError{1, 3, 3}) || len(xs) != 2 {
+		t.Error("Expected a DuplicateRowError for record 3, got", err)
+	}
+}
+
+func TestDecodeNonemptyInterface(t *testing.T) {
+	type X struct {
+		A fmt.Stringer
+	}
+	dec := NewDecoder(csv.NewReader(strings.NewReader("blonde\n")))
+	var x X
+	err := dec.Decode(&x)
+	if de, ok := err.(DecodeError); !ok || de.Type != "fmt.Stringer" || de.Field != "A" {
+		t.Error("Expected a DecodeError for fmt.Stringer, got", err)
+	}
+}
+
+func TestDecodeRaw(t *testing.T) {
+	type X struct {
+		First Raw
+		A     int
+		ARaw  Raw
+		B     string
+		BRaw  Raw
+	}
+	lines := `
+ x ,007,on
+`
+	dec := NewDecoder(csv.NewReader(strings.NewReader(lines)))
+	dec.Modify = map[reflect.Kind]func(*reflect.Value, string) error{
+		reflect.Int: defaultMods[reflect.Int],
+		reflect.String: func(v *reflect.Value, f string) error {
+			v.SetString(strings.ToUpper(f))
+			return nil
+		},
+	}
+	var x X
+	if err := dec.Decode(&x); err != nil {
+		t.Fatal("Expected no error, got", err)
+	}
+	if x.First != " x " {
+		t.Errorf("Expected First to be \" x \", got %q", x.First)
+	}
+	if x.A != 7 || x.ARaw != "007" {
+		t.Error("Expected A to be 7 and ARaw 007, got", x.A, x.ARaw)
+	}
+	if x.B != "ON" || x.BRaw != "on" {
+		t.Error("Expected B to be ON and BRaw on, got", x.B, x.BRaw)
+	}
+}
+
+func TestCheck(t *testing.T) {
+	type Good struct {
+		A int
+		B string
+		c uintptr
+	}
+	if err := Check(Good{}); err != nil {
+		t.Error("Expected no error, got", err)
+	}
+
+	type Bad struct {
+		A uintptr
+		B int
+		C fmt.Stringer
+	}
+	err := Check(&Bad{})
+	errs, ok := err.(Errors)
+	if !ok {
+		t.Fatal("Expected Errors, got", err)
+	}
+	if len(errs) != 2 || errs[0] != (DecodeError{"uintptr", "A", 0, "", errors.ErrUnsupported, 0, 0}) ||
+		errs[1] != (DecodeError{"fmt.Stringer", "C", 2, "", errors.ErrUnsupported, 0, 0}) {
+		t.Error("Expected errors for uintptr and fmt.Stringer, got", errs)
+	}
+
+	mod := func(v *reflect.Value, f string) error {
+		c, err := strconv.ParseComplex(f, 64)
+		v.SetComplex(c)
+		return err
+	}
+	if err := Check(&Bad{}, WithModify(reflect.Uintptr, mod)); err == nil || err.Error() != "fmt.Stringer is not decodable (field C, column 2)" {
+		t.Error("Expected only the fmt.Stringer error, got", err)
+	}
+	if _, ok := defaultMods[reflect.Uintptr]; ok {
+		t.Error("WithModify changed the default Modify map")
+	}
+
+	var m map[string]interface{}
+	if err := Check(&m, WithKinds(map[string]reflect.Kind{"a": reflect.Int, "b": reflect.Chan})); err == nil || err.Error() != "chan is not decodable (field b)" {
+		t.Error("Expected an error for chan, got", err)
+	}
+
+	if err := Check(7); err == nil {
+		t.Error("Expected an error for int")
+	}
+	if err := Check(nil); err != (InvalidDecodeError{nil}) {
+		t.Error("Expected an InvalidDecodeError for nil, got", err)
+	}
+}
+
+func TestRateLimit(t *testing.T) {
+	type X struct {
+		A int
+	}
+	lines := `
+1
+2
+3
+4
+5
+`
+	dec := NewDecoder(csv.NewReader(strings.NewReader(lines)), WithRateLimit(100))
+	start := time.Now()
+	n := 0
+	for {
+		var x X
+		if err := dec.Decode(&x); err == io.EOF {
+			break
+		} else if err != nil {
+			t.Fatal("Expected no error, got", err)
+		}
+		n++
+	}
+	if n != 5 {
+		t.Error("Expected 5 rows, got", n)
+	}
+	if d := time.Since(start); d < 40*time.Millisecond {
+		t.Error("Expected 5 rows at 100/s to take at least 40ms, took", d)
+	}
+
+	c := &fakeClock{now: time.Date(2014, 1, 1, 0, 0, 0, 0, time.UTC)}
+	dec = NewDecoder(csv.NewReader(strings.NewReader(lines)), WithRateLimit(2), WithClock(c))
+	for i := 0; i < 5; i++ {
+		var x X
+		if err := dec.Decode(&x); err != nil {
+			t.Fatal("Expected no error, got", err)
+		}
+	}
+	if c.slept != 2*time.Second {
+		t.Error("Expected 5 rows at 2/s to sleep for 2s, slept", c.slept)
+	}
+}
+
+// A fakeClock is a Clock that sleeps by advancing its time.
+type fakeClock struct {
+	now   time.Time
+	slept time.Duration
+}
+
+func (c *fakeClock) Now() time.Time {
+	return c.now
+}
+
+func (c *fakeClock) Sleep(d time.Duration) {
+	c.slept += d
+	c.now = c.now.Add(d)
+}
+
+func TestStrict(t *testing.T) {
+	type X struct {
+		A int8
+		b string
+		C string
+	}
+	lines := `
+300,on
+`
+	dec := NewDecoder(csv.NewReader(strings.NewReader(lines)))
+	var x X
+	if err := dec.Decode(&x); err != nil {
+		t.Error("Expected no error without Strict, got", err)
+	}
+
+	dec = NewDecoder(csv.NewReader(strings.NewReader(lines)), WithStrict())
+	err := dec.Decode(&x)
+	if err != UnexportedFieldError("b") {
+		t.Error("Expected an UnexportedFieldError for b, got", err)
+	}
+
+	type Y struct {
+		A int8
+		B string
+	}
+	var y Y
+	dec = NewDecoder(csv.NewReader(strings.NewReader("3x0,blonde\n300,blonde\n")), WithStrict())
+	err = dec.Decode(&y)
+	fe, ok := err.(FieldError)
+	if !ok {
+		t.Fatal("Expected a FieldError, got", err)
+	}
+	if fe.Field != "A" || fe.Column != 0 || fe.Value != "3x0" || fe.Record != 1 || fe.Line != 1 || !errors.Is(err, strconv.ErrSyntax) {
+		t.Error("Unexpected FieldError:", fe)
+	}
+	if fe.Error() != `record 1 (line 1): cannot decode "3x0" into field A (column 0): strconv.ParseInt: parsing "3x0": invalid syntax` {
+		t.Error("Unexpected fe.Error():", fe.Error())
+	}
+
+	y.A = 1
+	err = dec.Decode(&y)
+	oe, ok := err.(OverflowError)
+	if !ok {
+		t.Fatal("Expected an OverflowError, got", err)
+	}
+	if oe.Field != "A" || oe.Column != 0 || oe.Value != "300" || oe.Type != reflect.TypeOf(int8(0)) || oe.Record != 2 || oe.Line != 2 || !errors.Is(err, strconv.ErrRange) {
+		t.Error("Unexpected OverflowError:", oe)
+	}
+	if oe.Error() != `record 2 (line 2): "300" overflows field A of type int8 (column 0)` {
+		t.Error("Unexpected oe.Error():", oe.Error())
+	}
+	if y.A != 0 {
+		t.Error("Expected A to be left zero, got", y.A)
+	}
+
+	var n int
+	dec = NewDecoder(csv.NewReader(strings.NewReader("1,on\n")), WithStrict())
+	if err := dec.Decode(&n); err != (InvalidDecodeError{reflect.TypeOf(&n)}) {
+		t.Error("Expected an InvalidDecodeError, got", err)
+	}
+	if err := dec.Decode(nil); err != (InvalidDecodeError{}) {
+		t.Error("Expected an InvalidDecodeError for nil, got", err)
+	}
+	if err := dec.Decode(&y); err != nil || y.A != 1 {
+		t.Error("Expected the row to be left for a valid destination, got", y, err)
+	}
+}
+
+func TestTransform(t *testing.T) {
+	type X struct {
+		A    int
+		ARaw Raw
+		B    string
+		C    string
+	}
+	lines := `
+ 7 , 'blonde' ,"'on'"
+`
+	dec := NewDecoder(csv.NewReader(strings.NewReader(lines)),
+		WithTransform("A", TrimSpace),
+		WithTransform("B", TrimSpace, StripQuotes),
+		WithTransform("B", ToUpper),
+		WithTransform("C", StripQuotes))
+	var x X
+	if err := dec.Decode(&x); err != nil {
+		t.Fatal("Expected no error, got", err)
+	}
+	if x.A != 7 || x.ARaw != " 7 " {
+		t.Errorf("Expected A to be 7 and ARaw \" 7 \", got %d and %q", x.A, x.ARaw)
+	}
+	if x.B != "BLONDE" {
+		t.Error("Expected B to be BLONDE, got", x.B)
+	}
+	if x.C != "on" {
+		t.Error("Expected C to be on, got", x.C)
+	}
+
+	lines = `name,age
+ blonde ,3
+`
+	dec = NewDecoder(csv.NewReader(strings.NewReader(lines)), WithTransform("name", TrimSpace))
+	var m map[string]interface{}
+	if err := dec.Decode(&m); err != nil {
+		t.Fatal("Expected no error, got", err)
+	}
+	if m["name"] != "blonde" {
+		t.Errorf("Expected name to be blonde, got %q", m["name"])
+	}
+}
+
+func TestSkipTag(t *testing.T) {
+	type X struct {
+		A int
+		B chan int `table:"-"`
+		C string
+		D int `table:"-,"`
+	}
+	lines := `
+1,blonde,7
+`
+	dec := NewDecoder(csv.NewReader(strings.NewReader(lines)), WithStrict())
+	var x X
+	if err := dec.Decode(&x); err != nil {
+		t.Fatal("Expected no error, got", err)
+	}
+	if x.A != 1 || x.B != nil || x.C != "blonde" || x.D != 7 {
+		t.Error("Unexpected x:", x)
+	}
+}
+
+func TestIndexTag(t *testing.T) {
+	type X struct {
+		A string `table:"2"`
+		B int
+		C string `table:"index=0"`
+		D string `table:",index=5"`
+	}
+	dec := NewDecoder(csv.NewReader(strings.NewReader("a,b,c,4,e,f,g\n")))
+	var x X
+	if err := dec.Decode(&x); err != nil {
+		t.Fatal("Expected no error, got", err)
+	}
+	if x.A != "c" || x.B != 4 || x.C != "a" || x.D != "f" {
+		t.Error("Unexpected result:", x)
+	}
+
+	dec = NewDecoder(csv.NewReader(strings.NewReader("a,b,c\n")))
+	if err := dec.Decode(&x); err == nil {
+		t.Error("Expected an error for a short row, got nil")
+	}
+
+	type Dup struct {
+		A string
+		B string `table:"0"`
+	}
+	errs, ok := Check(Dup{}).(Errors)
+	if !ok || len(errs) != 1 {
+		t.Fatal("Expected one error, got", errs)
+	}
+	if _, ok := errs[0].(TagError); !ok {
+		t.Error("Expected a TagError, got", errs[0])
+	}
+
+	type Bad struct {
+		A string `table:",index=x"`
+	}
+	if err := Check(Bad{}); err == nil {
+		t.Error("Expected an error for a bad index, got nil")
+	}
+
+	type H struct {
+		A string `table:"2"`
+		B string `table:"index=0"`
+	}
+	dec = NewDecoder(csv.NewReader(strings.NewReader("x,2,y\nb,a,c\n")), WithUseHeader())
+	var h H
+	if err := dec.Decode(&h); err != nil {
+		t.Fatal("Expected no error, got", err)
+	}
+	if h.A != "a" || h.B != "b" {
+		t.Error("Unexpected result:", h)
+	}
+
+}
+
+func TestDecodeAll(t *testing.T) {
+	type X struct {
+		A int
+		B string
+	}
+	dec := NewDecoder(csv.NewReader(strings.NewReader("1,blonde\n2,on\n")))
+	xs, err := DecodeAll[X](dec)
+	if err != nil {
+		t.Fatal("Expected no error, got", err)
+	}
+	if !reflect.DeepEqual(xs, []X{{1, "blonde"}, {2, "on"}}) {
+		t.Error("Unexpected result:", xs)
+	}
+
+	r := csv.NewReader(strings.NewReader("1,blonde\n2\n3,on\n"))
+	r.FieldsPerRecord = -1
+	dec = NewDecoder(r)
+	xs, err = DecodeAll[X](dec)
+	if _, ok := err.(RowError); !ok {
+		t.Error("Expected a RowError, got", err)
+	}
+	if len(xs) != 1 {
+		t.Error("Expected the row before the error, got", xs)
+	}
+
+	dec = NewDecoder(csv.NewReader(strings.NewReader("")))
+	xs, err = DecodeAll[X](dec)
+	if err != nil || len(xs) != 0 {
+		t.Error("Expected no rows and no error, got", xs, err)
+	}
+}
+
+func TestRows(t *testing.T) {
+	type X struct {
+		A int
+		B string
+	}
+	r := csv.NewReader(strings.NewReader("1,blonde\n2,on\n3\n4,cake\n"))
+	r.FieldsPerRecord = -1
+	dec := NewDecoder(r)
+	var xs []X
+	var errs []error
+	for x, err := range Rows[X](dec) {
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		xs = append(xs, x)
+	}
+	if !reflect.DeepEqual(xs, []X{{1, "blonde"}, {2, "on"}}) {
+		t.Error("Unexpected rows:", xs)
+	}
+	if len(errs) != 1 {
+		t.Fatal("Expected one error, got", errs)
+	}
+	if _, ok := errs[0].(RowError); !ok {
+		t.Error("Expected a RowError, got", errs[0])
+	}
+
+	dec = NewDecoder(csv.NewReader(strings.NewReader("1,blonde\n2,on\n")))
+	for range Rows[X](dec) {
+		break
+	}
+	var x X
+	if err := dec.Decode(&x); err != nil || x.A != 2 {
+		t.Error("Expected the second row after breaking, got", x, err)
+	}
+}
+
+func TestErrorBudget(t *testing.T) {
+	type X struct {
+		A int
+	}
+	dec := NewDecoder(csv.NewReader(strings.NewReader("1\nx\n2\ny\nz\n3\n")), WithMaxErrors(2))
+	var x X
+	for i := 0; i < 4; i++ {
+		if err := dec.Decode(&x); err != nil {
+			t.Fatal("Expected no error on row", i, "got", err)
+		}
+	}
+	want := BudgetError{5, 3, nil}
+	if err := dec.Decode(&x); err != want {
+		t.Fatal("Expected", want, "got", err)
+	}
+	if err := dec.Decode(&x); err != want {
+		t.Error("Expected", want, "again, got", err)
+	}
+	if ErrorCode(want) != CodeErrorBudget || want.Error() != "aborted after 3 errors in 5 rows" {
+		t.Error("Unexpected error:", ErrorCode(want), want.Error())
+	}
+
+	dec = NewDecoder(csv.NewReader(strings.NewReader("1\nx\ny\n")), WithMaxErrorRate(0.5))
+	for i := 0; i < 3; i++ {
+		if err := dec.Decode(&x); err != nil {
+			t.Fatal("Expected no error on row", i, "got", err)
+		}
+	}
+	if err := dec.Decode(&x); err != (BudgetError{3, 2, nil}) {
+		t.Error("Expected a BudgetError in place of io.EOF, got", err)
+	}
+
+	r := csv.NewReader(strings.NewReader(strings.Repeat("1,2\n", 150)))
+	dec = NewDecoder(r, WithMaxErrorRate(0.5))
+	n := 0
+	var be BudgetError
+	for ; n < 150; n++ {
+		if err := dec.Decode(&x); errors.As(err, &be) {
+			break
+		}
+	}
+	if n != 99 {
+		t.Fatal("Expected a BudgetError on the 100th row, got one on row", n+1)
+	}
+	if _, ok := be.Err.(RowError); !ok {
+		t.Error("Expected the last error to be a RowError, got", be.Err)
+	}
+
+	dec = NewDecoder(csv.NewReader(strings.NewReader("1\n2\n")), WithMaxErrors(1), WithMaxErrorRate(0.1))
+	if xs, err := DecodeAll[X](dec); err != nil || len(xs) != 2 {
+		t.Error("Expected 2 rows and no error, got", xs, err)
+	}
+}
+
+// A level is an int that is written as a word.
+type level int
+
+var levels = []string{"low", "mid", "high"}
+
+func (l *level) UnmarshalText(b []byte) error {
+	for i, s := range levels {
+		if s == string(b) {
+			*l = level(i)
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown level %q", b)
+}
+
+func (l level) MarshalText() ([]byte, error) {
+	return []byte(levels[l]), nil
+}
+
+func TestDecodeTextUnmarshaler(t *testing.T) {
+	type X struct {
+		IP    net.IP
+		N     *big.Int
+		Level level
+	}
+	dec := NewDecoder(csv.NewReader(strings.NewReader("10.0.0.1,123456789012345678901234567890,high\n10.0.0.2,1,none\n")), WithStrict())
+	var x X
+	if err := dec.Decode(&x); err != nil {
+		t.Fatal("Expected no error, got", err)
+	}
+	if !x.IP.Equal(net.IPv4(10, 0, 0, 1)) || x.N.String() != "123456789012345678901234567890" || x.Level != 2 {
+		t.Error("Unexpected result:", x)
+	}
+
+	err := dec.Decode(&x)
+	var fe FieldError
+	if !errors.As(err, &fe) || fe.Field != "Level" || fe.Err.Error() != `unknown level "none"` {
+		t.Error("Expected a FieldError for Level, got", err)
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	type X struct {
+		A int
+		B string
+		C Raw
+	}
+	var log []string
+	logger := func(tag string) Middleware {
+		return func(f reflect.StructField, next func(*reflect.Value, string) error) func(*reflect.Value, string) error {
+			return func(v *reflect.Value, s string) error {
+				log = append(log, tag+" "+f.Name)
+				return next(v, s)
+			}
+		}
+	}
+	annotate := func(f reflect.StructField, next func(*reflect.Value, string) error) func(*reflect.Value, string) error {
+		if f.Type.Kind() != reflect.Int {
+			return next
+		}
+		return func(v *reflect.Value, s string) error {
+			if err := next(v, s); err != nil {
+				return fmt.Errorf("integer column %s: %w", f.Name, err)
+			}
+			return nil
+		}
+	}
+	dec := NewDecoder(csv.NewReader(strings.NewReader("1,blonde\nx,on\n")),
+		WithMiddleware(logger("outer"), annotate), WithMiddleware(logger("inner")), WithStrict())
+	var x X
+	if err := dec.Decode(&x); err != nil {
+		t.Fatal("Expected no error, got", err)
+	}
+	want := []string{"outer A", "inner A", "outer B", "inner B"}
+	if !reflect.DeepEqual(log, want) {
+		t.Error("Expected", want, "got", log)
+	}
+
+	err := dec.Decode(&x)
+	if err == nil || !strings.Contains(err.Error(), "integer column A: ") || !errors.Is(err, strconv.ErrSyntax) {
+		t.Error("Expected an annotated error for A, got", err)
+	}
+
+	log = nil
+	dec = NewDecoder(csv.NewReader(strings.NewReader("id,name\n7,blonde\n")), WithMiddleware(logger("map")))
+	m := map[string]interface{}{}
+	if err := dec.Decode(m); err != nil {
+		t.Fatal("Expected no error, got", err)
+	}
+	if !reflect.DeepEqual(log, []string{"map id", "map name"}) {
+		t.Error("Unexpected log for map:", log)
+	}
+}
+
+func TestDecodePointers(t *testing.T) {
+	type X struct {
+		A *int
+		B *string
+		C *float64 `table:",unit=m"`
+		D *time.Time
+	}
+	lines := `
+3,,2km,2014-03-01
+,blonde,,
+`
+	dec := NewDecoder(csv.NewReader(strings.NewReader(lines)), WithStrict())
+	x := X{B: new(string)}
+	if err := dec.Decode(&x); err != nil {
+		t.Fatal("Expected no error, got", err)
+	}
+	if x.A == nil || *x.A != 3 || x.B != nil || x.C == nil || *x.C != 2000 ||
+		x.D == nil || !x.D.Equal(time.Date(2014, 3, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("Unexpected result: %+v", x)
+	}
+	if err := dec.Decode(&x); err != nil {
+		t.Fatal("Expected no error, got", err)
+	}
+	if x.A != nil || x.B == nil || *x.B != "blonde" || x.C != nil || x.D != nil {
+		t.Errorf("Unexpected result: %+v", x)
+	}
+
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	enc := NewEncoder(w)
+	n := 7
+	if err := enc.Encode(X{A: &n}); err != nil {
+		t.Fatal("Expected no error, got", err)
+	}
+	w.Flush()
+	if buf.String() != "7,,,\n" {
+		t.Errorf("Unexpected output: %q", buf.String())
+	}
+
+	type Bad struct {
+		A *uintptr
+	}
+	if err := Check(Bad{}); err == nil || err.Error() != "uintptr is not decodable (field A, column 0)" {
+		t.Error("Expected an error for *uintptr, got", err)
+	}
+}
+
+func TestNulls(t *testing.T) {
+	type X struct {
+		A int
+		B *float64
+		C string
+		D Raw
+	}
+	lines := `
+NA,N/A, NULL 
+`
+	dec := NewDecoder(csv.NewReader(strings.NewReader(lines)),
+		WithNulls("NA", "N/A", "NULL"), WithTransform("C", TrimSpace), WithStrict())
+	one := 1.0
+	x := X{A: 3, B: &one, C: "blonde"}
+	if err := dec.Decode(&x); err != nil {
+		t.Fatal("Expected no error, got", err)
+	}
+	if x.A != 0 || x.B != nil || x.C != "" || x.D != " NULL " {
+		t.Errorf("Unexpected result: %+v", x)
+	}
+
+	dec = NewDecoder(csv.NewReader(strings.NewReader("a,b\n-,2\n")), WithNulls("-"), WithKinds(map[string]reflect.Kind{"a": reflect.Int}))
+	m := map[string]interface{}{}
+	if err := dec.Decode(m); err != nil {
+		t.Fatal("Expected no error, got", err)
+	}
+	if v, ok := m["a"]; !ok || v != nil || m["b"] != "2" {
+		t.Error("Unexpected result:", m)
+	}
+}
+
+func TestEmptyRows(t *testing.T) {
+	type X struct {
+		A int
+		B string
+	}
+	lines := "1,blonde\n,\n2,on\n"
+	tests := []struct {
+		policy EmptyRowPolicy
+		want   []X
+		err    error
+	}{
+		{EmptyRowSkip, []X{{1, "blonde"}, {2, "on"}}, nil},
+		{EmptyRowZero, []X{{1, "blonde"}, {}, {2, "on"}}, nil},
+		{EmptyRowError, []X{{1, "blonde"}}, ErrEmptyRow},
+	}
+	for _, test := range tests {
+		dec := NewDecoder(csv.NewReader(strings.NewReader(lines)), WithEmptyRows(test.policy), WithStrict())
+		xs, err := DecodeAll[X](dec)
+		if !errors.Is(err, test.err) {
+			t.Errorf("Expected %v for policy %d, got %v", test.err, test.policy, err)
+		}
+		if !reflect.DeepEqual(xs, test.want) {
+			t.Errorf("Expected %v for policy %d, got %v", test.want, test.policy, xs)
+		}
+	}
+	if ErrorCode(ErrEmptyRow) != CodeEmptyRow {
+		t.Error("Unexpected code:", ErrorCode(ErrEmptyRow))
+	}
+
+	dec := NewDecoder(csv.NewReader(strings.NewReader("a,b\n,\n")), WithEmptyRows(EmptyRowZero),
+		WithKinds(map[string]reflect.Kind{"a": reflect.Int}))
+	m := map[string]interface{}{}
+	if err := dec.Decode(m); err != nil {
+		t.Fatal("Expected no error, got", err)
+	}
+	if !reflect.DeepEqual(m, map[string]interface{}{"a": 0, "b": ""}) {
+		t.Error("Unexpected result:", m)
+	}
+}
+
+func TestMissingCells(t *testing.T) {
+	type X struct {
+		A int
+		B *string
+		C *string
+	}
+	str := func(s string) *string { return &s }
+	lines := "1,blonde,\n2,on\n3\n"
+	tests := []struct {
+		policy MissingCellPolicy
+		want   []X
+	}{
+		{MissingEmpty, []X{{1, str("blonde"), str("")}, {2, str("on"), str("")}, {3, str(""), str("")}}},
+		{MissingNull, []X{{1, str("blonde"), str("")}, {2, str("on"), nil}, {3, nil, nil}}},
+	}
+	for _, test := range tests {
+		r := csv.NewReader(strings.NewReader(lines))
+		r.FieldsPerRecord = -1
+		dec := NewDecoder(r, WithMissingCells(test.policy), WithEmptyStrings(), WithStrict())
+		xs, err := DecodeAll[X](dec)
+		if err != nil {
+			t.Errorf("Expected no error for policy %d, got %v", test.policy, err)
+		}
+		if !reflect.DeepEqual(xs, test.want) {
+			t.Errorf("Expected %+v for policy %d, got %+v", test.want, test.policy, xs)
+		}
+	}
+
+	r := csv.NewReader(strings.NewReader("1,blonde,\n2,on\n"))
+	r.FieldsPerRecord = -1
+	dec := NewDecoder(r)
+	var x X
+	if err := dec.Decode(&x); err != nil || x.C != nil {
+		t.Error("Expected an empty cell to be nil by default, got", x.C, err)
+	}
+	if err := dec.Decode(&x); err != (RowError{2, 3, "C", 2, 2}) {
+		t.Error("Expected a RowError by default, got", err)
+	}
+
+	r = csv.NewReader(strings.NewReader("a,b,c\n1,\n2\n"))
+	r.FieldsPerRecord = -1
+	dec = NewDecoder(r, WithMissingCells(MissingNull))
+	m := map[string]interface{}{}
+	if err := dec.Decode(m); err != nil {
+		t.Fatal("Expected no error, got", err)
+	}
+	if !reflect.DeepEqual(m, map[string]interface{}{"a": "1", "b": "", "c": nil}) {
+		t.Error("Unexpected result:", m)
+	}
+}
+
+func TestRecordNumbers(t *testing.T) {
+	type X struct {
+		A int
+		B string
+	}
+	lines := "A,B\n1,\"blonde\non\"\nx,on\n3\n"
+	r := csv.NewReader(strings.NewReader(lines))
+	r.FieldsPerRecord = -1
+	dec := NewDecoder(r, WithUseHeader(), WithStrict())
+	var x X
+	if err := dec.Decode(&x); err != nil || dec.Record() != 2 {
+		t.Error("Expected record 2 and no error, got", dec.Record(), err)
+	}
+	var fe FieldError
+	if err := dec.Decode(&x); !errors.As(err, &fe) || fe.Record != 3 || fe.Line != 4 {
+		t.Error("Expected a FieldError in record 3 on line 4, got", err)
+	}
+	if err := dec.Decode(&x); err != (RowError{1, 2, "B", 4, 5}) {
+		t.Error("Expected a RowError in record 4 on line 5, got", err)
+	}
+}
+
+func TestModifyField(t *testing.T) {
+	type X struct {
+		Name  string
+		Price string
+		N     int
+	}
+	cents := func(v *reflect.Value, s string) error {
+		v.SetString(strings.TrimPrefix(s, "$"))
+		return nil
+	}
+	double := func(v *reflect.Value, s string) error {
+		n, err := strconv.Atoi(s)
+		v.SetInt(int64(2 * n))
+		return err
+	}
+	dec := NewDecoder(csv.NewReader(strings.NewReader("$blonde,$3.50,4\n")), WithModifyField(&X{}, "Price", cents))
+	other := *dec
+	dec.ModifyField(X{}, "N", double)
+	var x X
+	if err := dec.Decode(&x); err != nil {
+		t.Fatal("Expected no error, got", err)
+	}
+	if x != (X{"$blonde", "3.50", 8}) {
+		t.Error("Unexpected result:", x)
+	}
+	if _, ok := other.ModifyFields[reflect.TypeOf(X{})]["N"]; ok {
+		t.Error("Expected ModifyField not to affect a copy of the Decoder")
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Error("Expected a panic for an unknown field")
+		}
+	}()
+	dec.ModifyField(X{}, "Cost", cents)
+}
+
+// A cents is an amount of money, written like "$1.25".
+type cents int64
+
+func (c *cents) UnmarshalField(s string) error {
+	d, err := strconv.ParseFloat(strings.TrimPrefix(s, "$"), 64)
+	*c = cents(math.Round(d * 100))
+	return err
+}
+
+func (c cents) MarshalField() (string, error) {
+	return fmt.Sprintf("$%d.%02d", c/100, c%100), nil
+}
+
+func TestUnmarshaler(t *testing.T) {
+	type X struct {
+		A cents
+		B *cents
+		C cents `table:",unit=m"`
+	}
+	dec := NewDecoder(csv.NewReader(strings.NewReader("$1.25,$3,$0.5\n")), WithStrict())
+	var x X
+	if err := dec.Decode(&x); err != nil {
+		t.Fatal("Expected no error, got", err)
+	}
+	if x.A != 125 || x.B == nil || *x.B != 300 || x.C != 50 {
+		t.Errorf("Unexpected result: %+v", x)
+	}
+
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	enc := NewEncoder(w)
+	if err := enc.Encode(x); err != nil {
+		t.Fatal("Expected no error, got", err)
+	}
+	w.Flush()
+	if buf.String() != "$1.25,$3.00,$0.50\n" {
+		t.Errorf("Unexpected output: %q", buf.String())
+	}
+}
+
+type Coord struct{ X, Y float64 }
+
+func (p *Coord) Scan(src interface{}) error {
+	s, ok := src.(string)
+	if !ok {
+		return fmt.Errorf("cannot scan %T into a Coord", src)
+	}
+	_, err := fmt.Sscanf(s, "(%g %g)", &p.X, &p.Y)
+	return err
+}
+
+type upper string
+
+func (u *upper) Scan(src interface{}) error {
+	*u = upper(strings.ToUpper(src.(string)))
+	return nil
+}
+
+func TestDecodeScanner(t *testing.T) {
+	type X struct {
+		P Coord
+		Q *Coord
+		U upper
+	}
+	dec := NewDecoder(csv.NewReader(strings.NewReader("(1 2),(3 4),abc\n(1 2),,abc\nbad,,\n")), WithStrict())
+	var x X
+	if err := dec.Decode(&x); err != nil {
+		t.Fatal("Expected no error, got", err)
+	}
+	if x.P != (Coord{1, 2}) || x.Q == nil || *x.Q != (Coord{3, 4}) || x.U != "abc" {
+		t.Error("Unexpected result:", x)
+	}
+	if err := dec.Decode(&x); err != nil || x.Q != nil {
+		t.Error("Expected a nil Q, got", x, err)
+	}
+	var fe FieldError
+	if err := dec.Decode(&x); !errors.As(err, &fe) || fe.Field != "P" {
+		t.Error("Expected a FieldError for P, got", err)
+	}
+
+	type E struct {
+		Coord
+		N int
+	}
+	dec = NewDecoder(csv.NewReader(strings.NewReader("(5 6),7\n")), WithStrict())
+	var e E
+	if err := dec.Decode(&e); err != nil || e.Coord != (Coord{5, 6}) || e.N != 7 {
+		t.Error("Expected an embedded Scanner to be decoded whole, got", e, err)
+	}
+}
+
+func TestDecodeComplex(t *testing.T) {
+	type X struct {
+		A complex64
+		B complex128
+		C *complex128
+	}
+	dec := NewDecoder(csv.NewReader(strings.NewReader("1+2i,(-0.5-3i),\n3,2.5i,1e3+0i\nx,,\n")), WithStrict())
+	var x X
+	if err := dec.Decode(&x); err != nil || x.A != 1+2i || x.B != -0.5-3i || x.C != nil {
+		t.Error("Unexpected result:", x, err)
+	}
+	if err := dec.Decode(&x); err != nil || x.A != 3 || x.B != 2.5i || x.C == nil || *x.C != 1000 {
+		t.Error("Unexpected result:", x, err)
+	}
+	var fe FieldError
+	if err := dec.Decode(&x); !errors.As(err, &fe) || fe.Field != "A" {
+		t.Error("Expected a FieldError for A, got", err)
+	}
+
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	enc := NewEncoder(w)
+	if err := enc.Encode(X{1 + 2i, -0.5 - 3i, nil}); err != nil {
+		t.Fatal("Expected no error, got", err)
+	}
+	w.Flush()
+	if buf.String() != "(1+2i),(-0.5-3i),\n" {
+		t.Errorf("Unexpected output: %q", buf.String())
+	}
+}
+
+func TestDecodeNetwork(t *testing.T) {
+	type X struct {
+		IP     net.IP
+		Addr   netip.Addr
+		Prefix netip.Prefix
+		Net    net.IPNet
+		URL    *url.URL
+	}
+	in := "10.0.0.1,::1,192.168.0.0/16,10.1.2.3/8,https://example.com/a?b=c\n1.2.3.4,1.2.3.4,1.2.3.0/24,2001:db8::/32,\n"
+	dec := NewDecoder(csv.NewReader(strings.NewReader(in)), WithStrict())
+	var xs []X
+	for {
+		var x X
+		err := dec.Decode(&x)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal("Expected no error, got", err)
+		}
+		xs = append(xs, x)
+	}
+	if len(xs) != 2 {
+		t.Fatal("Expected 2 rows, got", len(xs))
+	}
+	x := xs[0]
+	if !x.IP.Equal(net.IPv4(10, 0, 0, 1)) || x.Addr != netip.IPv6Loopback() || x.Prefix.String() != "192.168.0.0/16" ||
+		x.Net.String() != "10.0.0.0/8" || x.URL == nil || x.URL.Host != "example.com" || x.URL.Query().Get("b") != "c" {
+		t.Error("Unexpected first row:", x)
+	}
+	if xs[1].URL != nil || xs[1].Net.String() != "2001:db8::/32" {
+		t.Error("Unexpected second row:", xs[1])
+	}
+
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	enc := NewEncoder(w)
+	for _, x := range append(xs, X{}) {
+		if err := enc.Encode(x); err != nil {
+			t.Fatal("Expected no error, got", err)
+		}
+	}
+	w.Flush()
+	want := "10.0.0.1,::1,192.168.0.0/16,10.0.0.0/8,https://example.com/a?b=c\n1.2.3.4,1.2.3.4,1.2.3.0/24,2001:db8::/32,\n,,,,\n"
+	if buf.String() != want {
+		t.Errorf("Expected %q, got %q", want, buf.String())
+	}
+
+	dec = NewDecoder(csv.NewReader(strings.NewReader(",,,10.0.0.1,\n")), WithStrict())
+	var fe FieldError
+	if err := dec.Decode(&x); !errors.As(err, &fe) || fe.Field != "Net" {
+		t.Error("Expected a FieldError for Net, got", err)
+	}
+}
+
+type celsius float64
+
+type Span struct{ From, To int }
+
+func TestRegisterConverter(t *testing.T) {
+	RegisterConverter(func(s string) (celsius, error) {
+		n, err := strconv.ParseFloat(strings.TrimSuffix(s, "°C"), 64)
+		return celsius(n), err
+	})
+	RegisterConverter(func(s string) (Span, error) {
+		var sp Span
+		_, err := fmt.Sscanf(s, "%d-%d", &sp.From, &sp.To)
+		return sp, err
+	})
+	type X struct {
+		T  celsius
+		P  *celsius
+		S  Span
+		Ts []celsius `table:",split=;"`
+	}
+	dec := NewDecoder(csv.NewReader(strings.NewReader("21.5°C,,3-7,1°C;2\nhot,,,\n")), WithStrict())
+	var x X
+	if err := dec.Decode(&x); err != nil {
+		t.Fatal("Expected no error, got", err)
+	}
+	if x.T != 21.5 || x.P != nil || x.S != (Span{3, 7}) || !reflect.DeepEqual(x.Ts, []celsius{1, 2}) {
+		t.Error("Unexpected result:", x)
+	}
+	var fe FieldError
+	if err := dec.Decode(&x); !errors.As(err, &fe) || fe.Field != "T" {
+		t.Error("Expected a FieldError for T, got", err)
+	}
+
+	type E struct {
+		Span
+		N int
+	}
+	dec = NewDecoder(csv.NewReader(strings.NewReader("1-2,3\n")), WithStrict())
+	var e E
+	if err := dec.Decode(&e); err != nil || e.Span != (Span{1, 2}) || e.N != 3 {
+		t.Error("Expected the embedded Span decoded whole, got", e, err)
+	}
+}
+
+func TestBoolTokens(t *testing.T) {
+	type X struct {
+		A bool
+		B *bool `table:",bool=ja/nein"`
+		C bool  `table:",bool=Y/N"`
+	}
+	lines := "Yes,JA,y\noff,nein,true\nmaybe,,\n"
+	dec := NewDecoder(csv.NewReader(strings.NewReader(lines)), WithStrict(),
+		WithBoolTokens(map[string]bool{"yes": true, "no": false, "on": true, "off": false}))
+	var x X
+	if err := dec.Decode(&x); err != nil {
+		t.Fatal("Expected no error, got", err)
+	}
+	if !x.A || x.B == nil || !*x.B || !x.C {
+		t.Error("Unexpected result:", x)
+	}
+	if err := dec.Decode(&x); err != nil || x.A || *x.B || !x.C {
+		t.Error("Unexpected result:", x, err)
+	}
+	var fe FieldError
+	if err := dec.Decode(&x); !errors.As(err, &fe) || fe.Field != "A" {
+		t.Error("Expected a FieldError for A, got", err)
+	}
+
+	dec = NewDecoder(csv.NewReader(strings.NewReader("b\nOn\n")), WithUseHeader(),
+		WithKinds(map[string]reflect.Kind{"b": reflect.Bool}), WithBoolTokens(map[string]bool{"on": true}))
+	m := map[string]interface{}{}
+	if err := dec.Decode(m); err != nil || m["b"] != true {
+		t.Error("Expected b to be true, got", m, err)
+	}
+
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	enc := NewEncoder(w)
+	f := false
+	if err := enc.Encode(X{true, &f, true}); err != nil {
+		t.Fatal("Expected no error, got", err)
+	}
+	w.Flush()
+	if buf.String() != "true,nein,Y\n" {
+		t.Error("Expected true,nein,Y, got", buf.String())
+	}
+
+	type Bad struct {
+		A int  `table:",bool=y/n"`
+		B bool `table:",bool=y"`
+	}
+	if errs, ok := Check(Bad{}).(Errors); !ok || len(errs) != 2 {
+		t.Error("Expected 2 errors, got", errs)
+	}
+}
+
+func TestPrefixedInts(t *testing.T) {
+	type X struct {
+		A int
+		B uint8
+		C *int64 `table:",base=16"`
+		D int    `table:",base=0"`
+		E int16  `table:",base=2"`
+	}
+	lines := "0x1F,0b101,ff,0o17,-101\n1_000,0o377,-7f,12,0\n"
+	dec := NewDecoder(csv.NewReader(strings.NewReader(lines)), WithStrict(), WithPrefixedInts())
+	var x X
+	if err := dec.Decode(&x); err != nil {
+		t.Fatal("Expected no error, got", err)
+	}
+	if x.A != 31 || x.B != 5 || x.C == nil || *x.C != 255 || x.D != 15 || x.E != -5 {
+		t.Error("Unexpected result:", x)
+	}
+	if err := dec.Decode(&x); err != nil || x.A != 1000 || x.B != 255 || *x.C != -127 || x.D != 12 || x.E != 0 {
+		t.Error("Unexpected result:", x, err)
+	}
+
+	dec = NewDecoder(csv.NewReader(strings.NewReader("0x10,0,ff,0,0\n")), WithStrict())
+	var fe FieldError
+	if err := dec.Decode(&x); !errors.As(err, &fe) || fe.Field != "A" {
+		t.Error("Expected a FieldError for A without PrefixedInts, got", err)
+	}
+
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	enc := NewEncoder(w)
+	c := int64(-255)
+	if err := enc.Encode(X{31, 5, &c, 15, 5}); err != nil {
+		t.Fatal("Expected no error, got", err)
+	}
+	w.Flush()
+	if buf.String() != "31,5,-ff,15,101\n" {
+		t.Error("Expected 31,5,-ff,15,101, got", buf.String())
+	}
+
+	type Bad struct {
+		A float64 `table:",base=16"`
+		B int     `table:",base=37"`
+	}
+	if errs, ok := Check(Bad{}).(Errors); !ok || len(errs) != 2 {
+		t.Error("Expected 2 errors, got", errs)
+	}
+}
+
+func TestClamp(t *testing.T) {
+	type X struct {
+		A int8
+		B *uint16
+		C float32
+		D []uint8 `table:",split=;"`
+		E int
+	}
+	var rows [][]string
+	var clamped []OverflowError
+	lines := "300,-5,1e39,1;999;-1,7\n-300,70000,-1e39,2,0\n0,0,1e-50,0,0\n"
+	dec := NewDecoder(csv.NewReader(strings.NewReader(lines)), WithStrict(), WithClamp(),
+		WithOnClamp(func(row []string, c []OverflowError) {
+			rows = append(rows, row)
+			clamped = append(clamped, c...)
+		}))
+	var x X
+	if err := dec.Decode(&x); err != nil {
+		t.Fatal("Expected no error, got", err)
+	}
+	if x.A != 127 || x.B == nil || *x.B != 0 || x.C != math.MaxFloat32 || !reflect.DeepEqual(x.D, []uint8{1, 255, 0}) || x.E != 7 {
+		t.Error("Unexpected result:", x)
+	}
+	if len(rows) != 1 || len(clamped) != 5 {
+		t.Fatal("Expected 5 clamped values in 1 row, got", rows, clamped)
+	}
+	if c := clamped[0]; c.Field != "A" || c.Column != 0 || c.Value != "300" || c.Type != reflect.TypeOf(int8(0)) || c.Record != 1 {
+		t.Error("Unexpected OverflowError:", c)
+	}
+	if c := clamped[3]; c.Field != "D" || c.Column != 3 || c.Value != "999" || c.Type != reflect.TypeOf(uint8(0)) {
+		t.Error("Unexpected OverflowError:", c)
+	}
+
+	if err := dec.Decode(&x); err != nil {
+		t.Fatal("Expected no error, got", err)
+	}
+	if x.A != -128 || *x.B != 65535 || x.C != -math.MaxFloat32 || x.E != 0 {
+		t.Error("Unexpected result:", x)
+	}
+	if len(rows) != 2 || len(clamped) != 8 {
+		t.Error("Expected 8 clamped values in 2 rows, got", rows, clamped)
+	}
+	if err := dec.Decode(&x); err != nil || x.C != 0 {
+		t.Error("Expected C to be 0, got", x.C, err)
+	}
+
+	dec = NewDecoder(csv.NewReader(strings.NewReader("n\n-1e400\n")), WithUseHeader(), WithClamp(),
+		WithKinds(map[string]reflect.Kind{"n": reflect.Float64}))
+	m := map[string]interface{}{}
+	if err := dec.Decode(m); err != nil || m["n"] != -math.MaxFloat64 {
+		t.Error("Expected n to be clamped, got", m, err)
+	}
+}
+
+func TestModifyNotShared(t *testing.T) {
+	a := NewDecoder(csv.NewReader(strings.NewReader("1\n")))
+	b := NewDecoder(csv.NewReader(strings.NewReader("1\n")))
+	a.Modify[reflect.Int] = func(v *reflect.Value, f string) error {
+		v.SetInt(42)
+		return nil
+	}
+	var x struct{ N int }
+	if err := a.Decode(&x); err != nil || x.N != 42 {
+		t.Error("Expected 42 from a's Modify, got", x.N, err)
+	}
+	if err := b.Decode(&x); err != nil || x.N != 1 {
+		t.Error("Expected 1 from b's Modify, got", x.N, err)
+	}
+
+	e := NewEncoder(csv.NewWriter(io.Discard))
+	e.Format[reflect.Int] = nil
+	if NewEncoder(csv.NewWriter(io.Discard)).Format[reflect.Int] == nil {
+		t.Error("Changing one Encoder's Format changed another's")
+	}
+}
+
+func TestFastFields(t *testing.T) {
+	type Inner struct {
+		I8  int8
+		U16 uint16
+	}
+	type Outer struct {
+		O8 int8
+		O  string
+	}
+	type score float64
+	type X struct {
+		A bool
+		B int
+		C int32
+		Inner
+		D uint
+		E float32
+		F float64
+		G string
+		H score
+		*Outer
+	}
+	lines := "true,-7,42,-8,65535,9,1.5,2.25,hi,3.5,1,2\n"
+	dec := NewDecoder(csv.NewReader(strings.NewReader(lines+"x,300,1,1,1,1,1,1,s,1,1,1\n")), WithStrict(), WithAllErrors())
+	var x X
+	if err := dec.Decode(&x); err != nil {
+		t.Fatal("Expected no error, got", err)
+	}
+	want := X{true, -7, 42, Inner{-8, 65535}, 9, 1.5, 2.25, "hi", 3.5, &Outer{1, "2"}}
+	if !reflect.DeepEqual(x, want) {
+		t.Error("Expected", want, "got", x)
+	}
+	errs, ok := dec.Decode(&x).(Errors)
+	if !ok || len(errs) != 1 || ErrorCode(errs[0]) != CodeParseFailure || x.A || x.B != 300 {
+		t.Error("Expected a FieldError for A, got", errs, x)
+	}
+}
+
+// byteLines is a ByteFieldReader of comma-separated lines, which reuses
+// its buffer for each row.
+type byteLines struct {
+	lines [][]byte
+	buf   []byte
+}
+
+func (b *byteLines) ReadBytes() ([][]byte, error) {
+	if len(b.lines) == 0 {
+		return nil, io.EOF
+	}
+	b.buf = append(b.buf[:0], b.lines[0]...)
+	b.lines = b.lines[1:]
+	return bytes.Split(b.buf, []byte(",")), nil
+}
+
+// A Version is a version number, like "1.2".
+type Version struct{ Major, Minor int }
+
+func TestByteFields(t *testing.T) {
+	RegisterByteConverter(func(b []byte) (Version, error) {
+		major, minor, ok := bytes.Cut(b, []byte("."))
+		if !ok {
+			return Version{}, errors.New("no minor version")
+		}
+		x, err := strconv.Atoi(string(major))
+		if err != nil {
+			return Version{}, err
+		}
+		y, err := strconv.Atoi(string(minor))
+		return Version{x, y}, err
+	})
+	type X struct {
+		Name string
+		V    Version
+		N    int
+	}
+	r := &byteLines{lines: bytes.Split([]byte("tbl,1.2,3\ncsv,10.0,4\nbad,1,5"), []byte("\n"))}
+	dec := NewDecoder(ByteFields(r), WithStrict())
+	var xs []X
+	for {
+		var x X
+		err := dec.Decode(&x)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			if len(xs) != 2 || !strings.Contains(err.Error(), "no minor version") {
+				t.Error("Expected an error for the third row, got", err)
+			}
+			continue
+		}
+		xs = append(xs, x)
+	}
+	want := []X{{"tbl", Version{1, 2}, 3}, {"csv", Version{10, 0}, 4}}
+	if !reflect.DeepEqual(xs, want) {
+		t.Error("Expected", want, "got", xs)
+	}
+}
+
+func TestReuseRecord(t *testing.T) {
+	r := csv.NewReader(strings.NewReader("name,n\nx,1\ny,2\n"))
+	r.ReuseRecord = true
+	dec := NewDecoder(r)
+	var ms []map[string]interface{}
+	for {
+		m := map[string]interface{}{}
+		err := dec.Decode(m)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal("Expected no error, got", err)
+		}
+		ms = append(ms, m)
+	}
+	want := []map[string]interface{}{{"name": "x", "n": "1"}, {"name": "y", "n": "2"}}
+	if !reflect.DeepEqual(ms, want) {
+		t.Error("Expected", want, "got", ms)
+	}
+}
+
+// bufferLines is a FieldReader of comma-separated lines whose cells are
+// views of a buffer that it reuses for each row.
+type bufferLines struct {
+	lines []string
+	buf   []byte
+	row   []string
+}
+
+func (b *bufferLines) Read() ([]string, error) {
+	if len(b.lines) == 0 {
+		return nil, io.EOF
+	}
+	b.buf = append(b.buf[:0], b.lines[0]...)
+	b.lines = b.lines[1:]
+	b.row = b.row[:0]
+	for _, f := range bytes.Split(b.buf, []byte(",")) {
+		b.row = append(b.row, unsafe.String(unsafe.SliceData(f), len(f)))
+	}
+	return b.row, nil
+}
+
+func TestCopyRows(t *testing.T) {
+	type X struct {
+		Name string
+		N    int
+	}
+	dec := NewDecoder(&bufferLines{lines: []string{"ab,1", "cd,2"}}, WithCopyRows())
+	xs, err := DecodeAll[X](dec)
+	if err != nil {
+		t.Fatal("Expected no error, got", err)
+	}
+	want := []X{{"ab", 1}, {"cd", 2}}
+	if !reflect.DeepEqual(xs, want) {
+		t.Error("Expected", want, "got", xs)
+	}
+}
+
+func TestTypedDecoder(t *testing.T) {
+	type X struct {
+		Name string
+		N    int
+	}
+	td, err := NewTypedDecoder[X](csv.NewReader(strings.NewReader("a,1\nb,x\n")), WithStrict())
+	if err != nil {
+		t.Fatal("Expected no error, got", err)
+	}
+	x, err := td.Decode()
+	if err != nil || x != (X{"a", 1}) {
+		t.Error("Expected {a 1}, got", x, err)
+	}
+	x, err = td.Decode()
+	if _, ok := err.(FieldError); !ok || x != (X{}) {
+		t.Error("Expected a FieldError and a zero X, got", x, err)
+	}
+	if _, err = td.Decode(); err != io.EOF {
+		t.Error("Expected io.EOF, got", err)
+	}
+
+	type Bad struct {
+		A int
+		C fmt.Stringer
+	}
+	_, err = NewTypedDecoder[Bad](csv.NewReader(strings.NewReader("1,2\n")))
+	if errs, ok := err.(Errors); !ok || len(errs) != 1 {
+		t.Error("Expected an Errors for C, got", err)
+	}
+	_, err = NewTypedDecoder[int](csv.NewReader(strings.NewReader("1\n")))
+	if _, ok := err.(InvalidDecodeError); !ok {
+		t.Error("Expected an InvalidDecodeError, got", err)
+	}
+}
+
+func TestDecodeParallel(t *testing.T) {
+	type X struct {
+		N int
+		F float64
+		S string
+	}
+	var b strings.Builder
+	var want []X
+	for i := range 1000 {
+		x := X{i, float64(i) / 4, strconv.Itoa(i * 2)}
+		fmt.Fprintf(&b, "%d,%g,%s\n", x.N, x.F, x.S)
+		want = append(want, x)
+	}
+
+	xs, err := DecodeParallel[X](NewDecoder(csv.NewReader(strings.NewReader(b.String()))), 4, true)
+	if err != nil || !reflect.DeepEqual(xs, want) {
+		t.Error("Expected the rows in order, got", len(xs), "rows and", err)
+	}
+
+	xs, err = DecodeParallel[X](NewDecoder(csv.NewReader(strings.NewReader(b.String()))), 0, false)
+	slices.SortFunc(xs, func(a, b X) int { return a.N - b.N })
+	if err != nil || !reflect.DeepEqual(xs, want) {
+		t.Error("Expected every row, got", len(xs), "rows and", err)
+	}
+
+	lines := "1,1,a\n2,x,b\n3,3,c\n"
+	xs, err = DecodeParallel[X](NewDecoder(csv.NewReader(strings.NewReader(lines)), WithStrict()), 2, true)
+	var fe FieldError
+	if !errors.As(err, &fe) || fe.Record != 2 || fe.Line != 2 || !reflect.DeepEqual(xs, []X{{1, 1, "a"}}) {
+		t.Error("Expected the first row and a FieldError on line 2, got", xs, err)
+	}
+
+	var bad [][]string
+	onBad := WithOnBadRow(func(row []string, err error) { bad = append(bad, row) })
+	xs, err = DecodeParallel[X](NewDecoder(csv.NewReader(strings.NewReader(lines)), WithStrict(), onBad), 2, true)
+	if err != nil || !reflect.DeepEqual(xs, []X{{1, 1, "a"}, {3, 3, "c"}}) || !reflect.DeepEqual(bad, [][]string{{"2", "x", "b"}}) {
+		t.Error("Expected rows 1 and 3, and row 2 passed to OnBadRow, got", xs, bad, err)
+	}
+}
+
+func TestDecodeParallelRateAfterEOF(t *testing.T) {
+	type X struct {
+		S string
+		N int
+	}
+	slow := WithModifyField(X{}, "S", func(v *reflect.Value, f string) error {
+		if f == "slow" {
+			time.Sleep(50 * time.Millisecond)
+		}
+		v.SetString(f)
+		return nil
+	})
+	lines := "slow,a\nb,b\nc,c\nd,d\n"
+	for _, ordered := range []bool{false, true} {
+		dec := NewDecoder(csv.NewReader(strings.NewReader(lines)), slow, WithMaxErrorRate(0.5))
+		xs, err := DecodeParallel[X](dec, 2, ordered)
+		if _, ok := err.(BudgetError); !ok {
+			t.Error("Expected a BudgetError with ordered", ordered, "got", len(xs), "rows and", err)
+		}
+	}
+}
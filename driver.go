@@ -0,0 +1,133 @@
+// © 2014 Steve McCoy.
+
+package table
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"io"
+	"reflect"
+)
+
+// DriverRows returns a driver.Rows that reads the remaining rows of d,
+// each decoded into a T, as with Decode, so that a database/sql driver
+// can serve a table as the result of a query.
+//
+// If T is a struct, the columns are its fields that are bound to columns,
+// named by their tags or Go names, except one bound to the rest of them.
+// A field's value is written as text, as an Encoder would, if it is a
+// Marshaler or encoding.TextMarshaler other than a time.Time, and
+// otherwise converted as by driver.DefaultParameterConverter, if it can be.
+// If T is Record, the columns are those of d's Header, which is read
+// if it is not known yet, and their values are typed as d.Kinds says.
+func DriverRows[T any](d *Decoder) driver.Rows {
+	rows := &driverRows[T]{d: d}
+	t := reflect.TypeOf((*T)(nil)).Elem()
+	switch {
+	case t == reflect.TypeOf(Record{}):
+		rows.err = d.readHeader()
+		if rows.err == nil {
+			var r *resolved
+			r, rows.err = d.resolve()
+			if rows.err == nil {
+				for _, name := range r.names {
+					if name != "" {
+						rows.columns = append(rows.columns, name)
+					}
+				}
+			}
+		}
+	case t.Kind() == reflect.Struct:
+		plan, errs := cachedLayout(t, false)
+		if len(errs) > 0 {
+			rows.err = errs[0]
+		}
+		for _, f := range plan {
+			if !f.twin && !f.rest {
+				rows.fields = append(rows.fields, f)
+				rows.columns = append(rows.columns, f.columnName())
+			}
+		}
+	default:
+		rows.err = InvalidDecodeError{reflect.PointerTo(t)}
+	}
+	return rows
+}
+
+// driverRows is the driver.Rows returned by DriverRows.
+type driverRows[T any] struct {
+	d       *Decoder
+	columns []string
+	fields  []field // the fields of each column, if T is a struct
+	err     error   // an error that prevents any row from being read
+	closed  bool
+	enc     Encoder // for fields that have no driver.Value
+}
+
+func (r *driverRows[T]) Columns() []string {
+	return r.columns
+}
+
+func (r *driverRows[T]) Close() error {
+	r.closed = true
+	return nil
+}
+
+func (r *driverRows[T]) Next(dest []driver.Value) error {
+	if r.err != nil {
+		return r.err
+	}
+	if r.closed {
+		return io.EOF
+	}
+	var t T
+	if err := r.d.Decode(&t); err != nil {
+		return err
+	}
+	if rec, ok := interface{}(&t).(*Record); ok {
+		for i := range dest {
+			if i < len(rec.Values) {
+				dest[i] = rec.Values[i]
+			}
+		}
+		return nil
+	}
+	v := reflect.ValueOf(t)
+	for i, f := range r.fields {
+		if i >= len(dest) {
+			break
+		}
+		fv, err := v.FieldByIndexErr(f.index)
+		if err != nil {
+			dest[i] = nil
+			continue
+		}
+		if dest[i], err = r.value(f, fv); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// value returns fv, the value of f, as a driver.Value.
+func (r *driverRows[T]) value(f field, fv reflect.Value) (driver.Value, error) {
+	_, valuer := fv.Interface().(driver.Valuer)
+	t := fv.Type()
+	text := t != timeType && (t.Implements(marshalerType) || t.Implements(textMarshalerType))
+	if valuer || !text {
+		if dv, err := driver.DefaultParameterConverter.ConvertValue(fv.Interface()); err == nil {
+			return dv, nil
+		}
+	}
+	if r.enc.Format == nil {
+		r.enc = NewEncoder(nil)
+	}
+	if f.combiner.Columns > 0 {
+		return fmt.Sprint(fv.Interface()), nil
+	}
+	format, err := r.enc.formatter(f.sf, f.tag)
+	if err != nil {
+		return nil, err
+	}
+	return format(fv)
+}
@@ -0,0 +1,59 @@
+// © 2014 Steve McCoy.
+
+package table
+
+import (
+	"database/sql/driver"
+	"encoding/csv"
+	"io"
+	"net"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDriverRows(t *testing.T) {
+	type X struct {
+		ID   int `table:"id"`
+		Name string
+		Rate *float64
+		Seen time.Time
+		IP   net.IP
+		Lvl  level
+	}
+	lines := "7,blonde,2.5,2014-03-01T00:00:00Z,10.0.0.1,high\n8,on,,2014-03-02T00:00:00Z,10.0.0.2,low\n"
+	dec := NewDecoder(csv.NewReader(strings.NewReader(lines)))
+	rows := DriverRows[X](dec)
+	if !reflect.DeepEqual(rows.Columns(), []string{"id", "Name", "Rate", "Seen", "IP", "Lvl"}) {
+		t.Error("Unexpected columns:", rows.Columns())
+	}
+	dest := make([]driver.Value, 6)
+	if err := rows.Next(dest); err != nil {
+		t.Fatal("Expected no error, got", err)
+	}
+	want := []driver.Value{int64(7), "blonde", 2.5, time.Date(2014, 3, 1, 0, 0, 0, 0, time.UTC), "10.0.0.1", "high"}
+	if !reflect.DeepEqual(dest, want) {
+		t.Errorf("Expected %#v, got %#v", want, dest)
+	}
+	if err := rows.Next(dest); err != nil || dest[2] != nil {
+		t.Error("Expected a nil Rate, got", dest[2], err)
+	}
+	if err := rows.Next(dest); err != io.EOF {
+		t.Error("Expected io.EOF, got", err)
+	}
+
+	dec = NewDecoder(csv.NewReader(strings.NewReader("a,b\n1,x\n")), WithKinds(map[string]reflect.Kind{"a": reflect.Int64}))
+	rows = DriverRows[Record](dec)
+	if !reflect.DeepEqual(rows.Columns(), []string{"a", "b"}) {
+		t.Error("Unexpected columns:", rows.Columns())
+	}
+	dest = dest[:2]
+	if err := rows.Next(dest); err != nil || dest[0] != int64(1) || dest[1] != "x" {
+		t.Error("Unexpected row:", dest, err)
+	}
+	rows.Close()
+	if err := rows.Next(dest); err != io.EOF {
+		t.Error("Expected io.EOF after Close, got", err)
+	}
+}
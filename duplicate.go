@@ -0,0 +1,82 @@
+// © 2014 Steve McCoy.
+
+package table
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"strconv"
+)
+
+// A DuplicateRowPolicy says what a Decoder does with a row whose fields
+// are exactly those of a row it has read before. To tell, the Decoder
+// keeps a hash of every distinct row, unless the policy is
+// DuplicateRowDecode.
+type DuplicateRowPolicy int
+
+const (
+	DuplicateRowDecode DuplicateRowPolicy = iota // the row is decoded like any other, and not counted
+	DuplicateRowCount                            // the row is decoded like any other, and counted
+	DuplicateRowSkip                             // the row is counted and skipped, and the next one decoded
+	DuplicateRowReject                           // the row is counted, and a DuplicateRowError is returned
+)
+
+// DuplicateRowError is returned from Decode for a row that duplicates an
+// earlier one when the Decoder's DuplicateRows is DuplicateRowReject.
+type DuplicateRowError struct {
+	First  int // number of the earlier row among those read, as by Decoder.Record
+	Record int // number of the row among those read
+	Line   int // line on which the row begins, if the FieldReader reports it
+}
+
+func (e DuplicateRowError) Error() string {
+	return position(e.Record, e.Line) + "duplicate of record " + strconv.Itoa(e.First)
+}
+
+// MarshalJSON encodes e as an object with the members "record" and
+// "line", each if known, "code", and "message".
+func (e DuplicateRowError) MarshalJSON() ([]byte, error) {
+	return json.Marshal(e.asJSON())
+}
+
+func (e DuplicateRowError) asJSON() errorJSON {
+	return errorJSON{Record: e.Record, Line: e.Line, Code: e.Code(), Message: e.Error()}
+}
+
+// Code returns CodeDuplicateRow.
+func (e DuplicateRowError) Code() Code {
+	return CodeDuplicateRow
+}
+
+// DuplicateRowsSeen returns the number of rows that d has found to
+// duplicate earlier ones, under a DuplicateRows policy other than
+// DuplicateRowDecode.
+func (d *Decoder) DuplicateRowsSeen() int {
+	return d.duplicates
+}
+
+// duplicate checks whether fields, the row just read, duplicates an
+// earlier row, according to d.DuplicateRows. It reports whether the row
+// is to be skipped, or else the error to return for it, if any.
+func (d *Decoder) duplicate(fields []string) (bool, error) {
+	if d.DuplicateRows == DuplicateRowDecode {
+		return false, nil
+	}
+	sum := sumStrings(fields)
+	first, ok := d.seen[sum]
+	if !ok {
+		if d.seen == nil {
+			d.seen = map[[sha256.Size]byte]int{}
+		}
+		d.seen[sum] = d.records
+		return false, nil
+	}
+	d.duplicates++
+	switch d.DuplicateRows {
+	case DuplicateRowSkip:
+		return true, nil
+	case DuplicateRowReject:
+		return false, DuplicateRowError{first, d.records, d.line(0)}
+	}
+	return false, nil
+}
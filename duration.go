@@ -0,0 +1,81 @@
+// © 2014 Steve McCoy.
+
+package table
+
+import (
+	"math"
+	"reflect"
+	"strconv"
+	"time"
+)
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// bareUnit returns the unit given by the bare option of tg, the tag of f,
+// in which cells that are plain integers are taken to be, and whether it
+// gives one. The unit is any of those accepted by time.ParseDuration.
+// It is an error for a field that is not a time.Duration, or whose tag
+// also has a unit option.
+func bareUnit(f reflect.StructField, tg Tag) (string, bool, error) {
+	unit, ok := tg.Lookup("bare")
+	if !ok {
+		return "", false, nil
+	}
+	if f.Type != durationType {
+		return "", false, tagError(f, "bare applies only to time.Duration")
+	}
+	if _, ok := tg.Lookup("unit"); ok {
+		return "", false, tagError(f, "bare and unit cannot both be given")
+	}
+	if _, err := time.ParseDuration("1" + unit); err != nil {
+		return "", false, tagError(f, "unknown duration unit "+unit)
+	}
+	return unit, true, nil
+}
+
+// modDuration returns a function that sets a time.Duration to a cell
+// parsed by time.ParseDuration, such as "5m30s", or, if bare is not "",
+// to a cell that is a plain integer, in the unit bare.
+func modDuration(bare string) func(*reflect.Value, string) error {
+	return func(v *reflect.Value, f string) error {
+		d, err := time.ParseDuration(f)
+		if err != nil && bare != "" {
+			if _, ierr := strconv.ParseInt(f, 10, 64); ierr == nil {
+				d, err = time.ParseDuration(f + bare)
+			}
+		}
+		if err != nil {
+			return err
+		}
+		v.SetInt(int64(d))
+		return nil
+	}
+}
+
+// durationNanos returns the number of nanoseconds in each unit of table,
+// the UnitTable of unit, the unit option of the time.Duration field f.
+// It is an error if table is not a table of units of time.
+func durationNanos(f reflect.StructField, table UnitTable, unit string) (map[string]float64, error) {
+	ns, ok := table["ns"]
+	if !ok {
+		return nil, tagError(f, "unit of a time.Duration must be a unit of time, not "+unit)
+	}
+	nanos := make(map[string]float64, len(table))
+	for sym, size := range table {
+		nanos[sym] = math.Round(size / ns)
+	}
+	return nanos, nil
+}
+
+// formatDurationUnit returns a function that formats a time.Duration as
+// a number of units, each of which is scale nanoseconds.
+func formatDurationUnit(scale float64) func(reflect.Value) (string, error) {
+	return func(v reflect.Value) (string, error) {
+		return strconv.FormatFloat(float64(v.Int())/scale, 'f', -1, 64), nil
+	}
+}
+
+// formatDuration formats a time.Duration as time.Duration.String does.
+func formatDuration(v reflect.Value) (string, error) {
+	return time.Duration(v.Int()).String(), nil
+}
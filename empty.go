@@ -0,0 +1,131 @@
+// © 2014 Steve McCoy.
+
+package table
+
+import (
+	"encoding/json"
+	"reflect"
+)
+
+// An EmptyRowPolicy says what a Decoder does with a row that has no
+// fields, or whose fields are all empty.
+type EmptyRowPolicy int
+
+const (
+	EmptyRowDecode EmptyRowPolicy = iota // the row is decoded like any other
+	EmptyRowSkip                         // the row is skipped, and the next one decoded
+	EmptyRowError                        // ErrEmptyRow is returned
+	EmptyRowZero                         // the destination is set to its zero value
+)
+
+// A MissingCellPolicy says what a Decoder does with a row that is
+// short of the columns it needs, lacking trailing cells.
+type MissingCellPolicy int
+
+const (
+	MissingError MissingCellPolicy = iota // a RowError is returned
+	MissingEmpty                          // missing cells are decoded as empty cells
+	MissingNull                           // missing cells are null, as if one of the Decoder's Nulls
+)
+
+// ErrEmptyRow is returned from Decode for an empty row when the
+// Decoder's EmptyRows is EmptyRowError.
+var ErrEmptyRow error = emptyRowError{}
+
+type emptyRowError struct{}
+
+func (emptyRowError) Error() string {
+	return "empty row"
+}
+
+// MarshalJSON encodes the error as an object with the members
+// "code" and "message".
+func (e emptyRowError) MarshalJSON() ([]byte, error) {
+	return json.Marshal(e.asJSON())
+}
+
+func (e emptyRowError) asJSON() errorJSON {
+	return errorJSON{Code: e.Code(), Message: e.Error()}
+}
+
+// Code returns CodeEmptyRow.
+func (emptyRowError) Code() Code {
+	return CodeEmptyRow
+}
+
+// readRow reads the next row from d's FieldReader, applying
+// d.ControlChars to its cells, and skipping empty rows if d.EmptyRows is
+// EmptyRowSkip, and duplicate rows if d.DuplicateRows is
+// DuplicateRowSkip. It reports whether the row is empty and
+// should be decoded as zero values; for EmptyRowError, the error is
+// ErrEmptyRow.
+func (d *Decoder) readRow() ([]string, bool, error) {
+	d.clamped = nil // left by a row that failed to decode
+	for {
+		fields, err := d.read()
+		if err != nil {
+			return fields, false, err
+		}
+		if err := d.control(fields); err != nil {
+			return fields, false, err
+		}
+		if d.EmptyRows == EmptyRowDecode || !emptyRow(fields) {
+			if skip, err := d.duplicate(fields); skip {
+				continue
+			} else if err != nil {
+				return fields, false, err
+			}
+			return fields, false, nil
+		}
+		switch d.EmptyRows {
+		case EmptyRowSkip:
+			continue
+		case EmptyRowError:
+			return fields, false, ErrEmptyRow
+		}
+		return fields, true, nil
+	}
+}
+
+// emptyRow reports whether fields has no fields, or only empty ones.
+func emptyRow(fields []string) bool {
+	for _, f := range fields {
+		if f != "" {
+			return false
+		}
+	}
+	return true
+}
+
+// zeroMap sets each column of d's Header in m to the zero value of
+// its Kind.
+func (d *Decoder) zeroMap(m map[string]interface{}, names []string) error {
+	for _, name := range names {
+		if name == "" {
+			continue
+		}
+		t, _, err := d.columnKind(name)
+		if err != nil {
+			return err
+		}
+		m[name] = reflect.Zero(t).Interface()
+	}
+	return nil
+}
+
+// fill pads fields with empty cells to n cells, if it is shorter and
+// d.MissingCells allows it. It returns the row and the number of its
+// cells that were read.
+func (d *Decoder) fill(fields []string, n int) ([]string, int) {
+	read := len(fields)
+	if d.MissingCells == MissingError || read >= n {
+		return fields, read
+	}
+	return append(fields[:read:read], make([]string, n-read)...), read
+}
+
+// missing reports whether column col of a row of which read cells were
+// read is null because it is missing.
+func (d *Decoder) missing(col, read int) bool {
+	return d.MissingCells == MissingNull && col >= read
+}
@@ -0,0 +1,621 @@
+// © 2014 Steve McCoy.
+
+package table
+
+import (
+	"maps"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// FieldWriter represents anything that behaves similarly to
+// encoding/csv's Writer type. Any errors encountered
+// by the writer will be immediately returned by Encode.
+// Writers that buffer, like csv.Writer, must still be flushed by the caller.
+type FieldWriter interface {
+	Write(record []string) error
+}
+
+// EncodeError is returned from Encode if a field is of a Kind that
+// does not have an associated function in Format, or if the value
+// to encode is not a struct.
+type EncodeError string
+
+func (e EncodeError) Error() string {
+	return string(e) + " is not encodable"
+}
+
+// Encoder contains a map of functions from reflect.Kinds to
+// functions that should return the text representing a reflect.Value
+// of the associated Kind. It is the inverse of a Decoder: a row written
+// by an Encoder can be read back into the same struct type by a Decoder.
+type Encoder struct {
+	Format map[reflect.Kind]func(reflect.Value) (string, error)
+
+	// Columns is the order in which the keys of maps are written.
+	// If it is nil when the first map is encoded, it is set to
+	// that map's keys, in sorted order.
+	Columns []string
+
+	// TimeLayout, if not empty, is the layout, as for time.Time's Format,
+	// in which time.Time fields are written, instead of RFC 3339.
+	TimeLayout string
+
+	// NumberWidth, if positive, is the number of digits to which the
+	// integer parts of numeric fields are padded with leading zeros,
+	// so that byte-wise tools sort them as numbers, as long as they are
+	// not negative. The pad tag option of a field, as in pad=10, gives
+	// its own width, which may be 0 for none. Decoders read the padded
+	// numbers as they do any others.
+	NumberWidth int
+
+	// EscapeControls makes the control characters in cells, such as
+	// newlines, be written as backslash escapes, such as \n, and
+	// backslashes as \\, so that each row is one line of printable text.
+	// A Decoder whose ControlChars is ControlUnescape reads them back.
+	EscapeControls bool
+
+	// NonFinite says what Encode does with NaN and infinite values of
+	// floating-point fields, which it otherwise writes as
+	// strconv.FormatFloat does, as NaN, +Inf, and -Inf.
+	NonFinite NonFinitePolicy
+
+	w    FieldWriter
+	view []viewColumn // the columns selected by View, if not nil
+}
+
+// NewEncoder returns an Encoder that writes to w and has a default
+// Format map for the same Kinds as a Decoder's default Modify map.
+// The map is the Encoder's own, so that changing it does not affect
+// other Encoders.
+func NewEncoder(w FieldWriter) Encoder {
+	return Encoder{Format: maps.Clone(defaultFormats), w: w}
+}
+
+// Encode writes the exported fields of the struct s, or of the struct
+// that s points to, as a row to e's FieldWriter. Fields are formatted
+// using the functions in e.Format, and are bound to columns as they are
+// by Decode: Raw fields that share the column of their twin are not
+// written, fields with a Combiner are split into several cells, a field
+// bound to the rest of the columns is written as a cell per element, in
+// the order of a map's keys,
+// time.Time fields are written in e.TimeLayout or RFC 3339 format,
+// or in the layout or ISO 8601 variant given by their layout or format
+// tag option, time.Duration fields are written as by their String method,
+// as in 5m30s, and fields whose types
+// implement encoding.TextMarshaler are written by MarshalText, as are
+// big.Int and big.Float fields, with or without pointers; net.IPNet and
+// url.URL fields are written by their String methods. A big.Rat is
+// written as a decimal if it has one that ends, as 0.125, or else as a
+// fraction, as 1/3. A nil pointer is written as an empty cell, as is a
+// sql.NullString or the like that is not Valid.
+// Negative numbers whose fields' tags have the accounting option are
+// written in parentheses, as (1234.56).
+// Numbers whose fields' tags have the percent option are written as
+// percentages, as 12.5%. Bools whose fields' tags give tokens for them,
+// as in bool=yes/no, are written as those tokens. Integers whose fields'
+// tags give a base, as in base=16, are written in it, without a prefix.
+//
+// If s is a map[string]string or map[string]interface{}, its values are
+// written in the order of e.Columns; if s is a Record, in the order of its
+// Columns. Values of interface type are formatted according to their
+// dynamic type. A MissingColumnError is returned for the first map key,
+// in sorted order, not in e.Columns.
+//
+// If e is from View, only the columns of the view are written; see View.
+//
+// If e's FieldWriter is a TypedFieldWriter, it is also given the type of
+// each cell: fields and values of numeric Kinds are NumberCells, those of
+// Kind bool are BoolCells, and nil pointers and interfaces are NullCells.
+//
+// An EncodeError is returned for the first field whose Kind has
+// no entry in e.Format, and a TagError for the first malformed tag.
+func (e *Encoder) Encode(s interface{}) error {
+	switch m := s.(type) {
+	case map[string]string, map[string]interface{}:
+		if e.view != nil {
+			return e.encodeView(mapGetter(reflect.ValueOf(m)))
+		}
+		return e.encodeMap(reflect.ValueOf(m))
+	case Record:
+		return e.encodeRecord(&m)
+	case *Record:
+		return e.encodeRecord(m)
+	}
+
+	v := reflect.ValueOf(s)
+	for v.Kind() == reflect.Ptr && !v.IsNil() {
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.Struct {
+		if !v.IsValid() {
+			return EncodeError("nil")
+		}
+		return EncodeError(v.Type().String())
+	}
+
+	plan, errs := e.plan(v.Type())
+	if len(errs) > 0 {
+		return errs[0]
+	}
+
+	row := make([]string, columns(plan))
+	types := make([]CellType, len(row))
+	for _, f := range plan {
+		if f.twin {
+			continue
+		}
+		fv, err := v.FieldByIndexErr(f.index)
+		if err != nil {
+			// The field is in a nil embedded struct, so it has no value.
+			types[f.column] = NullCell
+			continue
+		}
+		if f.rest {
+			for _, ev := range restValues(fv) {
+				cell, err := f.format(ev)
+				if err != nil {
+					return err
+				}
+				row = append(row, cell)
+				types = append(types, cellType(ev, f.tag))
+			}
+			continue
+		}
+		if f.combiner.Columns > 0 {
+			cells, err := f.combiner.Split(fv)
+			if err != nil {
+				return err
+			}
+			copy(row[f.column:f.column+f.width], cells)
+			continue
+		}
+		cell, err := f.format(fv)
+		if err != nil {
+			return err
+		}
+		row[f.column] = cell
+		if f.sf.Type != rawType {
+			types[f.column] = cellType(fv, f.tag)
+		}
+	}
+	if e.view != nil {
+		var err error
+		if row, err = project(e.view, plan, row); err != nil {
+			return err
+		}
+		types, _ = project(e.view, plan, types)
+	}
+	return e.write(row, types)
+}
+
+// restValues returns the elements of v, the slice or map of a field bound
+// to the rest of the columns. A map's are in the order of their keys.
+func restValues(v reflect.Value) []reflect.Value {
+	if v.Kind() != reflect.Map {
+		vs := make([]reflect.Value, v.Len())
+		for i := range vs {
+			vs[i] = v.Index(i)
+		}
+		return vs
+	}
+	keys := v.MapKeys()
+	sort.Slice(keys, func(i, j int) bool { return keys[i].String() < keys[j].String() })
+	vs := make([]reflect.Value, len(keys))
+	for i, k := range keys {
+		vs[i] = v.MapIndex(k)
+	}
+	return vs
+}
+
+// encodeMap writes the map m, whose keys are strings,
+// in the order of e.Columns.
+func (e *Encoder) encodeMap(m reflect.Value) error {
+	e.setColumns(m)
+
+	row := make([]string, len(e.Columns))
+	types := make([]CellType, len(row))
+	found := 0
+	for i, c := range e.Columns {
+		v := m.MapIndex(reflect.ValueOf(c))
+		if !v.IsValid() {
+			continue
+		}
+		found++
+		cell, err := e.formatAny(v.Interface())
+		if err != nil {
+			return err
+		}
+		row[i] = cell
+		types[i] = cellType(v, Tag{})
+	}
+	if found < m.Len() {
+		keys := m.MapKeys()
+		sort.Slice(keys, func(i, j int) bool { return keys[i].String() < keys[j].String() })
+		for _, k := range keys {
+			if !containsString(e.Columns, k.String()) {
+				return MissingColumnError{k.String(), k.String()}
+			}
+		}
+	}
+	return e.write(row, types)
+}
+
+func containsString(a []string, s string) bool {
+	for _, t := range a {
+		if t == s {
+			return true
+		}
+	}
+	return false
+}
+
+// encodeRecord writes r's values in the order of its columns.
+func (e *Encoder) encodeRecord(r *Record) error {
+	if e.view != nil {
+		return e.encodeView(r.Get)
+	}
+	row := make([]string, len(r.Values))
+	types := make([]CellType, len(row))
+	for i, v := range r.Values {
+		cell, err := e.formatAny(v)
+		if err != nil {
+			return err
+		}
+		row[i] = cell
+		types[i] = cellType(reflect.ValueOf(v), Tag{})
+	}
+	return e.write(row, types)
+}
+
+// formatAny formats x according to its dynamic type.
+func (e *Encoder) formatAny(x interface{}) (string, error) {
+	if x == nil {
+		return "", nil
+	}
+	v := reflect.ValueOf(x)
+	if v.Type() == timeType {
+		return formatTime(v)
+	}
+	fm, ok := e.Format[v.Kind()]
+	if !ok || v.Kind() == reflect.Interface {
+		return "", EncodeError(v.Type().String())
+	}
+	return fm(v)
+}
+
+// setColumns sets e.Columns, if it is nil, to the keys of the map m,
+// in sorted order.
+func (e *Encoder) setColumns(m reflect.Value) {
+	if e.Columns != nil {
+		return
+	}
+	for _, k := range m.MapKeys() {
+		e.Columns = append(e.Columns, k.String())
+	}
+	sort.Strings(e.Columns)
+}
+
+// plan returns the fields of the struct type t that are encoded, in order,
+// along with every problem that would prevent t from being encoded.
+func (e *Encoder) plan(t reflect.Type) ([]field, Errors) {
+	plan, errs := cachedLayout(t, false)
+	for i := range plan {
+		f := &plan[i]
+		if f.twin {
+			continue
+		}
+		if f.combiner.Columns > 0 {
+			if f.combiner.Split == nil {
+				errs = append(errs, tagError(f.sf, "combiner "+f.combinerName()+" cannot split"))
+			}
+			continue
+		}
+		fm, err := e.formatter(f.elem(), f.tag)
+		if err != nil {
+			errs = append(errs, err)
+		}
+		f.format = fm
+	}
+	return plan, errs
+}
+
+// formatter returns the function that encodes values of f, whose
+// `table` tag is tg. This is from e.Format, except for time.Time,
+// time.Duration, and encoding.TextMarshalers, and for Marshalers,
+// which take precedence over all else but the json tag option.
+// A pointer is encoded as what it points to, unless only the pointer is
+// a TextMarshaler, and the value of a sql.NullString or the like as
+// itself. Numbers are padded as e.NumberWidth or the pad tag option says.
+func (e *Encoder) formatter(f reflect.StructField, tg Tag) (func(reflect.Value) (string, error), error) {
+	t := f.Type
+	if _, ok := tg.Lookup("json"); ok {
+		return formatJSON, nil
+	}
+	if t.Kind() != reflect.Interface && t.Implements(marshalerType) {
+		return formatField, nil
+	}
+	if t.Kind() == reflect.Ptr && (isBig(t.Elem()) || isNetwork(t.Elem()) || !(t.Implements(textMarshalerType) && !t.Elem().Implements(textMarshalerType))) {
+		ef := f
+		ef.Type = t.Elem()
+		fm, err := e.formatter(ef, tg)
+		if err != nil {
+			return nil, err
+		}
+		return formatPointer(fm), nil
+	}
+	if isSQLNull(t) {
+		ef := f
+		ef.Type = t.Field(0).Type
+		fm, err := e.formatter(ef, tg)
+		if err != nil {
+			return nil, err
+		}
+		return formatSQLNull(fm), nil
+	}
+	if sp, ef, rest, ok, err := splitOf(f, tg); err != nil {
+		return nil, err
+	} else if ok {
+		fm, err := e.formatter(ef, rest)
+		if err != nil {
+			return nil, err
+		}
+		return formatSplit(fm, sp), nil
+	}
+	if _, ok := tg.Lookup("pad"); ok && (!isNumber(t.Kind()) || t == durationType) {
+		return nil, tagError(f, "pad applies only to numbers")
+	}
+	if tokens, _, ok, err := boolTokens(f, tg); err != nil {
+		return nil, err
+	} else if ok {
+		return formatBool(tokens), nil
+	}
+	if rest, ok, err := accounting(f, tg); err != nil {
+		return nil, err
+	} else if ok {
+		fm, err := e.formatter(f, rest)
+		if err != nil {
+			return nil, err
+		}
+		return formatAccounting(fm), nil
+	}
+	if rest, ok, err := currency(f, tg); err != nil {
+		return nil, err
+	} else if ok {
+		return e.formatter(f, rest)
+	}
+	if l, rest, ok, err := locale(f, tg); err != nil {
+		return nil, err
+	} else if ok {
+		fm, err := e.formatter(f, rest)
+		if err != nil {
+			return nil, err
+		}
+		return func(v reflect.Value) (string, error) {
+			s, err := fm(v)
+			return l.format(s), err
+		}, nil
+	}
+	if rest, whole, ok, err := percent(f, tg); err != nil {
+		return nil, err
+	} else if ok {
+		fm, err := e.formatter(f, rest)
+		if err != nil {
+			return nil, err
+		}
+		return formatPercent(fm, whole), nil
+	}
+	if f.Type == rawType {
+		return formatString, nil
+	}
+	if layout, ok, err := timeLayout(f, tg); err != nil {
+		return nil, err
+	} else if ok {
+		return formatTimeLayout(layout), nil
+	}
+	if format, ok := tg.Lookup("format"); ok {
+		if t != timeType {
+			return nil, tagError(f, "format applies only to time.Time")
+		}
+		fm, ok := timeFormatters[format]
+		if !ok {
+			return nil, tagError(f, "unknown time format "+format)
+		}
+		return fm, nil
+	}
+	if t == timeType && e.TimeLayout != "" {
+		return formatTimeLayout(e.TimeLayout), nil
+	}
+	if t == timeType {
+		return formatTime, nil
+	}
+	if isBig(t) {
+		return formatBig, nil
+	}
+	if isNetwork(t) {
+		return formatNetwork, nil
+	}
+	if _, _, err := bareUnit(f, tg); err != nil {
+		return nil, err
+	}
+	if base, ok, err := intBase(f, tg); err != nil {
+		return nil, err
+	} else if ok {
+		return formatBase(base), nil
+	}
+	if unit, ok := tg.Lookup("unit"); ok && t == durationType {
+		table, ok := unitTable(unit)
+		if !ok {
+			return nil, tagError(f, "unknown unit "+unit)
+		}
+		nanos, err := durationNanos(f, table, unit)
+		if err != nil {
+			return nil, err
+		}
+		return formatDurationUnit(nanos[unit]), nil
+	} else if t == durationType {
+		return formatDuration, nil
+	}
+	if t.Kind() != reflect.Interface && t.Implements(textMarshalerType) {
+		return formatText, nil
+	}
+	if t.Kind() == reflect.Interface && t.NumMethod() != 0 {
+		return nil, EncodeError(t.String())
+	}
+	fm, ok := e.Format[t.Kind()]
+	if !ok {
+		return nil, EncodeError(t.Kind().String())
+	}
+	if !isNumber(t.Kind()) {
+		return fm, nil
+	}
+	if e.NonFinite != NonFiniteAccept && isFloat(t.Kind()) {
+		fm = formatNonFinite(fm, e.NonFinite)
+	}
+	width := e.NumberWidth
+	if s, ok := tg.Lookup("pad"); ok {
+		w, err := strconv.Atoi(s)
+		if err != nil || w < 0 {
+			return nil, tagError(f, "bad pad width "+s)
+		}
+		width = w
+	}
+	if width > 0 {
+		return formatPadded(fm, width), nil
+	}
+	return fm, nil
+}
+
+// formatPadded returns a function that formats numbers with fm, and pads
+// their integer parts with leading zeros to at least width digits, after
+// any sign, so that the text of non-negative numbers sorts as they do.
+func formatPadded(fm func(reflect.Value) (string, error), width int) func(reflect.Value) (string, error) {
+	return func(v reflect.Value) (string, error) {
+		s, err := fm(v)
+		if err != nil {
+			return "", err
+		}
+		sign := ""
+		if strings.HasPrefix(s, "-") || strings.HasPrefix(s, "+") {
+			sign, s = s[:1], s[1:]
+		}
+		digits := strings.IndexFunc(s, func(r rune) bool { return r < '0' || r > '9' })
+		if digits < 0 {
+			digits = len(s)
+		}
+		if digits < width {
+			s = strings.Repeat("0", width-digits) + s
+		}
+		return sign + s, nil
+	}
+}
+
+// isNumber reports whether k is an integer or floating-point Kind.
+func isNumber(k reflect.Kind) bool {
+	return isInteger(k) || k == reflect.Float32 || k == reflect.Float64
+}
+
+// formatPointer returns a function that formats a nil pointer as an
+// empty cell, and otherwise what it points to with fm.
+func formatPointer(fm func(reflect.Value) (string, error)) func(reflect.Value) (string, error) {
+	return func(v reflect.Value) (string, error) {
+		if v.IsNil() {
+			return "", nil
+		}
+		return fm(v.Elem())
+	}
+}
+
+func formatString(v reflect.Value) (string, error) {
+	return v.String(), nil
+}
+
+func formatTime(v reflect.Value) (string, error) {
+	return v.Interface().(time.Time).Format(time.RFC3339Nano), nil
+}
+
+// formatTimeLayout returns a function that formats time.Time values
+// in layout.
+func formatTimeLayout(layout string) func(reflect.Value) (string, error) {
+	return func(v reflect.Value) (string, error) {
+		return v.Interface().(time.Time).Format(layout), nil
+	}
+}
+
+// formatInterface formats the value held by an empty interface using
+// the default format for its dynamic type. A pointer is formatted as what
+// it points to, and a nil one as an empty cell. Values of other types are
+// formatted by MarshalText, if they have it, or else are an EncodeError,
+// so that no cell depends on where a value is in memory.
+func formatInterface(v reflect.Value) (string, error) {
+	if v.IsNil() {
+		return "", nil
+	}
+	v = v.Elem()
+	var p reflect.Value // the last pointer to v, if any
+	for v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return "", nil
+		}
+		p, v = v, v.Elem()
+	}
+	if v.Type() == timeType {
+		return formatTime(v)
+	}
+	if fm, ok := defaultFormats[v.Kind()]; ok && v.Kind() != reflect.Interface {
+		return fm(v)
+	}
+	if v.Type().Implements(textMarshalerType) {
+		return formatText(v)
+	}
+	if p.IsValid() && p.Type().Implements(textMarshalerType) {
+		return formatText(p)
+	}
+	return "", EncodeError(v.Type().String())
+}
+
+var defaultFormats = map[reflect.Kind]func(reflect.Value) (string, error){
+	reflect.Bool: func(v reflect.Value) (string, error) {
+		return strconv.FormatBool(v.Bool()), nil
+	},
+	reflect.Int:    formatInt,
+	reflect.Int8:   formatInt,
+	reflect.Int16:  formatInt,
+	reflect.Int32:  formatInt,
+	reflect.Int64:  formatInt,
+	reflect.Uint:   formatUint,
+	reflect.Uint8:  formatUint,
+	reflect.Uint16: formatUint,
+	reflect.Uint32: formatUint,
+	reflect.Uint64: formatUint,
+	reflect.Float32: func(v reflect.Value) (string, error) {
+		return strconv.FormatFloat(v.Float(), 'g', -1, 32), nil
+	},
+	reflect.Float64: func(v reflect.Value) (string, error) {
+		return strconv.FormatFloat(v.Float(), 'g', -1, 64), nil
+	},
+	reflect.Complex64: func(v reflect.Value) (string, error) {
+		return strconv.FormatComplex(v.Complex(), 'g', -1, 64), nil
+	},
+	reflect.Complex128: func(v reflect.Value) (string, error) {
+		return strconv.FormatComplex(v.Complex(), 'g', -1, 128), nil
+	},
+	reflect.String: formatString,
+}
+
+func init() {
+	// formatInterface refers to defaultFormats, so it can't be
+	// in the map's initializer.
+	defaultFormats[reflect.Interface] = formatInterface
+}
+
+func formatInt(v reflect.Value) (string, error) {
+	return strconv.FormatInt(v.Int(), 10), nil
+}
+
+func formatUint(v reflect.Value) (string, error) {
+	return strconv.FormatUint(v.Uint(), 10), nil
+}
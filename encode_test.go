@@ -0,0 +1,907 @@
+// © 2014 Steve McCoy.
+
+package table
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"math/big"
+	"net"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func ExampleEncoder_Encode() {
+	type X struct {
+		A int
+		B string
+		c int
+	}
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	enc := NewEncoder(w)
+	for _, x := range []X{{1, "blonde", 0}, {2, "on", 0}} {
+		if err := enc.Encode(x); err != nil {
+			fmt.Println("oops:", err)
+			return
+		}
+	}
+	w.Flush()
+	fmt.Print(buf.String())
+
+	// output: 1,blonde
+	// 2,on
+}
+
+func TestEncodeRoundTrip(t *testing.T) {
+	type X struct {
+		A    int
+		ARaw Raw
+		B    string
+		C    uint8
+		D    float32
+		E    bool
+		F    interface{}
+		G    time.Time
+		H    time.Time `table:",format=isoweek"`
+		I    time.Time `table:",combine=datetime"`
+		J    float64   `table:",unit=km"`
+	}
+	x := X{
+		A: -1,
+		B: "meow, meow",
+		C: 2,
+		D: 11.1,
+		E: true,
+		F: int64(7),
+		G: time.Date(2014, 3, 1, 10, 11, 12, 13, time.UTC),
+		H: time.Date(2023, 1, 30, 0, 0, 0, 0, time.UTC),
+		I: time.Date(2014, 3, 1, 10, 11, 12, 0, time.UTC),
+		J: 1.5,
+	}
+
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	enc := NewEncoder(w)
+	if err := enc.Encode(&x); err != nil {
+		t.Fatal("Expected no error, got", err)
+	}
+	w.Flush()
+
+	want := `-1,"meow, meow",2,11.1,true,7,2014-03-01T10:11:12.000000013Z,2023-W05-1,2014-03-01,10:11:12,1.5` + "\n"
+	if buf.String() != want {
+		t.Errorf("Expected %q, got %q", want, buf.String())
+	}
+
+	dec := NewDecoder(csv.NewReader(strings.NewReader(buf.String())), WithStrict())
+	var y X
+	if err := dec.Decode(&y); err != nil {
+		t.Fatal("Expected no error, got", err)
+	}
+	x.ARaw = "-1"
+	if !reflect.DeepEqual(x, y) {
+		t.Errorf("Expected %+v, got %+v", x, y)
+	}
+}
+
+func TestEncodeError(t *testing.T) {
+	type X struct {
+		A int
+		B uintptr
+	}
+	enc := NewEncoder(csv.NewWriter(&bytes.Buffer{}))
+	if err := enc.Encode(X{}); err != EncodeError("uintptr") {
+		t.Error("Expected an EncodeError for uintptr, got", err)
+	}
+	if err := enc.Encode(7); err != EncodeError("int") {
+		t.Error("Expected an EncodeError for int, got", err)
+	}
+	if err := enc.Encode(nil); err != EncodeError("nil") {
+		t.Error("Expected an EncodeError for nil, got", err)
+	}
+}
+
+func TestEncodeDeterministic(t *testing.T) {
+	type X struct {
+		ID    int
+		Tags  map[string]int    `table:",split=;"`
+		Extra map[string]string `table:",rest"`
+	}
+	x := X{ID: 1, Tags: map[string]int{}, Extra: map[string]string{}}
+	for i := 0; i < 20; i++ {
+		x.Tags[fmt.Sprint("t", i)] = i
+		x.Extra[fmt.Sprint("e", i)] = fmt.Sprint(i)
+	}
+	m := map[string]interface{}{}
+	for i := 0; i < 20; i++ {
+		m[fmt.Sprint("c", i)] = i
+	}
+	encode := func() string {
+		var buf bytes.Buffer
+		w := csv.NewWriter(&buf)
+		enc := NewEncoder(w)
+		if err := enc.Encode(x); err != nil {
+			t.Fatal("Expected no error, got", err)
+		}
+		if err := enc.Encode(m); err != nil {
+			t.Fatal("Expected no error, got", err)
+		}
+		w.Flush()
+		return buf.String()
+	}
+	first := encode()
+	if !strings.HasPrefix(first, "1,t0=0;t1=1;t10=10;t11=11;") || !strings.Contains(first, ",0,1,10,11,") {
+		t.Errorf("Expected sorted keys, got %q", first)
+	}
+	for i := 0; i < 10; i++ {
+		if s := encode(); s != first {
+			t.Fatalf("Expected %q every time, got %q", first, s)
+		}
+	}
+
+	enc := NewEncoder(csv.NewWriter(&bytes.Buffer{}))
+	enc.Columns = []string{"a"}
+	for i := 0; i < 10; i++ {
+		err := enc.Encode(map[string]string{"z": "", "y": "", "x": "", "a": ""})
+		if err != (MissingColumnError{"x", "x"}) {
+			t.Fatal("Expected a MissingColumnError for x, got", err)
+		}
+	}
+}
+
+func TestEncodeInterfacePointers(t *testing.T) {
+	type X struct {
+		A, B, C, D interface{}
+	}
+	n := 42
+	var nilInt *int
+	pn := &n
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	enc := NewEncoder(w)
+	if err := enc.Encode(X{&n, nilInt, &pn, big.NewInt(7)}); err != nil {
+		t.Fatal("Expected no error, got", err)
+	}
+	w.Flush()
+	if buf.String() != "42,,42,7\n" {
+		t.Errorf("Expected the pointed-to values, got %q", buf.String())
+	}
+
+	type S struct{ N int }
+	if err := enc.Encode(X{A: &S{1}}); err != EncodeError("table.S") {
+		t.Error("Expected an EncodeError for table.S, got", err)
+	}
+}
+
+func TestEncodeMapsAndRecords(t *testing.T) {
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	enc := NewEncoder(w)
+	if err := enc.Encode(map[string]interface{}{"name": "blonde", "age": 3, "cool": true}); err != nil {
+		t.Fatal("Expected no error, got", err)
+	}
+	if err := enc.Encode(map[string]string{"name": "on", "cool": "false"}); err != nil {
+		t.Fatal("Expected no error, got", err)
+	}
+	if err := enc.Encode(map[string]string{"name": "on", "colour": "red"}); err != (MissingColumnError{"colour", "colour"}) {
+		t.Error("Expected a MissingColumnError for colour, got", err)
+	}
+	if err := enc.Encode(map[string]interface{}{"name": uintptr(1)}); err != EncodeError("uintptr") {
+		t.Error("Expected an EncodeError for uintptr, got", err)
+	}
+	rec := Record{}
+	rec.Set("when", time.Date(2014, 3, 1, 0, 0, 0, 0, time.UTC))
+	rec.Set("n", 7.5)
+	if err := enc.Encode(rec); err != nil {
+		t.Fatal("Expected no error, got", err)
+	}
+	w.Flush()
+
+	want := "3,true,blonde\n,false,on\n2014-03-01T00:00:00Z,7.5\n"
+	if buf.String() != want {
+		t.Errorf("Expected %q, got %q", want, buf.String())
+	}
+	if !reflect.DeepEqual(enc.Columns, []string{"age", "cool", "name"}) {
+		t.Error("Unexpected Columns:", enc.Columns)
+	}
+}
+
+func TestDecodeRecord(t *testing.T) {
+	lines := `name,age
+blonde,3
+`
+	dec := NewDecoder(csv.NewReader(strings.NewReader(lines)), WithKinds(map[string]reflect.Kind{"age": reflect.Int}))
+	var r Record
+	if err := dec.Decode(&r); err != nil {
+		t.Fatal("Expected no error, got", err)
+	}
+	want := Record{[]string{"name", "age"}, []interface{}{"blonde", 3}}
+	if !reflect.DeepEqual(r, want) {
+		t.Errorf("Expected %v, got %v", want, r)
+	}
+	if v, ok := r.Get("age"); !ok || v != 3 {
+		t.Error("Expected age to be 3, got", v, ok)
+	}
+}
+
+func TestEncodeIndexTag(t *testing.T) {
+	type X struct {
+		A string `table:"2"`
+		B int
+		C string `table:"index=0"`
+	}
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	enc := NewEncoder(w)
+	if err := enc.Encode(X{A: "c", B: 4, C: "a"}); err != nil {
+		t.Fatal("Expected no error, got", err)
+	}
+	w.Flush()
+	if buf.String() != "a,,c,4\n" {
+		t.Errorf("Expected %q, got %q", "a,,c,4\n", buf.String())
+	}
+}
+
+func TestEncodeView(t *testing.T) {
+	type X struct {
+		Name string
+		Age  int `table:"age"`
+		Cool bool
+		When time.Time `table:",combine=datetime"`
+	}
+
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	enc := NewEncoder(w)
+	view := enc.View("age", "name=Customer", "When")
+	if err := view.WriteHeader(); err != nil {
+		t.Fatal("Expected no error, got", err)
+	}
+	if err := view.Encode(X{"blonde", 3, true, time.Date(2014, 3, 1, 10, 11, 12, 0, time.UTC)}); err != nil {
+		t.Fatal("Expected no error, got", err)
+	}
+	if err := view.Encode(map[string]interface{}{"age": 4, "colour": "red"}); err != nil {
+		t.Fatal("Expected no error, got", err)
+	}
+	nope := enc.View("Nope")
+	if err := nope.Encode(X{}); err != (MissingColumnError{"Nope", "Nope"}) {
+		t.Error("Expected a MissingColumnError for Nope, got", err)
+	}
+	if err := enc.Encode(X{"on", 2, false, time.Date(2014, 3, 2, 0, 0, 0, 0, time.UTC)}); err != nil {
+		t.Fatal("Expected no error, got", err)
+	}
+	w.Flush()
+
+	want := "age,Customer,When\n3,blonde,2014-03-01,10:11:12\n4,,\non,2,false,2014-03-02,00:00:00\n"
+	if buf.String() != want {
+		t.Errorf("Expected %q, got %q", want, buf.String())
+	}
+}
+
+func TestEncodeTextMarshaler(t *testing.T) {
+	type X struct {
+		IP    net.IP
+		N     *big.Int
+		Level level
+	}
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	enc := NewEncoder(w)
+	in := X{net.IPv4(10, 0, 0, 1), big.NewInt(42), 1}
+	if err := enc.Encode(in); err != nil {
+		t.Fatal("Expected no error, got", err)
+	}
+	if err := enc.Encode(X{}); err != nil {
+		t.Fatal("Expected no error, got", err)
+	}
+	w.Flush()
+	if buf.String() != "10.0.0.1,42,mid\n,,low\n" {
+		t.Errorf("Unexpected output: %q", buf.String())
+	}
+
+	dec := NewDecoder(csv.NewReader(strings.NewReader(buf.String())))
+	var out X
+	if err := dec.Decode(&out); err != nil {
+		t.Fatal("Expected no error, got", err)
+	}
+	if !out.IP.Equal(in.IP) || out.N.Cmp(in.N) != 0 || out.Level != in.Level {
+		t.Error("Expected", in, "got", out)
+	}
+}
+
+func TestJSONArrayWriter(t *testing.T) {
+	type X struct {
+		A int
+		B string
+		C bool
+		D *float64
+		E net.IP
+		F float64
+	}
+
+	var buf bytes.Buffer
+	enc := NewEncoder(NewJSONArrayWriter(&buf))
+	d := 1.5
+	if err := enc.Encode(X{1, "blonde", true, &d, net.IPv4(10, 0, 0, 1), 0}); err != nil {
+		t.Fatal("Expected no error, got", err)
+	}
+	if err := enc.Encode(X{2, `"on" <b>`, false, nil, nil, 2.5}); err != nil {
+		t.Fatal("Expected no error, got", err)
+	}
+	if err := enc.Encode(Record{Values: []interface{}{3, "x", nil}}); err != nil {
+		t.Fatal("Expected no error, got", err)
+	}
+	view := enc.View("B", "A")
+	if err := view.Encode(X{A: 4, B: "y"}); err != nil {
+		t.Fatal("Expected no error, got", err)
+	}
+
+	want := `[1,"blonde",true,1.5,"10.0.0.1",0]
+[2,"\"on\" <b>",false,null,"",2.5]
+[3,"x",null]
+["y",4]
+`
+	if buf.String() != want {
+		t.Errorf("Expected %q, got %q", want, buf.String())
+	}
+}
+
+type closeCounter struct{ n *int }
+
+func (c closeCounter) Close() error {
+	*c.n++
+	return nil
+}
+
+func TestPartitionEncoder(t *testing.T) {
+	type X struct {
+		Name    string
+		Country string
+	}
+	bufs := map[string]*bytes.Buffer{}
+	closed := 0
+	open := func(key string) (FieldWriter, io.Closer, error) {
+		bufs[key] = &bytes.Buffer{}
+		return csv.NewWriter(bufs[key]), closeCounter{&closed}, nil
+	}
+	key := func(v interface{}) (string, error) {
+		return v.(X).Country, nil
+	}
+	enc := NewEncoder(nil)
+	p := NewPartitionEncoder(enc.View("Name"), key, open)
+	p.Headers = true
+	for _, x := range []X{{"blonde", "ca"}, {"on", "us"}, {"red", "ca"}} {
+		if err := p.Encode(x); err != nil {
+			t.Fatal("Expected no error, got", err)
+		}
+	}
+	if !reflect.DeepEqual(p.Keys(), []string{"ca", "us"}) {
+		t.Error("Unexpected keys:", p.Keys())
+	}
+	if err := p.Close(); err != nil || closed != 2 {
+		t.Error("Expected two partitions to be closed, got", closed, err)
+	}
+	if bufs["ca"].String() != "Name\nblonde\nred\n" || bufs["us"].String() != "Name\non\n" {
+		t.Errorf("Unexpected partitions: %q, %q", bufs["ca"], bufs["us"])
+	}
+
+	p = NewPartitionEncoder(NewEncoder(nil), func(v interface{}) (string, error) {
+		return v.(map[string]interface{})["day"].(string), nil
+	}, open)
+	p.Headers = true
+	if err := p.Encode(map[string]interface{}{"day": "mon", "n": 1}); err != nil {
+		t.Fatal("Expected no error, got", err)
+	}
+	if err := p.Flush(); err != nil {
+		t.Fatal("Expected no error, got", err)
+	}
+	if bufs["mon"].String() != "day,n\nmon,1\n" {
+		t.Errorf("Unexpected partition: %q", bufs["mon"])
+	}
+
+	type Sale struct {
+		Country string `table:"country"`
+		N       int
+		Raw     Raw
+	}
+	p = NewPartitionEncoder(NewEncoder(nil), func(v interface{}) (string, error) {
+		return v.(*Sale).Country, nil
+	}, open)
+	p.Headers = true
+	if err := p.Encode(&Sale{"de", 1, ""}); err != nil {
+		t.Fatal("Expected no error, got", err)
+	}
+	if err := p.Flush(); err != nil {
+		t.Fatal("Expected no error, got", err)
+	}
+	if bufs["de"].String() != "country,N\nde,1\n" {
+		t.Errorf("Unexpected partition: %q", bufs["de"])
+	}
+}
+
+func TestAsyncEncoder(t *testing.T) {
+	type X struct {
+		A int
+		B string
+	}
+	var buf bytes.Buffer
+	a := NewAsyncEncoder(NewEncoder(csv.NewWriter(&buf)), 2)
+	for i := 0; i < 5; i++ {
+		if err := a.Encode(X{i, "on"}); err != nil {
+			t.Fatal("Expected no error, got", err)
+		}
+	}
+	if err := a.Flush(); err != nil {
+		t.Fatal("Expected no error, got", err)
+	}
+	if buf.String() != "0,on\n1,on\n2,on\n3,on\n4,on\n" {
+		t.Errorf("Unexpected output: %q", buf.String())
+	}
+	if err := a.Close(); err != nil {
+		t.Error("Expected no error, got", err)
+	}
+	if err := a.Encode(X{}); err != ErrClosed {
+		t.Error("Expected ErrClosed after Close, got", err)
+	}
+
+	a = NewAsyncEncoder(NewEncoder(csv.NewWriter(&buf)), 2)
+	a.Encode(uintptr(1))
+	if err := a.Flush(); err != EncodeError("uintptr") {
+		t.Error("Expected an EncodeError from Flush, got", err)
+	}
+	if err := a.Encode(X{}); err != EncodeError("uintptr") {
+		t.Error("Expected the EncodeError from Encode, got", err)
+	}
+	if err := a.Close(); err != EncodeError("uintptr") {
+		t.Error("Expected the EncodeError from Close, got", err)
+	}
+}
+
+func TestEncodeRest(t *testing.T) {
+	type X struct {
+		Site string
+		Obs  []float64 `table:",rest"`
+	}
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	enc := NewEncoder(w)
+	for _, x := range []X{{"a", []float64{2.5, 3}}, {"b", nil}} {
+		if err := enc.Encode(x); err != nil {
+			t.Fatal("Expected no error, got", err)
+		}
+	}
+	w.Flush()
+	if buf.String() != "a,2.5,3\nb\n" {
+		t.Errorf("Unexpected output: %q", buf.String())
+	}
+
+	buf.Reset()
+	type M struct {
+		Site  string
+		Extra map[string]int `table:",rest"`
+	}
+	if err := enc.Encode(M{"a", map[string]int{"z": 1, "b": 2}}); err != nil {
+		t.Fatal("Expected no error, got", err)
+	}
+	w.Flush()
+	if buf.String() != "a,2,1\n" {
+		t.Errorf("Unexpected map output: %q", buf.String())
+	}
+
+	buf.Reset()
+	view := enc.View("Obs", "Site")
+	if err := view.Encode(X{"a", []float64{1, 2}}); err != nil {
+		t.Fatal("Expected no error, got", err)
+	}
+	w.Flush()
+	if buf.String() != "1,2,a\n" {
+		t.Errorf("Unexpected view output: %q", buf.String())
+	}
+}
+
+func TestEncodeSplit(t *testing.T) {
+	type X struct {
+		Tags  []string `table:",split=;"`
+		Sizes []int    `table:",split=\\,"`
+	}
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	enc := NewEncoder(w)
+	if err := enc.Encode(X{[]string{"a", "b"}, []int{1, 2}}); err != nil {
+		t.Fatal("Expected no error, got", err)
+	}
+	w.Flush()
+	if buf.String() != "a;b,\"1,2\"\n" {
+		t.Errorf("Unexpected output: %q", buf.String())
+	}
+
+	buf.Reset()
+	type M struct {
+		Counts map[string]int `table:",split=&,kv=:"`
+	}
+	if err := enc.Encode(M{map[string]int{"n": 2, "m": 3}}); err != nil {
+		t.Fatal("Expected no error, got", err)
+	}
+	w.Flush()
+	if buf.String() != "m:3&n:2\n" {
+		t.Errorf("Unexpected map output: %q", buf.String())
+	}
+}
+
+func TestEncodeJSON(t *testing.T) {
+	type X struct {
+		Payload map[string]string `table:",json"`
+		Tags    []string          `table:",json"`
+	}
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	enc := NewEncoder(w)
+	if err := enc.Encode(X{map[string]string{"a": "<b>"}, nil}); err != nil {
+		t.Fatal("Expected no error, got", err)
+	}
+	w.Flush()
+	if buf.String() != "\"{\"\"a\"\":\"\"<b>\"\"}\",\n" {
+		t.Errorf("Unexpected output: %q", buf.String())
+	}
+}
+
+func TestEncodeNumberWidth(t *testing.T) {
+	type X struct {
+		ID    int
+		Price float64
+		Delta int
+		Day   uint8 `table:",pad=2"`
+		N     int   `table:",pad=0"`
+		Name  string
+	}
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	enc := NewEncoder(w)
+	enc.NumberWidth = 4
+	x := X{42, 3.25, -7, 5, 12, "a"}
+	if err := enc.Encode(x); err != nil {
+		t.Fatal("Expected no error, got", err)
+	}
+	w.Flush()
+	if buf.String() != "0042,0003.25,-0007,05,12,a\n" {
+		t.Errorf("Unexpected output: %q", buf.String())
+	}
+
+	dec := NewDecoder(csv.NewReader(&buf), WithStrict())
+	var y X
+	if err := dec.Decode(&y); err != nil || y != x {
+		t.Errorf("Expected %+v back, got %+v, %v", x, y, err)
+	}
+
+	type Bad struct {
+		S string `table:",pad=3"`
+	}
+	if err := enc.Encode(Bad{}); ErrorCode(err) != CodeInvalidTag {
+		t.Error("Expected a TagError for pad on a string, got", err)
+	}
+}
+
+func TestDuration(t *testing.T) {
+	type X struct {
+		Wait  time.Duration
+		Retry time.Duration `table:",bare=s"`
+		Limit *time.Duration
+	}
+	in := "5m30s,90,\n1h,1.5s,250ms\n"
+	dec := NewDecoder(csv.NewReader(strings.NewReader(in)))
+	var got []X
+	for {
+		var x X
+		err := dec.Decode(&x)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal("Expected no error, got", err)
+		}
+		got = append(got, x)
+	}
+	if len(got) != 2 || got[0].Wait != 5*time.Minute+30*time.Second || got[0].Retry != 90*time.Second || got[0].Limit != nil {
+		t.Fatalf("Unexpected first row: %+v", got)
+	}
+	if got[1].Wait != time.Hour || got[1].Retry != 1500*time.Millisecond || got[1].Limit == nil || *got[1].Limit != 250*time.Millisecond {
+		t.Errorf("Unexpected second row: %+v", got[1])
+	}
+
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	enc := NewEncoder(w)
+	enc.NumberWidth = 4
+	for _, x := range got {
+		if err := enc.Encode(x); err != nil {
+			t.Fatal("Expected no error, got", err)
+		}
+	}
+	w.Flush()
+	if buf.String() != "5m30s,1m30s,\n1h0m0s,1.5s,250ms\n" {
+		t.Errorf("Unexpected output: %q", buf.String())
+	}
+
+	dec = NewDecoder(csv.NewReader(strings.NewReader("90,90,\n")), WithStrict())
+	var x X
+	if err := dec.Decode(&x); err == nil {
+		t.Error("Expected an error for a bare integer without the bare option, got", x)
+	}
+
+	type Bad struct {
+		N int `table:",bare=s"`
+	}
+	dec = NewDecoder(csv.NewReader(strings.NewReader("1\n")))
+	if err := dec.Decode(&Bad{}); ErrorCode(err) != CodeInvalidTag {
+		t.Error("Expected a TagError for bare on an int, got", err)
+	}
+	type BadUnit struct {
+		D time.Duration `table:",bare=fortnight"`
+	}
+	dec = NewDecoder(csv.NewReader(strings.NewReader("1\n")))
+	if err := dec.Decode(&BadUnit{}); ErrorCode(err) != CodeInvalidTag {
+		t.Error("Expected a TagError for an unknown unit, got", err)
+	}
+}
+
+func TestBig(t *testing.T) {
+	type X struct {
+		I *big.Int
+		F *big.Float
+		R *big.Rat
+		V big.Rat
+	}
+	in := "123456789012345678901234567890,12345678901234567890.123456789,0.10,1/3\n,,,-5/4\n"
+	dec := NewDecoder(csv.NewReader(strings.NewReader(in)), WithStrict())
+	var xs []X
+	for {
+		var x X
+		err := dec.Decode(&x)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal("Expected no error, got", err)
+		}
+		xs = append(xs, x)
+	}
+	if len(xs) != 2 {
+		t.Fatal("Expected 2 rows, got", len(xs))
+	}
+	x := xs[0]
+	if x.I.String() != "123456789012345678901234567890" || x.F.Text('f', 9) != "12345678901234567890.123456789" ||
+		x.R.Cmp(big.NewRat(1, 10)) != 0 || x.V.Cmp(big.NewRat(1, 3)) != 0 {
+		t.Errorf("Unexpected first row: %v %v %v %v", x.I, x.F, x.R, &x.V)
+	}
+	if y := xs[1]; y.I != nil || y.F != nil || y.R != nil || y.V.Cmp(big.NewRat(-5, 4)) != 0 {
+		t.Errorf("Unexpected second row: %+v", y)
+	}
+
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	enc := NewEncoder(w)
+	for _, x := range xs {
+		if err := enc.Encode(x); err != nil {
+			t.Fatal("Expected no error, got", err)
+		}
+	}
+	w.Flush()
+	if buf.String() != "123456789012345678901234567890,1.2345678901234567890123456789e+19,0.1,1/3\n,,,-1.25\n" {
+		t.Errorf("Unexpected output: %q", buf.String())
+	}
+}
+
+func TestSQLNull(t *testing.T) {
+	type X struct {
+		S sql.NullString
+		N sql.NullInt64
+		F sql.NullFloat64
+		B sql.NullBool
+		T sql.NullTime `table:",layout=2006-01-02"`
+		G sql.Null[int16]
+	}
+	in := "a,1,2.5,true,2023-02-01,7\n,,,,,\nb,NULL,3,false,,-1\n"
+	dec := NewDecoder(csv.NewReader(strings.NewReader(in)), WithStrict(), WithNulls("NULL"))
+	var xs []X
+	for {
+		var x X
+		err := dec.Decode(&x)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal("Expected no error, got", err)
+		}
+		xs = append(xs, x)
+	}
+	want := []X{
+		{
+			sql.NullString{String: "a", Valid: true},
+			sql.NullInt64{Int64: 1, Valid: true},
+			sql.NullFloat64{Float64: 2.5, Valid: true},
+			sql.NullBool{Bool: true, Valid: true},
+			sql.NullTime{Time: time.Date(2023, 2, 1, 0, 0, 0, 0, time.UTC), Valid: true},
+			sql.Null[int16]{V: 7, Valid: true},
+		},
+		{},
+		{
+			S: sql.NullString{String: "b", Valid: true},
+			F: sql.NullFloat64{Float64: 3, Valid: true},
+			B: sql.NullBool{Bool: false, Valid: true},
+			G: sql.Null[int16]{V: -1, Valid: true},
+		},
+	}
+	if !reflect.DeepEqual(xs, want) {
+		t.Errorf("Expected %+v, got %+v", want, xs)
+	}
+
+	dec = NewDecoder(csv.NewReader(strings.NewReader(",x,,,,\n")), WithStrict())
+	var x X
+	if err := dec.Decode(&x); ErrorCode(err) != CodeParseFailure {
+		t.Error("Expected a FieldError for N, got", err)
+	}
+
+	dec = NewDecoder(csv.NewReader(strings.NewReader(",,,,,\n")), WithEmptyStrings())
+	if err := dec.Decode(&x); err != nil || x.S != (sql.NullString{Valid: true}) || x.N.Valid {
+		t.Error("Expected a valid empty string, got", x, err)
+	}
+
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	enc := NewEncoder(w)
+	for _, x := range want {
+		if err := enc.Encode(x); err != nil {
+			t.Fatal("Expected no error, got", err)
+		}
+	}
+	w.Flush()
+	if buf.String() != "a,1,2.5,true,2023-02-01,7\n,,,,,\nb,,3,false,,-1\n" {
+		t.Errorf("Unexpected output: %q", buf.String())
+	}
+}
+
+func TestControlChars(t *testing.T) {
+	type X struct {
+		ID   int
+		Note string
+	}
+	xs := []X{{1, "one\ntwo"}, {2, "tab\there, bell\a, next\u0085, C:\\dir"}, {3, "plain"}}
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	enc := NewEncoder(w)
+	enc.EscapeControls = true
+	for _, x := range xs {
+		if err := enc.Encode(x); err != nil {
+			t.Fatal("Expected no error, got", err)
+		}
+	}
+	w.Flush()
+	want := "1,one\\ntwo\n2,\"tab\\there, bell\\a, next\\u0085, C:\\\\dir\"\n3,plain\n"
+	if buf.String() != want {
+		t.Errorf("Expected %q, got %q", want, buf.String())
+	}
+
+	dec := NewDecoder(csv.NewReader(strings.NewReader(buf.String())), WithStrict(), WithControlChars(ControlUnescape))
+	for _, x := range xs {
+		var y X
+		if err := dec.Decode(&y); err != nil || y != x {
+			t.Errorf("Expected %+v, got %+v, %v", x, y, err)
+		}
+	}
+
+	in := "1,\"one\ntwo\"\n2,\"a\tb\"\n3,c\n"
+	tests := []struct {
+		policy ControlPolicy
+		notes  []string
+		err    Code
+	}{
+		{ControlAccept, []string{"one\ntwo", "a\tb", "c"}, ""},
+		{ControlStrip, []string{"onetwo", "ab", "c"}, ""},
+		{ControlReject, nil, CodeControlChar},
+	}
+	for _, test := range tests {
+		dec := NewDecoder(csv.NewReader(strings.NewReader(in)), WithControlChars(test.policy))
+		var notes []string
+		for {
+			var x X
+			err := dec.Decode(&x)
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				if ErrorCode(err) != test.err {
+					t.Errorf("%d: Unexpected error: %v", test.policy, err)
+				}
+				break
+			}
+			notes = append(notes, x.Note)
+		}
+		if !reflect.DeepEqual(notes, test.notes) {
+			t.Errorf("%d: Expected %q, got %q", test.policy, test.notes, notes)
+		}
+	}
+}
+
+func TestEncodeEmbedded(t *testing.T) {
+	type Meta struct {
+		ID   int
+		Note string
+	}
+	type X struct {
+		Meta
+		A bool
+	}
+	type Y struct {
+		*Meta
+		A bool
+	}
+
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	enc := NewEncoder(w)
+	if err := enc.Encode(X{Meta{1, "blonde"}, true}); err != nil {
+		t.Fatal("Expected no error, got", err)
+	}
+	if err := enc.Encode(Y{nil, false}); err != nil {
+		t.Fatal("Expected no error, got", err)
+	}
+	w.Flush()
+	if buf.String() != "1,blonde,true\n,,false\n" {
+		t.Errorf("Unexpected output: %q", buf.String())
+	}
+}
+
+func TestNonFinite(t *testing.T) {
+	type X struct {
+		A float64
+		B *float32
+		C int
+	}
+	lines := "NaN,-Inf,1\n2.5,3,4\n"
+	dec := NewDecoder(csv.NewReader(strings.NewReader(lines)), WithStrict())
+	var x X
+	if err := dec.Decode(&x); err != nil || !math.IsNaN(x.A) || x.B == nil || !math.IsInf(float64(*x.B), -1) {
+		t.Error("Expected NaN and -Inf to be accepted, got", x, err)
+	}
+
+	dec = NewDecoder(csv.NewReader(strings.NewReader(lines)), WithStrict(), WithNonFinite(NonFiniteReject))
+	var fe FieldError
+	if err := dec.Decode(&x); !errors.As(err, &fe) || fe.Field != "A" || !errors.Is(err, ErrNonFinite) {
+		t.Error("Expected an ErrNonFinite for A, got", err)
+	}
+	if err := dec.Decode(&x); err != nil || x.A != 2.5 || *x.B != 3 {
+		t.Error("Expected finite numbers to be accepted, got", x, err)
+	}
+
+	dec = NewDecoder(csv.NewReader(strings.NewReader(lines)), WithStrict(), WithNonFinite(NonFiniteZero))
+	if err := dec.Decode(&x); err != nil || x.A != 0 || x.B == nil || *x.B != 0 || x.C != 1 {
+		t.Error("Expected zeros, got", x, err)
+	}
+
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	enc := NewEncoder(w)
+	inf := float32(math.Inf(1))
+	if err := enc.Encode(X{math.NaN(), &inf, 1}); err != nil {
+		t.Fatal("Expected no error, got", err)
+	}
+	enc.NonFinite = NonFiniteZero
+	if err := enc.Encode(X{math.NaN(), &inf, 2}); err != nil {
+		t.Fatal("Expected no error, got", err)
+	}
+	w.Flush()
+	if want := "NaN,+Inf,1\n0,0,2\n"; buf.String() != want {
+		t.Errorf("Expected %q, got %q", want, buf.String())
+	}
+	enc.NonFinite = NonFiniteReject
+	if err := enc.Encode(X{1, &inf, 3}); !errors.Is(err, ErrNonFinite) {
+		t.Error("Expected an ErrNonFinite, got", err)
+	}
+}
@@ -0,0 +1,612 @@
+// © 2014 Steve McCoy.
+
+package table
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"strconv"
+	"time"
+)
+
+// A Code identifies the kind of an error returned by a Decoder. Unlike
+// error messages, which may change or be overridden by a Formatter,
+// codes are stable and may be relied upon by programs.
+type Code string
+
+const (
+	CodeShortRow        Code = "SHORT_ROW"        // RowError: the row has too few fields
+	CodeLongRow         Code = "LONG_ROW"         // RowError: the row has too many fields
+	CodeUnsupportedKind Code = "UNSUPPORTED_KIND" // DecodeError, EncodeError
+	CodeInvalidTag      Code = "INVALID_TAG"      // TagError
+	CodeParseFailure    Code = "PARSE_FAILURE"    // FieldError
+	CodeDuplicateColumn Code = "DUPLICATE_COLUMN" // DuplicateColumnError
+	CodeMissingColumn   Code = "MISSING_COLUMN"   // MissingColumnError
+	CodeInvalidDest     Code = "INVALID_DEST"     // InvalidDecodeError
+	CodeUnexported      Code = "UNEXPORTED_FIELD" // UnexportedFieldError
+	CodeErrorBudget     Code = "ERROR_BUDGET"     // BudgetError
+	CodeEmptyRow        Code = "EMPTY_ROW"        // ErrEmptyRow
+	CodeTimeout         Code = "TIMEOUT"          // TimeoutError
+	CodeInvalidRow      Code = "INVALID_ROW"      // ValidationError
+	CodeDuplicateRow    Code = "DUPLICATE_ROW"    // DuplicateRowError
+	CodeControlChar     Code = "CONTROL_CHAR"     // ControlCharError
+	CodeOverflow        Code = "OVERFLOW"         // OverflowError
+)
+
+// ErrorCode returns the Code of the first error in err's chain that has
+// one, or the empty Code if there is none, as for errors from the FieldReader.
+func ErrorCode(err error) Code {
+	var c coder
+	if errors.As(err, &c) {
+		return c.Code()
+	}
+	return ""
+}
+
+// coder is implemented by each of the package's errors.
+type coder interface {
+	error
+	Code() Code
+}
+
+// errorJSON is the form in which every error is marshaled to JSON, so that
+// services can report them uniformly. Members that do not apply to a
+// particular error are omitted.
+type errorJSON struct {
+	Record  int    `json:"record,omitempty"`
+	Line    int    `json:"line,omitempty"`
+	Column  *int   `json:"column,omitempty"`
+	Field   string `json:"field,omitempty"`
+	Value   string `json:"value,omitempty"`
+	Code    Code   `json:"code,omitempty"`
+	Message string `json:"message"`
+}
+
+// jsoner is implemented by errors that have a JSON form.
+type jsoner interface {
+	asJSON() errorJSON
+}
+
+// RowError is returned from Decode when the number of fields in a row
+// does not equal the number of exported fields in the destination struct.
+// If there are more row fields than struct fields, MissingField will contain
+// the name of the next available field.
+type RowError struct {
+	RowLen       int
+	StructLen    int
+	MissingField string
+	Record       int // number of the row among those read, as by Decoder.Record
+	Line         int // line on which the row begins, if the FieldReader reports it
+}
+
+func (r RowError) Error() string {
+	msg := position(r.Record, r.Line) + "row mismatch: row length = " + strconv.Itoa(r.RowLen) +
+		", but struct length = " + strconv.Itoa(r.StructLen)
+	if r.MissingField != "" {
+		msg += " (field " + r.MissingField + ")"
+	}
+	return msg
+}
+
+// MarshalJSON encodes r as an object with the members "record", "line",
+// "field" (MissingField), each if known, "code", and "message".
+func (r RowError) MarshalJSON() ([]byte, error) {
+	return json.Marshal(r.asJSON())
+}
+
+func (r RowError) asJSON() errorJSON {
+	return errorJSON{Record: r.Record, Line: r.Line, Field: r.MissingField, Code: r.Code(), Message: r.Error()}
+}
+
+// position returns the prefix of the message of an error in the given
+// record and line, each of which is 0 if unknown.
+func position(record, line int) string {
+	switch {
+	case record > 0 && line > 0:
+		return "record " + strconv.Itoa(record) + " (line " + strconv.Itoa(line) + "): "
+	case record > 0:
+		return "record " + strconv.Itoa(record) + ": "
+	case line > 0:
+		return "line " + strconv.Itoa(line) + ": "
+	}
+	return ""
+}
+
+// Code returns CodeShortRow or CodeLongRow.
+func (r RowError) Code() Code {
+	if r.RowLen < r.StructLen {
+		return CodeShortRow
+	}
+	return CodeLongRow
+}
+
+// DecodeError is returned from Decode if a field is of a Kind that
+// does not have an associated function in Modify, or is an interface
+// type with methods. Check returns it with no Value, Record, or Line.
+type DecodeError struct {
+	Type   string // the type or Kind that cannot be decoded
+	Field  string // name of the struct field or map column, if known
+	Column int    // index of the field's first column, or -1 if unknown
+	Value  string // text of the field's cell in the row, if any
+	Err    error  // errors.ErrUnsupported
+	Record int    // number of the row among those read, as by Decoder.Record
+	Line   int    // line on which the row begins, if the FieldReader reports it
+}
+
+// decodeError returns a DecodeError for the type or Kind typ,
+// in no particular field.
+func decodeError(typ string) DecodeError {
+	return DecodeError{Type: typ, Column: -1, Err: errors.ErrUnsupported}
+}
+
+func (d DecodeError) Error() string {
+	msg := position(d.Record, d.Line) + d.Type + " is not decodable"
+	switch {
+	case d.Field != "" && d.Column >= 0:
+		msg += " (field " + d.Field + ", column " + strconv.Itoa(d.Column) + ")"
+	case d.Field != "":
+		msg += " (field " + d.Field + ")"
+	}
+	return msg
+}
+
+// Unwrap returns d.Err.
+func (d DecodeError) Unwrap() error {
+	return d.Err
+}
+
+// MarshalJSON encodes d as an object with the members "record", "line",
+// "column", "field", and "value", each if known, "code", and "message".
+func (d DecodeError) MarshalJSON() ([]byte, error) {
+	return json.Marshal(d.asJSON())
+}
+
+func (d DecodeError) asJSON() errorJSON {
+	j := errorJSON{Record: d.Record, Line: d.Line, Field: d.Field, Value: d.Value, Code: d.Code(), Message: d.Error()}
+	if d.Column >= 0 {
+		col := d.Column
+		j.Column = &col
+	}
+	return j
+}
+
+// Code returns CodeUnsupportedKind.
+func (d DecodeError) Code() Code {
+	return CodeUnsupportedKind
+}
+
+// MarshalJSON encodes e as an object with the members "code" and "message".
+func (e EncodeError) MarshalJSON() ([]byte, error) {
+	return json.Marshal(e.asJSON())
+}
+
+func (e EncodeError) asJSON() errorJSON {
+	return errorJSON{Code: e.Code(), Message: e.Error()}
+}
+
+// Code returns CodeUnsupportedKind.
+func (e EncodeError) Code() Code {
+	return CodeUnsupportedKind
+}
+
+// TagError is returned when the `table` tag of a struct field is
+// malformed or does not apply to the field.
+type TagError struct {
+	Field  string // name of the struct field, if known
+	Tag    string // the field's `table` tag
+	Reason string
+}
+
+func (t TagError) Error() string {
+	if t.Field == "" {
+		return "bad table tag " + strconv.Quote(t.Tag) + ": " + t.Reason
+	}
+	return "bad table tag " + strconv.Quote(t.Tag) + " on field " + t.Field + ": " + t.Reason
+}
+
+// MarshalJSON encodes t as an object with the members
+// "field", "code", and "message".
+func (t TagError) MarshalJSON() ([]byte, error) {
+	return json.Marshal(t.asJSON())
+}
+
+func (t TagError) asJSON() errorJSON {
+	return errorJSON{Field: t.Field, Code: t.Code(), Message: t.Error()}
+}
+
+// Code returns CodeInvalidTag.
+func (t TagError) Code() Code {
+	return CodeInvalidTag
+}
+
+// FieldError is returned from Decode, when d.Strict is set, if a function
+// in d.Modify fails to decode a cell, for a reason other than its value
+// being out of range, for which an OverflowError is returned.
+type FieldError struct {
+	Field  string // name of the struct field or map column
+	Column int    // index of the cell in the row
+	Value  string // text of the cell
+	Err    error  // error from the Modify function
+	Record int    // number of the row among those read, as by Decoder.Record
+	Line   int    // line on which the cell begins, if the FieldReader reports it
+}
+
+func (f FieldError) Error() string {
+	return position(f.Record, f.Line) + "cannot decode " + strconv.Quote(f.Value) + " into field " + f.Field +
+		" (column " + strconv.Itoa(f.Column) + "): " + f.Err.Error()
+}
+
+// Unwrap returns f.Err.
+func (f FieldError) Unwrap() error {
+	return f.Err
+}
+
+// MarshalJSON encodes f as an object with the members "record" and
+// "line", each if known, "column", "field", "value", "code", and "message".
+func (f FieldError) MarshalJSON() ([]byte, error) {
+	return json.Marshal(f.asJSON())
+}
+
+func (f FieldError) asJSON() errorJSON {
+	col := f.Column
+	return errorJSON{Record: f.Record, Line: f.Line, Column: &col, Field: f.Field, Value: f.Value, Code: f.Code(), Message: f.Error()}
+}
+
+// Code returns CodeParseFailure.
+func (f FieldError) Code() Code {
+	return CodeParseFailure
+}
+
+// OverflowError is returned from Decode, in place of a FieldError, for a
+// cell whose value is out of the range of the type of its field or
+// column, such as 300 for an int8. The field or column is left zero.
+type OverflowError struct {
+	Field  string       // name of the struct field or map column
+	Column int          // index of the cell in the row
+	Value  string       // text of the cell
+	Type   reflect.Type // type of the field or column
+	Err    error        // error from the Modify function, which wraps strconv.ErrRange
+	Record int          // number of the row among those read, as by Decoder.Record
+	Line   int          // line on which the cell begins, if the FieldReader reports it
+}
+
+func (o OverflowError) Error() string {
+	return position(o.Record, o.Line) + strconv.Quote(o.Value) + " overflows field " + o.Field +
+		" of type " + o.Type.String() + " (column " + strconv.Itoa(o.Column) + ")"
+}
+
+// Unwrap returns o.Err.
+func (o OverflowError) Unwrap() error {
+	return o.Err
+}
+
+// MarshalJSON encodes o as an object with the members "record" and
+// "line", each if known, "column", "field", "value", "code", and "message".
+func (o OverflowError) MarshalJSON() ([]byte, error) {
+	return json.Marshal(o.asJSON())
+}
+
+func (o OverflowError) asJSON() errorJSON {
+	col := o.Column
+	return errorJSON{Record: o.Record, Line: o.Line, Column: &col, Field: o.Field, Value: o.Value, Code: o.Code(), Message: o.Error()}
+}
+
+// Code returns CodeOverflow.
+func (o OverflowError) Code() Code {
+	return CodeOverflow
+}
+
+// fieldError returns the error for err, from decoding value, the cell of
+// the named field or column of type t at col: an OverflowError if err is
+// for a value out of range, and otherwise a FieldError.
+func (d *Decoder) fieldError(name string, col int, value string, t reflect.Type, err error) error {
+	if errors.Is(err, strconv.ErrRange) {
+		return OverflowError{name, col, value, t, err, d.records, d.line(col)}
+	}
+	return FieldError{name, col, value, err, d.records, d.line(col)}
+}
+
+// InvalidDecodeError is returned from Decode if the destination is not
+// a pointer to a struct or a map, or is a nil pointer.
+type InvalidDecodeError struct {
+	Type reflect.Type // nil for a nil destination
+}
+
+func (i InvalidDecodeError) Error() string {
+	if i.Type == nil {
+		return "cannot decode into nil"
+	}
+	if i.Type.Kind() == reflect.Ptr && i.Type.Elem().Kind() == reflect.Struct {
+		return "cannot decode into a nil " + i.Type.String()
+	}
+	return "cannot decode into " + i.Type.String() + ", which is not a pointer to a struct or a map"
+}
+
+// MarshalJSON encodes i as an object with the members "code" and "message".
+func (i InvalidDecodeError) MarshalJSON() ([]byte, error) {
+	return json.Marshal(i.asJSON())
+}
+
+func (i InvalidDecodeError) asJSON() errorJSON {
+	return errorJSON{Code: i.Code(), Message: i.Error()}
+}
+
+// Code returns CodeInvalidDest.
+func (i InvalidDecodeError) Code() Code {
+	return CodeInvalidDest
+}
+
+// UnexportedFieldError is returned from Decode, when d.Strict is set, for
+// an unexported struct field that is followed by an exported one.
+// Unexported fields are not bound to columns, so such a field silently
+// shifts the columns of the fields after it.
+type UnexportedFieldError string
+
+func (u UnexportedFieldError) Error() string {
+	return "unexported field " + string(u) + " is not bound to a column"
+}
+
+// MarshalJSON encodes u as an object with the members
+// "field", "code", and "message".
+func (u UnexportedFieldError) MarshalJSON() ([]byte, error) {
+	return json.Marshal(u.asJSON())
+}
+
+func (u UnexportedFieldError) asJSON() errorJSON {
+	return errorJSON{Field: string(u), Code: u.Code(), Message: u.Error()}
+}
+
+// Code returns CodeUnexported.
+func (u UnexportedFieldError) Code() Code {
+	return CodeUnexported
+}
+
+// DuplicateColumnError is returned from Decode when the header names
+// a column more than once and the Decoder's Duplicates is DuplicateError.
+type DuplicateColumnError struct {
+	Name          string
+	First, Second int // the columns with the name
+}
+
+func (d DuplicateColumnError) Error() string {
+	return "duplicate column " + strconv.Quote(d.Name) + " at " +
+		strconv.Itoa(d.First) + " and " + strconv.Itoa(d.Second)
+}
+
+// MarshalJSON encodes d as an object with the members
+// "column" (Second), "field" (Name), "code", and "message".
+func (d DuplicateColumnError) MarshalJSON() ([]byte, error) {
+	return json.Marshal(d.asJSON())
+}
+
+func (d DuplicateColumnError) asJSON() errorJSON {
+	col := d.Second
+	return errorJSON{Column: &col, Field: d.Name, Code: d.Code(), Message: d.Error()}
+}
+
+// Code returns CodeDuplicateColumn.
+func (d DuplicateColumnError) Code() Code {
+	return CodeDuplicateColumn
+}
+
+// MissingColumnError is returned from Decode when the Decoder's UseHeader
+// is set and a struct field has no column of its name in the header,
+// and from Encode when a map has a key that is not in the Encoder's Columns.
+type MissingColumnError struct {
+	Field  string // name of the struct field
+	Column string // name of the column it would be bound to
+}
+
+func (m MissingColumnError) Error() string {
+	return "no column " + strconv.Quote(m.Column) + " for field " + m.Field
+}
+
+// MarshalJSON encodes m as an object with the members
+// "field", "code", and "message".
+func (m MissingColumnError) MarshalJSON() ([]byte, error) {
+	return json.Marshal(m.asJSON())
+}
+
+func (m MissingColumnError) asJSON() errorJSON {
+	return errorJSON{Field: m.Field, Code: m.Code(), Message: m.Error()}
+}
+
+// Code returns CodeMissingColumn.
+func (m MissingColumnError) Code() Code {
+	return CodeMissingColumn
+}
+
+// BudgetError is returned from Decode once the rows with errors exceed
+// the Decoder's MaxErrors or MaxErrorRate, and from every call after.
+// A row has an error if Decode returned one for it or, unless the Decoder
+// is Strict, if a field could not be parsed and was left as it was.
+type BudgetError struct {
+	Rows   int   // number of rows read
+	Errors int   // number of those rows with errors
+	Err    error // the error of the last row, if Decode returned one
+}
+
+func (b BudgetError) Error() string {
+	msg := "aborted after " + strconv.Itoa(b.Errors) + " errors in " + strconv.Itoa(b.Rows) + " rows"
+	if b.Err != nil {
+		msg += ": " + b.Err.Error()
+	}
+	return msg
+}
+
+func (b BudgetError) Unwrap() error {
+	return b.Err
+}
+
+// MarshalJSON encodes b as an object with the members
+// "code" and "message".
+func (b BudgetError) MarshalJSON() ([]byte, error) {
+	return json.Marshal(b.asJSON())
+}
+
+func (b BudgetError) asJSON() errorJSON {
+	return errorJSON{Code: b.Code(), Message: b.Error()}
+}
+
+// Code returns CodeErrorBudget.
+func (b BudgetError) Code() Code {
+	return CodeErrorBudget
+}
+
+// TimeoutError is returned from Decode when the Decoder's FieldReader
+// does not return a record within the Decoder's RowTimeout. It is not
+// a problem with the data: Decode may be called again to keep waiting
+// for the same record.
+type TimeoutError struct {
+	Timeout time.Duration
+}
+
+func (t TimeoutError) Error() string {
+	return "no record read within " + t.Timeout.String()
+}
+
+// MarshalJSON encodes t as an object with the members "code" and "message".
+func (t TimeoutError) MarshalJSON() ([]byte, error) {
+	return json.Marshal(t.asJSON())
+}
+
+func (t TimeoutError) asJSON() errorJSON {
+	return errorJSON{Code: t.Code(), Message: t.Error()}
+}
+
+// Code returns CodeTimeout.
+func (t TimeoutError) Code() Code {
+	return CodeTimeout
+}
+
+// ValidationError is returned from Decode when the Decoder's Validate
+// rejects a row that was otherwise decoded.
+type ValidationError struct {
+	Err    error
+	Record int // number of the row among those read, as by Decoder.Record
+	Line   int // line on which the row begins, if the FieldReader reports it
+}
+
+func (v ValidationError) Error() string {
+	return position(v.Record, v.Line) + "invalid row: " + v.Err.Error()
+}
+
+func (v ValidationError) Unwrap() error {
+	return v.Err
+}
+
+// MarshalJSON encodes v as an object with the members "record" and
+// "line", each if known, "code", and "message".
+func (v ValidationError) MarshalJSON() ([]byte, error) {
+	return json.Marshal(v.asJSON())
+}
+
+func (v ValidationError) asJSON() errorJSON {
+	return errorJSON{Record: v.Record, Line: v.Line, Code: v.Code(), Message: v.Error()}
+}
+
+// Code returns CodeInvalidRow.
+func (v ValidationError) Code() Code {
+	return CodeInvalidRow
+}
+
+// Errors is a list of errors, returned when more than one problem
+// is reported at once.
+type Errors []error
+
+func (e Errors) Error() string {
+	msg := ""
+	for i, err := range e {
+		if i > 0 {
+			msg += "; "
+		}
+		msg += err.Error()
+	}
+	return msg
+}
+
+// Unwrap returns the errors in e.
+func (e Errors) Unwrap() []error {
+	return e
+}
+
+// MarshalJSON encodes e as an array of its errors.
+// Errors that are not the package's own are encoded with only a "message".
+func (e Errors) MarshalJSON() ([]byte, error) {
+	js := make([]errorJSON, len(e))
+	for i, err := range e {
+		if j, ok := err.(jsoner); ok {
+			js[i] = j.asJSON()
+		} else {
+			js[i] = errorJSON{Message: err.Error()}
+		}
+	}
+	return json.Marshal(js)
+}
+
+// A Formatter provides the message of an error returned by a Decoder,
+// for example to present it to the user of an import tool in their
+// own language. Format is passed the package's error (such as a RowError
+// or DecodeError) that would otherwise be returned.
+type Formatter interface {
+	Format(err error) string
+}
+
+// FormatterFunc is an adapter to allow the use of an ordinary function
+// as a Formatter.
+type FormatterFunc func(err error) string
+
+// Format returns f(err).
+func (f FormatterFunc) Format(err error) string {
+	return f(err)
+}
+
+// formattedError is an error whose message was provided by a Formatter.
+type formattedError struct {
+	err error
+	msg string
+}
+
+func (f formattedError) Error() string {
+	return f.msg
+}
+
+func (f formattedError) Unwrap() error {
+	return f.err
+}
+
+// MarshalJSON encodes f as its underlying error would be,
+// but with f's message.
+func (f formattedError) MarshalJSON() ([]byte, error) {
+	return json.Marshal(f.asJSON())
+}
+
+func (f formattedError) asJSON() errorJSON {
+	var j errorJSON
+	if e, ok := f.err.(jsoner); ok {
+		j = e.asJSON()
+	}
+	j.Message = f.msg
+	return j
+}
+
+// format applies d.Formatter, if any, to err if it is one of the
+// package's own errors, or to each of the errors in err if it is an
+// Errors, as under AllErrors. Errors from the FieldReader are left alone.
+func (d *Decoder) format(err error) error {
+	if d.Formatter == nil {
+		return err
+	}
+	if errs, ok := err.(Errors); ok {
+		formatted := make(Errors, len(errs))
+		for i, e := range errs {
+			formatted[i] = d.format(e)
+		}
+		return formatted
+	}
+	if _, ok := err.(coder); ok {
+		return formattedError{err, d.Formatter.Format(err)}
+	}
+	return err
+}
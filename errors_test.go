@@ -0,0 +1,120 @@
+// © 2014 Steve McCoy.
+
+package table
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"io"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestFormatter(t *testing.T) {
+	type X struct {
+		A int
+		B string
+		C int
+	}
+	lines := `
+1,blonde
+`
+	dec := NewDecoder(csv.NewReader(strings.NewReader(lines)))
+	dec.Formatter = FormatterFunc(func(err error) string {
+		if re, ok := err.(RowError); ok {
+			return "Zeile hat zu wenige Spalten: " + re.MissingField + " fehlt"
+		}
+		return err.Error()
+	})
+	var x X
+	err := dec.Decode(&x)
+	if err == nil {
+		t.Fatal("Expected an error")
+	}
+	if err.Error() != "Zeile hat zu wenige Spalten: C fehlt" {
+		t.Error("Unexpected err.Error():", err.Error())
+	}
+	var re RowError
+	if !errors.As(err, &re) {
+		t.Error("Expected to recover a RowError, got", err)
+	} else if re.MissingField != "C" {
+		t.Error("Expected MissingField of C, got", re.MissingField)
+	}
+
+	if err := dec.Decode(&x); err != io.EOF {
+		t.Error("Expected reader errors to be untouched, got", err)
+	}
+}
+
+func TestFormatterAllErrors(t *testing.T) {
+	type X struct {
+		A int
+		B int
+		C int
+	}
+	dec := NewDecoder(csv.NewReader(strings.NewReader("x,2,y\n")), WithAllErrors())
+	dec.Formatter = FormatterFunc(func(err error) string {
+		if fe, ok := err.(FieldError); ok {
+			return "Feld " + fe.Field + " ist ungültig"
+		}
+		return err.Error()
+	})
+	var x X
+	err := dec.Decode(&x)
+	errs, ok := err.(Errors)
+	if !ok || len(errs) != 2 {
+		t.Fatal("Expected Errors of 2, got", err)
+	}
+	if errs[0].Error() != "Feld A ist ungültig" || errs[1].Error() != "Feld C ist ungültig" {
+		t.Error("Expected formatted messages, got", errs)
+	}
+	var fe FieldError
+	if !errors.As(err, &fe) || fe.Field != "A" {
+		t.Error("Expected to recover the FieldError for A, got", err)
+	}
+}
+
+func TestErrorCode(t *testing.T) {
+	tests := []struct {
+		err  error
+		code Code
+	}{
+		{RowError{2, 3, "C", 0, 0}, CodeShortRow},
+		{RowError{3, 2, "", 0, 0}, CodeLongRow},
+		{decodeError("uintptr"), CodeUnsupportedKind},
+		{formattedError{RowError{2, 3, "C", 0, 0}, "nope"}, CodeShortRow},
+		{io.EOF, ""},
+	}
+	for _, test := range tests {
+		if c := ErrorCode(test.err); c != test.code {
+			t.Errorf("Expected code %q for %v, got %q", test.code, test.err, c)
+		}
+	}
+}
+
+func TestErrorJSON(t *testing.T) {
+	tests := []struct {
+		err  error
+		json string
+	}{
+		{RowError{2, 3, "C", 0, 0}, `{"field":"C","code":"SHORT_ROW","message":"row mismatch: row length = 2, but struct length = 3 (field C)"}`},
+		{decodeError("uintptr"), `{"code":"UNSUPPORTED_KIND","message":"uintptr is not decodable"}`},
+		{DecodeError{"uintptr", "B", 1, "2", errors.ErrUnsupported, 3, 4}, `{"record":3,"line":4,"column":1,"field":"B","value":"2","code":"UNSUPPORTED_KIND","message":"record 3 (line 4): uintptr is not decodable (field B, column 1)"}`},
+		{formattedError{RowError{3, 2, "", 0, 0}, "too long"}, `{"code":"LONG_ROW","message":"too long"}`},
+		{RowError{3, 2, "", 4, 5}, `{"record":4,"line":5,"code":"LONG_ROW","message":"record 4 (line 5): row mismatch: row length = 3, but struct length = 2"}`},
+		{ValidationError{errors.New("negative price"), 2, 0}, `{"record":2,"code":"INVALID_ROW","message":"record 2: invalid row: negative price"}`},
+		{ControlCharError{'\n', 1, 2, 3}, `{"record":2,"line":3,"column":1,"code":"CONTROL_CHAR","message":"record 2 (line 3): column 1: control character '\\n'"}`},
+		{OverflowError{"N", 0, "256", reflect.TypeOf(uint8(0)), strconv.ErrRange, 1, 0}, `{"record":1,"column":0,"field":"N","value":"256","code":"OVERFLOW","message":"record 1: \"256\" overflows field N of type uint8 (column 0)"}`},
+	}
+	for _, test := range tests {
+		b, err := json.Marshal(test.err)
+		if err != nil {
+			t.Error("Expected no error, got", err)
+		} else if string(b) != test.json {
+			t.Errorf("Expected %s, got %s", test.json, b)
+		}
+	}
+}
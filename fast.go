@@ -0,0 +1,114 @@
+// © 2014 Steve McCoy.
+
+//go:build tableunsafe
+
+package table
+
+import (
+	"reflect"
+	"strconv"
+	"unsafe"
+)
+
+// fastField returns a function that decodes cells into the field of the
+// struct type t at index, of Kind k, as d.Modify's default function for
+// k does, but by writing to the field's memory directly, at its offset
+// from the start of the struct, or nil if there is none for k, or the
+// field is in an embedded struct that t points to.
+//
+// It is only built with the tableunsafe build tag, for programs that
+// decode so many rows that reflection is their bottleneck; without it,
+// fields are always set through reflect.
+func fastField(t reflect.Type, index []int, k reflect.Kind) func(reflect.Value, string) error {
+	var offset uintptr
+	for _, x := range index {
+		if t.Kind() != reflect.Struct {
+			return nil
+		}
+		sf := t.Field(x)
+		offset += sf.Offset
+		t = sf.Type
+	}
+	set := fastSetters[k]
+	if set == nil {
+		return nil
+	}
+	return func(v reflect.Value, s string) error {
+		return set(unsafe.Add(v.Addr().UnsafePointer(), offset), s)
+	}
+}
+
+// fastSetters are the functions that fastField uses for each Kind.
+// Like d.Modify's default functions, they set a field to zero for a
+// cell that they cannot parse.
+var fastSetters = map[reflect.Kind]func(unsafe.Pointer, string) error{
+	reflect.Bool: func(p unsafe.Pointer, s string) error {
+		b, err := strconv.ParseBool(s)
+		*(*bool)(p) = b
+		return err
+	},
+	reflect.Int: func(p unsafe.Pointer, s string) error {
+		n, err := parseInt(s, strconv.IntSize)
+		*(*int)(p) = int(n)
+		return err
+	},
+	reflect.Int8: func(p unsafe.Pointer, s string) error {
+		n, err := parseInt(s, 8)
+		*(*int8)(p) = int8(n)
+		return err
+	},
+	reflect.Int16: func(p unsafe.Pointer, s string) error {
+		n, err := parseInt(s, 16)
+		*(*int16)(p) = int16(n)
+		return err
+	},
+	reflect.Int32: func(p unsafe.Pointer, s string) error {
+		n, err := parseInt(s, 32)
+		*(*int32)(p) = int32(n)
+		return err
+	},
+	reflect.Int64: func(p unsafe.Pointer, s string) error {
+		n, err := parseInt(s, 64)
+		*(*int64)(p) = n
+		return err
+	},
+	reflect.Uint: func(p unsafe.Pointer, s string) error {
+		n, err := parseUint(s, strconv.IntSize)
+		*(*uint)(p) = uint(n)
+		return err
+	},
+	reflect.Uint8: func(p unsafe.Pointer, s string) error {
+		n, err := parseUint(s, 8)
+		*(*uint8)(p) = uint8(n)
+		return err
+	},
+	reflect.Uint16: func(p unsafe.Pointer, s string) error {
+		n, err := parseUint(s, 16)
+		*(*uint16)(p) = uint16(n)
+		return err
+	},
+	reflect.Uint32: func(p unsafe.Pointer, s string) error {
+		n, err := parseUint(s, 32)
+		*(*uint32)(p) = uint32(n)
+		return err
+	},
+	reflect.Uint64: func(p unsafe.Pointer, s string) error {
+		n, err := parseUint(s, 64)
+		*(*uint64)(p) = n
+		return err
+	},
+	reflect.Float32: func(p unsafe.Pointer, s string) error {
+		n, err := parseFloat(s, 32)
+		*(*float32)(p) = float32(n)
+		return err
+	},
+	reflect.Float64: func(p unsafe.Pointer, s string) error {
+		n, err := parseFloat(s, 64)
+		*(*float64)(p) = n
+		return err
+	},
+	reflect.String: func(p unsafe.Pointer, s string) error {
+		*(*string)(p) = s
+		return nil
+	},
+}
@@ -0,0 +1,13 @@
+// © 2014 Steve McCoy.
+
+//go:build !tableunsafe
+
+package table
+
+import "reflect"
+
+// fastField returns nil, since fields are always set through reflect
+// without the tableunsafe build tag.
+func fastField(t reflect.Type, index []int, k reflect.Kind) func(reflect.Value, string) error {
+	return nil
+}
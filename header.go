@@ -0,0 +1,160 @@
+// © 2014 Steve McCoy.
+
+package table
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"strconv"
+	"strings"
+)
+
+// A DuplicatePolicy says what a Decoder does when its Header names
+// the same column more than once.
+type DuplicatePolicy int
+
+const (
+	DuplicateError  DuplicatePolicy = iota // a DuplicateColumnError is returned
+	DuplicateFirst                         // the first column of the name is used; the others are ignored
+	DuplicateLast                          // the last column of the name is used; the others are ignored
+	DuplicateSuffix                        // the second column of the name is renamed name_2, the third name_3, and so on
+)
+
+// resolved is a Header with its duplicates resolved.
+type resolved struct {
+	from  []string       // the Header that was resolved
+	names []string       // names[i] is the name of column i, or "" if it is ignored
+	index map[string]int // the column of each name
+
+	migration *Migration // the Migration applied to the Header, if any
+}
+
+// resolve resolves d.Header according to d.Migrations and d.Duplicates,
+// reusing the last resolution if Header has not changed since.
+func (d *Decoder) resolve() (*resolved, error) {
+	if d.resolved != nil && sameStrings(d.resolved.from, d.Header) {
+		return d.resolved, nil
+	}
+
+	r := &resolved{
+		from:      d.Header,
+		names:     make([]string, len(d.Header)),
+		index:     make(map[string]int, len(d.Header)),
+		migration: d.migration(d.Header),
+	}
+	header := d.Header
+	if r.migration != nil && len(r.migration.Renames) > 0 {
+		header = make([]string, len(d.Header))
+		for i, name := range d.Header {
+			if n, ok := r.migration.Renames[name]; ok {
+				name = n
+			}
+			header[i] = name
+		}
+	}
+	taken := make(map[string]bool, len(header))
+	for _, name := range header {
+		taken[name] = true
+	}
+	for i, name := range header {
+		j, dup := r.index[name]
+		switch {
+		case !dup:
+		case d.Duplicates == DuplicateError:
+			return nil, DuplicateColumnError{name, j, i}
+		case d.Duplicates == DuplicateFirst:
+			continue
+		case d.Duplicates == DuplicateLast:
+			r.names[j] = ""
+		case d.Duplicates == DuplicateSuffix:
+			n := 2
+			for taken[name+"_"+strconv.Itoa(n)] {
+				n++
+			}
+			name += "_" + strconv.Itoa(n)
+			taken[name] = true
+		}
+		r.names[i] = name
+		r.index[name] = i
+	}
+	d.resolved = r
+	return r, nil
+}
+
+// column returns the column with the given name. If fold is set and no
+// column has exactly that name, a column whose name matches in any case
+// will do.
+func (r *resolved) column(name string, fold bool) (int, bool) {
+	if i, ok := r.index[name]; ok {
+		return i, true
+	}
+	if fold {
+		for i, n := range r.names {
+			if n != "" && strings.EqualFold(n, name) {
+				return i, true
+			}
+		}
+	}
+	return 0, false
+}
+
+// Columns returns the column index of each name in d.Header, after
+// duplicate names have been resolved according to d.Duplicates.
+// It returns nil if the Header is not known yet.
+func (d *Decoder) Columns() (map[string]int, error) {
+	if d.Header == nil {
+		return nil, nil
+	}
+	r, err := d.resolve()
+	if err != nil {
+		return nil, err
+	}
+	index := make(map[string]int, len(r.index))
+	for name, i := range r.index {
+		index[name] = i
+	}
+	return index, nil
+}
+
+// HeaderHash returns the hash of d.Header, as by HashHeader,
+// or "" if the Header is not known yet.
+func (d *Decoder) HeaderHash() string {
+	if d.Header == nil {
+		return ""
+	}
+	return HashHeader(d.Header)
+}
+
+// HashHeader returns a hash of the names of header, in order, as a string
+// of hexadecimal digits. Headers have the same hash only if they are the
+// same, so a pipeline can store the hash of the header it expects and be
+// alerted when a provider changes its format.
+func HashHeader(header []string) string {
+	return hashStrings(header)
+}
+
+// hashStrings returns the hexadecimal SHA-256 hash of ss, as by sumStrings.
+func hashStrings(ss []string) string {
+	sum := sumStrings(ss)
+	return hex.EncodeToString(sum[:])
+}
+
+// sumStrings returns the SHA-256 hash of ss, each prefixed by its length,
+// so that no two lists of strings are encoded alike.
+func sumStrings(ss []string) [sha256.Size]byte {
+	h := sha256.New()
+	var n [binary.MaxVarintLen64]byte
+	for _, s := range ss {
+		h.Write(n[:binary.PutUvarint(n[:], uint64(len(s)))])
+		h.Write([]byte(s))
+	}
+	var sum [sha256.Size]byte
+	h.Sum(sum[:0])
+	return sum
+}
+
+// sameStrings reports whether a and b are the same slice.
+func sameStrings(a, b []string) bool {
+	return len(a) == len(b) && (len(a) == 0 || &a[0] == &b[0])
+}
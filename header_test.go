@@ -0,0 +1,217 @@
+// © 2014 Steve McCoy.
+
+package table
+
+import (
+	"encoding/csv"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestDuplicateColumns(t *testing.T) {
+	lines := `name,age,name,name_2,name
+a,1,b,c,d
+`
+	tests := []struct {
+		policy  DuplicatePolicy
+		want    map[string]interface{}
+		columns map[string]int
+	}{
+		{
+			DuplicateFirst,
+			map[string]interface{}{"name": "a", "age": "1", "name_2": "c"},
+			map[string]int{"name": 0, "age": 1, "name_2": 3},
+		},
+		{
+			DuplicateLast,
+			map[string]interface{}{"name": "d", "age": "1", "name_2": "c"},
+			map[string]int{"name": 4, "age": 1, "name_2": 3},
+		},
+		{
+			DuplicateSuffix,
+			map[string]interface{}{"name": "a", "age": "1", "name_3": "b", "name_2": "c", "name_4": "d"},
+			map[string]int{"name": 0, "age": 1, "name_3": 2, "name_2": 3, "name_4": 4},
+		},
+	}
+	for _, test := range tests {
+		dec := NewDecoder(csv.NewReader(strings.NewReader(lines)), WithDuplicates(test.policy))
+		var m map[string]interface{}
+		if err := dec.Decode(&m); err != nil {
+			t.Fatal("Expected no error, got", err)
+		}
+		if !reflect.DeepEqual(m, test.want) {
+			t.Errorf("Expected %v with policy %d, got %v", test.want, test.policy, m)
+		}
+		if cols, err := dec.Columns(); err != nil || !reflect.DeepEqual(cols, test.columns) {
+			t.Errorf("Expected columns %v with policy %d, got %v, %v", test.columns, test.policy, cols, err)
+		}
+	}
+
+	dec := NewDecoder(csv.NewReader(strings.NewReader(lines)))
+	var m map[string]interface{}
+	err := dec.Decode(&m)
+	if err != (DuplicateColumnError{"name", 0, 2}) {
+		t.Error("Expected a DuplicateColumnError, got", err)
+	}
+	if cols, err := dec.Columns(); cols != nil || err == nil {
+		t.Error("Expected Columns to fail, got", cols, err)
+	}
+}
+
+func TestUseHeader(t *testing.T) {
+	type X struct {
+		Name    string
+		Age     int `table:"years"`
+		AgeRaw  Raw
+		Comment Raw `table:"NAME"`
+		hidden  string
+		City    string
+	}
+	lines := `junk,city,years,NAME
+x,Rochester,3,blonde
+y,Ithaca,4,on
+`
+	dec := NewDecoder(csv.NewReader(strings.NewReader(lines)), WithUseHeader(), WithStrict())
+	want := []X{
+		{"blonde", 3, "3", "blonde", "", "Rochester"},
+		{"on", 4, "4", "on", "", "Ithaca"},
+	}
+	for _, w := range want {
+		var x X
+		if err := dec.Decode(&x); err != nil {
+			t.Fatal("Expected no error, got", err)
+		}
+		if x != w {
+			t.Errorf("Expected %+v, got %+v", w, x)
+		}
+	}
+	if err := dec.Decode(&X{}); err != io.EOF {
+		t.Error("Expected io.EOF, got", err)
+	}
+
+	type Y struct {
+		Name string
+		Zip  string
+		Age  int `table:"age"`
+	}
+	dec = NewDecoder(csv.NewReader(strings.NewReader(lines)), WithUseHeader())
+	err := dec.Decode(&Y{})
+	if err != (MissingColumnError{"Zip", "Zip"}) {
+		t.Error("Expected a MissingColumnError for Zip, got", err)
+	}
+}
+
+func TestHeaderHash(t *testing.T) {
+	dec := NewDecoder(csv.NewReader(strings.NewReader("a,b\n1,2\n")), WithUseHeader())
+	if h := dec.HeaderHash(); h != "" {
+		t.Error("Expected no hash before the header is read, got", h)
+	}
+	m := map[string]interface{}{}
+	if err := dec.Decode(m); err != nil {
+		t.Fatal("Expected no error, got", err)
+	}
+	if dec.HeaderHash() != HashHeader([]string{"a", "b"}) {
+		t.Error("Expected the hash of a,b, got", dec.HeaderHash())
+	}
+	if HashHeader([]string{"a,b"}) == HashHeader([]string{"a", "b"}) || HashHeader([]string{"ab", ""}) == HashHeader([]string{"a", "b"}) {
+		t.Error("Expected different headers to have different hashes")
+	}
+}
+
+func TestMigrations(t *testing.T) {
+	type X struct {
+		Name  string  `table:"name"`
+		Price float64 `table:"price"`
+	}
+	v1 := Migration{
+		Version: "v1",
+		Renames: map[string]string{"customer": "name", "cents": "price"},
+		Convert: map[string]Transformer{"price": func(s string) string {
+			return strings.TrimSuffix(s, "00") // whole dollars, in cents
+		}},
+	}
+	tests := []struct {
+		lines   string
+		version string
+	}{
+		{"customer,cents\nBrian,1200\n", "v1"},
+		{"name,price\nBrian,12\n", ""},
+	}
+	for _, test := range tests {
+		dec := NewDecoder(csv.NewReader(strings.NewReader(test.lines)), WithUseHeader(), WithStrict(), WithMigrations(v1))
+		var x X
+		if err := dec.Decode(&x); err != nil {
+			t.Fatal("Expected no error, got", err)
+		}
+		if x != (X{"Brian", 12}) {
+			t.Errorf("Unexpected result for version %q: %+v", test.version, x)
+		}
+		if dec.Version() != test.version {
+			t.Errorf("Expected version %q, got %q", test.version, dec.Version())
+		}
+	}
+}
+
+func TestChunkReader(t *testing.T) {
+	chunks := map[string][2]string{
+		"":   {"# export\nname,note\na,\"one\n", "p2"},
+		"p2": {"# export\nname,note\nline\"\nb,two\n", "p3"},
+		"p3": {"# export\nname,note\nc,three\nd,fo", "p4"},
+		"p4": {"ur\n", ""},
+	}
+	var opened []string
+	next := func(token string) (io.ReadCloser, string, error) {
+		opened = append(opened, token)
+		c := chunks[token]
+		return io.NopCloser(strings.NewReader(c[0])), c[1], nil
+	}
+	cr := NewChunkReader(next, 2)
+	b, err := io.ReadAll(cr)
+	if err != nil {
+		t.Fatal("Expected no error, got", err)
+	}
+	want := "# export\nname,note\na,\"one\nline\"\nb,two\nc,three\nd,four\n"
+	if string(b) != want {
+		t.Errorf("Expected %q, got %q", want, b)
+	}
+	if !reflect.DeepEqual(opened, []string{"", "p2", "p3", "p4"}) || cr.Token() != "" {
+		t.Error("Unexpected chunks opened:", opened, cr.Token())
+	}
+
+	r := csv.NewReader(NewChunkReader(next, 2))
+	r.Comment = '#'
+	dec := NewDecoder(r, WithUseHeader())
+	var rows []struct{ Name, Note string }
+	for {
+		var x struct{ Name, Note string }
+		err := dec.Decode(&x)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal("Expected no error, got", err)
+		}
+		rows = append(rows, x)
+	}
+	if len(rows) != 4 || rows[0].Note != "one\nline" || rows[3].Note != "four" {
+		t.Error("Unexpected rows:", rows)
+	}
+
+	opened = nil
+	cr = NewChunkReader(next, 2)
+	buf := make([]byte, 4)
+	if _, err := cr.Read(buf); err != nil {
+		t.Fatal("Expected no error, got", err)
+	}
+	if err := cr.Close(); err != nil {
+		t.Error("Expected no error, got", err)
+	}
+	if n, err := cr.Read(buf); n != 0 || err != io.EOF {
+		t.Error("Expected EOF after Close, got", n, err)
+	}
+	if len(opened) != 1 || cr.Token() != "p2" {
+		t.Error("Expected one chunk opened, got", opened, cr.Token())
+	}
+}
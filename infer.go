@@ -0,0 +1,65 @@
+// © 2014 Steve McCoy.
+
+package table
+
+import (
+	"math"
+	"reflect"
+	"strconv"
+	"time"
+)
+
+// inferLayouts are the time layouts tried, in order, by infer.
+var inferLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04:05",
+	"2006-01-02",
+}
+
+// infer returns f as the first of int64, float64, bool, or time.Time
+// that it parses as, or f itself if it parses as none of them.
+// Single-letter booleans ("t", "F") and non-finite floats ("NaN", "Inf")
+// are left as strings, since they are more often words than values.
+func infer(f string) interface{} {
+	if n, err := strconv.ParseInt(f, 10, 64); err == nil {
+		return n
+	}
+	if n, err := strconv.ParseFloat(f, 64); err == nil && !math.IsInf(n, 0) && !math.IsNaN(n) {
+		return n
+	}
+	if len(f) > 1 {
+		if b, err := strconv.ParseBool(f); err == nil {
+			return b
+		}
+	}
+	for _, layout := range inferLayouts {
+		if t, err := time.Parse(layout, f); err == nil {
+			return t
+		}
+	}
+	return f
+}
+
+// isAny reports whether t is an interface type with no methods,
+// whose values are inferred by modInterface.
+func isAny(t reflect.Type) bool {
+	return t.Kind() == reflect.Interface && t.NumMethod() == 0
+}
+
+// modInterfaceText sets an interface{} to f itself, without inferring
+// what else it might be.
+func modInterfaceText(v *reflect.Value, f string) error {
+	v.Set(reflect.ValueOf(f))
+	return nil
+}
+
+func modInterface(v *reflect.Value, f string) error {
+	if v.NumMethod() != 0 {
+		de := decodeError(v.Type().String())
+		de.Value = f
+		return de
+	}
+	v.Set(reflect.ValueOf(infer(f)))
+	return nil
+}
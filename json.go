@@ -0,0 +1,139 @@
+// © 2014 Steve McCoy.
+
+package table
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"reflect"
+)
+
+// A CellType is the type of the value in a cell, for FieldWriters of
+// formats that distinguish types, such as JSON.
+type CellType int
+
+const (
+	StringCell CellType = iota // text
+	NumberCell                 // a number, from a field of a numeric Kind
+	BoolCell                   // a boolean, from a field of Kind bool
+	NullCell                   // no value, from a nil pointer or interface
+)
+
+// A TypedFieldWriter is a FieldWriter that can also be given the type
+// of each cell of a record. An Encoder calls WriteTyped instead of Write
+// if its FieldWriter is a TypedFieldWriter.
+type TypedFieldWriter interface {
+	FieldWriter
+	WriteTyped(record []string, types []CellType) error
+}
+
+// A JSONArrayWriter is a TypedFieldWriter that writes each record as
+// a JSON array on a line of its own, as in JSON Lines:
+//
+//	[1,"blonde",true]
+//
+// Cells are written as JSON numbers, booleans, or null according to
+// their types, when their text allows it, and otherwise as strings.
+type JSONArrayWriter struct {
+	w io.Writer
+}
+
+// NewJSONArrayWriter returns a JSONArrayWriter that writes to w.
+func NewJSONArrayWriter(w io.Writer) *JSONArrayWriter {
+	return &JSONArrayWriter{w: w}
+}
+
+// Write writes record as an array of strings.
+func (j *JSONArrayWriter) Write(record []string) error {
+	return j.WriteTyped(record, nil)
+}
+
+// WriteTyped writes record as an array of values of the given types.
+// Cells with no type are strings.
+func (j *JSONArrayWriter) WriteTyped(record []string, types []CellType) error {
+	var b bytes.Buffer
+	enc := json.NewEncoder(&b)
+	enc.SetEscapeHTML(false)
+	b.WriteByte('[')
+	for i, cell := range record {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		t := StringCell
+		if i < len(types) {
+			t = types[i]
+		}
+		switch {
+		case t == NullCell:
+			b.WriteString("null")
+		case t == BoolCell && (cell == "true" || cell == "false"),
+			t == NumberCell && isJSONNumber(cell):
+			b.WriteString(cell)
+		default:
+			if err := enc.Encode(cell); err != nil {
+				return err
+			}
+			b.Truncate(b.Len() - 1) // Encode's newline
+		}
+	}
+	b.WriteString("]\n")
+	_, err := j.w.Write(b.Bytes())
+	return err
+}
+
+// isJSONNumber reports whether s is a number in JSON's syntax.
+func isJSONNumber(s string) bool {
+	return s != "" && (s[0] == '-' || '0' <= s[0] && s[0] <= '9') && json.Valid([]byte(s))
+}
+
+// cellType returns the type of the cell for v, encoded by a field
+// whose tag is tg.
+func cellType(v reflect.Value, tg Tag) CellType {
+	if !v.IsValid() {
+		return NullCell
+	}
+	t := v.Type()
+	if isSQLNull(t) {
+		if !v.Field(1).Bool() {
+			return NullCell
+		}
+		return cellType(v.Field(0), tg)
+	}
+	if t.Implements(marshalerType) || t.Implements(textMarshalerType) {
+		if (v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface) && v.IsNil() {
+			return NullCell
+		}
+		return StringCell
+	}
+	if _, ok := tg.Lookup("locale"); ok {
+		return StringCell
+	}
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if v.IsNil() {
+			return NullCell
+		}
+		return cellType(v.Elem(), tg)
+	case reflect.Bool:
+		return BoolCell
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return NumberCell
+	}
+	return StringCell
+}
+
+// write writes row, whose cells have the given types, to e's FieldWriter.
+func (e *Encoder) write(row []string, types []CellType) error {
+	if e.EscapeControls {
+		for i, cell := range row {
+			row[i] = escapeControls(cell)
+		}
+	}
+	if tw, ok := e.w.(TypedFieldWriter); ok {
+		return tw.WriteTyped(row, types)
+	}
+	return e.w.Write(row)
+}
@@ -0,0 +1,43 @@
+// © 2014 Steve McCoy.
+
+package table
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"strings"
+)
+
+// modJSON sets v to the JSON value in f, as by json.Unmarshal.
+// An empty cell sets it to its zero value.
+func modJSON(v *reflect.Value, f string) error {
+	if f == "" {
+		v.Set(reflect.Zero(v.Type()))
+		return nil
+	}
+	p := reflect.New(v.Type())
+	if err := json.Unmarshal([]byte(f), p.Interface()); err != nil {
+		return err
+	}
+	v.Set(p.Elem())
+	return nil
+}
+
+// formatJSON returns v as JSON, as by json.Marshal, but without escaping
+// HTML. A nil pointer, slice, map, or interface is an empty cell.
+func formatJSON(v reflect.Value) (string, error) {
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Slice, reflect.Map, reflect.Interface:
+		if v.IsNil() {
+			return "", nil
+		}
+	}
+	var b bytes.Buffer
+	enc := json.NewEncoder(&b)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(v.Interface()); err != nil {
+		return "", err
+	}
+	return strings.TrimSuffix(b.String(), "\n"), nil
+}
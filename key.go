@@ -0,0 +1,234 @@
+// © 2014 Steve McCoy.
+
+package table
+
+import (
+	"encoding/binary"
+	"math"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// A Key is a byte-ordered encoding of some of the fields of a struct, as
+// returned by KeyOf. Keys of the same struct type and columns order as
+// their fields do, one after another, so they can be compared with <, ==,
+// or strings.Compare, and used as map keys, to sort, join, or diff rows.
+type Key string
+
+// KeyOf returns the Key of the struct s, or of the struct that s points
+// to, for the given columns, which are named as for Encoder.View. A column
+// prefixed with "-" is in descending order. If no columns are given, the
+// Key is of every column of s, in order.
+//
+// Booleans, numbers, strings, and times are ordered by value, with false
+// before true, and nil pointers and interfaces, and sql.NullStrings and
+// the like that are not Valid, before all else. Other fields, such as
+// those with a Combiner or of types that implement
+// encoding.TextMarshaler, are ordered by their text, as written by an
+// Encoder. The numbers held by interfaces are ordered as float64s, and
+// are before strings and after times, which are after booleans.
+//
+// A MissingColumnError is returned for a column that s has no field for,
+// and an EncodeError for a field that cannot be encoded.
+func KeyOf(s interface{}, columns ...string) (Key, error) {
+	v := reflect.ValueOf(s)
+	for v.Kind() == reflect.Ptr && !v.IsNil() {
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.Struct {
+		if !v.IsValid() {
+			return "", EncodeError("nil")
+		}
+		return "", EncodeError(v.Type().String())
+	}
+
+	e := Encoder{Format: defaultFormats}
+	plan, errs := e.plan(v.Type())
+	if len(errs) > 0 {
+		return "", errs[0]
+	}
+	if len(columns) == 0 {
+		for i := range plan {
+			if !plan[i].twin {
+				columns = append(columns, plan[i].columnName())
+			}
+		}
+	}
+
+	var b []byte
+	for _, c := range columns {
+		name, desc := strings.CutPrefix(c, "-")
+		f := viewField(plan, name)
+		if f == nil {
+			return "", MissingColumnError{name, name}
+		}
+		start := len(b)
+		var err error
+		fv, ferr := v.FieldByIndexErr(f.index)
+		if ferr == nil && embeddedPointer(v.Type(), f.index) {
+			b = append(b, 1)
+		}
+		switch {
+		case ferr != nil:
+			b = append(b, 0) // The field is in a nil embedded struct.
+		case f.rest:
+			for _, ev := range restValues(fv) {
+				b = append(b, 1)
+				if b, err = appendKey(b, ev, textOf(f.format, ev)); err != nil {
+					return "", err
+				}
+			}
+			b = append(b, 0)
+		case f.combiner.Columns > 0:
+			var cells []string
+			if cells, err = f.combiner.Split(fv); err != nil {
+				return "", err
+			}
+			for _, cell := range cells {
+				b = appendKeyString(b, cell)
+			}
+		default:
+			if b, err = appendKey(b, fv, textOf(f.format, fv)); err != nil {
+				return "", err
+			}
+		}
+		if desc {
+			for i := start; i < len(b); i++ {
+				b[i] = ^b[i]
+			}
+		}
+	}
+	return Key(b), nil
+}
+
+// embeddedPointer reports whether the field of the struct type t at the
+// index sequence index is in an embedded pointer to a struct.
+func embeddedPointer(t reflect.Type, index []int) bool {
+	for _, x := range index[:len(index)-1] {
+		t = t.Field(x).Type
+		if t.Kind() == reflect.Ptr {
+			return true
+		}
+	}
+	return false
+}
+
+// textOf returns a function that formats v with format.
+func textOf(format func(reflect.Value) (string, error), v reflect.Value) func() (string, error) {
+	return func() (string, error) {
+		return format(v)
+	}
+}
+
+// appendKey appends the Key encoding of v to b. Values of types that are
+// not ordered by value are ordered by their text, as returned by text.
+func appendKey(b []byte, v reflect.Value, text func() (string, error)) ([]byte, error) {
+	t := v.Type()
+	if t.Implements(marshalerType) || t.Implements(textMarshalerType) && t != timeType {
+		if (v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface) && v.IsNil() {
+			return append(b, 0), nil
+		}
+		s, err := text()
+		if err != nil {
+			return nil, err
+		}
+		return appendKeyString(append(b, 1), s), nil
+	}
+	if isSQLNull(t) {
+		if !v.Field(1).Bool() {
+			return append(b, 0), nil
+		}
+		return appendKey(append(b, 1), v.Field(0), text)
+	}
+	switch v.Kind() {
+	case reflect.Ptr:
+		if v.IsNil() {
+			return append(b, 0), nil
+		}
+		return appendKey(append(b, 1), v.Elem(), text)
+	case reflect.Interface:
+		if v.IsNil() {
+			return append(b, 0), nil
+		}
+		return appendAnyKey(append(b, 1), v)
+	}
+	if t == timeType {
+		return appendKeyTime(b, v.Interface().(time.Time)), nil
+	}
+	switch v.Kind() {
+	case reflect.Bool:
+		if v.Bool() {
+			return append(b, 1), nil
+		}
+		return append(b, 0), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return binary.BigEndian.AppendUint64(b, uint64(v.Int())^1<<63), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return binary.BigEndian.AppendUint64(b, v.Uint()), nil
+	case reflect.Float32, reflect.Float64:
+		return appendKeyFloat(b, v.Float()), nil
+	case reflect.String:
+		return appendKeyString(b, v.String()), nil
+	}
+	s, err := text()
+	if err != nil {
+		return nil, err
+	}
+	return appendKeyString(b, s), nil
+}
+
+// appendAnyKey appends the Key encoding of the value held by the
+// non-nil interface iface to b, after a byte that orders its class of type.
+func appendAnyKey(b []byte, iface reflect.Value) ([]byte, error) {
+	v := iface.Elem()
+	switch {
+	case v.Kind() == reflect.Bool:
+		return appendKey(append(b, 1), v, nil)
+	case v.Type() == timeType:
+		return appendKeyTime(append(b, 2), v.Interface().(time.Time)), nil
+	case isInteger(v.Kind()) && v.Kind() < reflect.Uint:
+		return appendKeyFloat(append(b, 3), float64(v.Int())), nil
+	case isInteger(v.Kind()):
+		return appendKeyFloat(append(b, 3), float64(v.Uint())), nil
+	case v.Kind() == reflect.Float32 || v.Kind() == reflect.Float64:
+		return appendKeyFloat(append(b, 3), v.Float()), nil
+	}
+	s, err := formatInterface(iface)
+	if err != nil {
+		return nil, err
+	}
+	return appendKeyString(append(b, 4), s), nil
+}
+
+// appendKeyFloat appends f to b such that the bytes order as the floats
+// do, with negative zero before positive zero.
+func appendKeyFloat(b []byte, f float64) []byte {
+	bits := math.Float64bits(f)
+	if bits&(1<<63) != 0 {
+		bits = ^bits
+	} else {
+		bits |= 1 << 63
+	}
+	return binary.BigEndian.AppendUint64(b, bits)
+}
+
+// appendKeyTime appends t to b such that the bytes order as the instants
+// do, whatever their locations.
+func appendKeyTime(b []byte, t time.Time) []byte {
+	b = binary.BigEndian.AppendUint64(b, uint64(t.Unix())^1<<63)
+	return binary.BigEndian.AppendUint32(b, uint32(t.Nanosecond()))
+}
+
+// appendKeyString appends s to b, with its zero bytes escaped as 0 0xFF,
+// and terminated by 0 1, so that a string orders before those it is a
+// prefix of, and no string's encoding is a prefix of another's.
+func appendKeyString(b []byte, s string) []byte {
+	for i := 0; i < len(s); i++ {
+		b = append(b, s[i])
+		if s[i] == 0 {
+			b = append(b, 0xFF)
+		}
+	}
+	return append(b, 0, 1)
+}
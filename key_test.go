@@ -0,0 +1,184 @@
+// © 2014 Steve McCoy.
+
+package table
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestKeyOf(t *testing.T) {
+	type X struct {
+		Name  string
+		Score float64
+		Age   *int
+		When  time.Time
+	}
+	age := func(n int) *int { return &n }
+	day := time.Date(2014, 3, 1, 0, 0, 0, 0, time.UTC)
+	xs := []X{
+		{"b", -1.5, age(3), day},
+		{"a\x00", 2, nil, day},
+		{"a", 2, age(-7), day.Add(time.Hour)},
+		{"a", 2, age(-7), day},
+		{"b", -2, nil, day},
+		{"a", 10, age(1), day},
+	}
+	sortBy := func(columns ...string) []X {
+		out := append([]X(nil), xs...)
+		sort.SliceStable(out, func(i, j int) bool {
+			ki, err := KeyOf(out[i], columns...)
+			if err != nil {
+				t.Fatal("Expected no error, got", err)
+			}
+			kj, _ := KeyOf(&out[j], columns...)
+			return ki < kj
+		})
+		return out
+	}
+
+	got := sortBy()
+	want := []int{3, 2, 5, 1, 4, 0}
+	for i, w := range want {
+		if got[i] != xs[w] {
+			t.Errorf("Sorted by every column, row %d is %+v, expected %+v", i, got[i], xs[w])
+		}
+	}
+
+	got = sortBy("Score", "-Name", "Age")
+	want = []int{4, 0, 1, 2, 3, 5}
+	for i, w := range want {
+		if got[i] != xs[w] {
+			t.Errorf("Sorted by Score, -Name, Age, row %d is %+v, expected %+v", i, got[i], xs[w])
+		}
+	}
+
+	type Y struct{ V interface{} }
+	ys := []Y{{"x"}, {int64(3)}, {nil}, {2.5}, {true}, {day}}
+	for i := 1; i < len(ys); i++ {
+		a, _ := KeyOf(ys[i-1])
+		b, _ := KeyOf(ys[i])
+		if a == b {
+			t.Errorf("Expected different keys for %v and %v", ys[i-1].V, ys[i].V)
+		}
+	}
+	k2, _ := KeyOf(Y{2})
+	k3, _ := KeyOf(Y{int64(3)})
+	kf, _ := KeyOf(Y{2.5})
+	if !(k2 < kf && kf < k3) {
+		t.Error("Expected numbers in interfaces to order by value")
+	}
+
+	if _, err := KeyOf(X{}, "Nope"); err != (MissingColumnError{"Nope", "Nope"}) {
+		t.Error("Expected a MissingColumnError, got", err)
+	}
+	if _, err := KeyOf(3); err != EncodeError("int") {
+		t.Error("Expected an EncodeError, got", err)
+	}
+}
+
+func TestShardBy(t *testing.T) {
+	type Row struct {
+		Country string
+		N       int
+	}
+	shard := ShardBy(4, "Country")
+	seen := map[string]int{}
+	counts := make([]int, 4)
+	for i := 0; i < 100; i++ {
+		r := Row{fmt.Sprint("c", i%10), i}
+		s, err := shard(&r)
+		if err != nil {
+			t.Fatal("Expected no error, got", err)
+		}
+		if s < 0 || s >= 4 {
+			t.Fatal("Expected a shard in [0, 4), got", s)
+		}
+		if prev, ok := seen[r.Country]; ok && prev != s {
+			t.Errorf("Expected %s always in shard %d, got %d", r.Country, prev, s)
+		}
+		seen[r.Country] = s
+		counts[s]++
+	}
+	for i, c := range counts {
+		if c == 0 {
+			t.Error("Expected rows in every shard, got none in", i)
+		}
+	}
+
+	part := PartitionByShard(4, "Country")
+	key, err := part(Row{"c3", 0})
+	if err != nil || key != fmt.Sprint(seen["c3"]) {
+		t.Errorf("Expected partition %d, got %q, %v", seen["c3"], key, err)
+	}
+	if _, err := shard(Row{}); err != nil {
+		t.Error("Expected no error, got", err)
+	}
+	if _, err := ShardBy(2, "Missing")(Row{}); ErrorCode(err) != CodeMissingColumn {
+		t.Error("Expected a MissingColumnError, got", err)
+	}
+}
+
+func TestShardByHash(t *testing.T) {
+	type Row struct {
+		Country string
+	}
+	seven := func(Key) uint64 { return 7 }
+	shard := ShardByHash(3, seven, "Country")
+	for _, c := range []string{"a", "b", "c", "d"} {
+		if s, err := shard(Row{c}); err != nil || s != 1 {
+			t.Errorf("Expected %s in shard 1, got %d, %v", c, s, err)
+		}
+	}
+	if key, err := PartitionByShardHash(3, seven, "Country")(Row{"d"}); err != nil || key != "1" {
+		t.Errorf("Expected partition 1, got %q, %v", key, err)
+	}
+	if Key("x").Shard(5) != Key("x").ShardHash(5, FNV64a) {
+		t.Error("Expected Shard to hash with FNV64a")
+	}
+}
+
+func TestRecordBoundaries(t *testing.T) {
+	var b strings.Builder
+	b.WriteString("name,note\n")
+	for i := range 40 {
+		fmt.Fprintf(&b, "n%d,\"a \"\"quoted\"\"\nnote %d\"\n", i, i)
+	}
+	in := b.String()
+	whole, err := csv.NewReader(strings.NewReader(in)).ReadAll()
+	if err != nil {
+		t.Fatal("Expected no error, got", err)
+	}
+
+	offsets, err := RecordBoundaries(strings.NewReader(in), int64(len(in)), 4)
+	if err != nil || len(offsets) != 4 || offsets[0] != 0 {
+		t.Fatal("Expected 4 offsets from 0, got", offsets, err)
+	}
+	var parts [][]string
+	for i, off := range offsets {
+		end := int64(len(in))
+		if i+1 < len(offsets) {
+			end = offsets[i+1]
+		}
+		r := csv.NewReader(io.NewSectionReader(strings.NewReader(in), off, end-off))
+		records, err := r.ReadAll()
+		if err != nil {
+			t.Fatal("Expected part", i, "to parse, got", err)
+		}
+		parts = append(parts, records...)
+	}
+	if !reflect.DeepEqual(parts, whole) {
+		t.Error("Expected the parts to have every record once, got", len(parts), "records")
+	}
+
+	offsets, err = RecordBoundaries(strings.NewReader("a\nb\n"), 4, 8)
+	if err != nil || !reflect.DeepEqual(offsets, []int64{0, 2}) {
+		t.Error("Expected a part for each record, got", offsets, err)
+	}
+}
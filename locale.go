@@ -0,0 +1,129 @@
+// © 2014 Steve McCoy.
+
+package table
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"sync"
+)
+
+// A Locale gives the conventions for writing numbers in some region.
+type Locale struct {
+	Decimal rune   // the decimal separator
+	Group   string // the digit group separators, any of which may appear
+}
+
+var (
+	localesMu sync.RWMutex
+	locales   = map[string]Locale{
+		"en": {Decimal: '.', Group: ","},
+		"de": {Decimal: ',', Group: "."},
+		"fr": {Decimal: ',', Group: " \u00a0\u202f"},
+		"ch": {Decimal: '.', Group: "'’"},
+	}
+)
+
+// RegisterLocale makes l available to the locale tag option, under name.
+// It replaces any Locale already registered under name.
+// RegisterLocale is safe to call concurrently.
+func RegisterLocale(name string, l Locale) {
+	localesMu.Lock()
+	defer localesMu.Unlock()
+	locales[name] = l
+	forgetLayouts()
+}
+
+// locale returns the Locale for the locale option of tg, the tag of f,
+// and tg without that option. It reports false if tg has no locale option.
+func locale(f reflect.StructField, tg Tag) (Locale, Tag, bool, error) {
+	name, ok := tg.Lookup("locale")
+	if !ok {
+		return Locale{}, tg, false, nil
+	}
+	t := f.Type
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	switch t.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+	default:
+		return Locale{}, tg, false, tagError(f, "locale applies only to numbers")
+	}
+
+	l, ok := lookupLocale(name)
+	if !ok {
+		return Locale{}, tg, false, tagError(f, "unknown locale "+name)
+	}
+
+	return l, tg.without("locale"), true, nil
+}
+
+// lookupLocale returns the Locale registered under name.
+func lookupLocale(name string) (Locale, bool) {
+	localesMu.RLock()
+	defer localesMu.RUnlock()
+	l, ok := locales[name]
+	return l, ok
+}
+
+// localizable reports whether t, or what it points to, is a number that
+// a Decoder's Locale applies to: one decoded by its Modify map or a unit,
+// rather than as a time.Duration or by a method or converter of its own.
+func localizable(t reflect.Type) bool {
+	for t.Kind() == reflect.Ptr {
+		if _, ok := converter(t); ok {
+			return false
+		}
+		t = t.Elem()
+	}
+	if _, ok := converter(t); ok {
+		return false
+	}
+	return isNumber(t.Kind()) && t != durationType && !unmarshalsText(t) &&
+		!reflect.PointerTo(t).Implements(unmarshalerType) &&
+		!reflect.PointerTo(t).Implements(contextUnmarshalerType)
+}
+
+// localize returns mod, for numbers of Kind k, wrapped to read them in
+// the conventions of d.Locale, if it names one.
+func (d *Decoder) localize(k reflect.Kind, mod func(*reflect.Value, string) error) (func(*reflect.Value, string) error, error) {
+	if d.Locale == "" || !isNumber(k) {
+		return mod, nil
+	}
+	l, ok := lookupLocale(d.Locale)
+	if !ok {
+		return nil, errors.New("unknown locale " + d.Locale)
+	}
+	return func(v *reflect.Value, s string) error {
+		return mod(v, l.parse(s))
+	}, nil
+}
+
+// parse returns the number s, written in l's conventions,
+// in the conventions of package strconv.
+func (l Locale) parse(s string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r == l.Decimal:
+			return '.'
+		case strings.ContainsRune(l.Group, r):
+			return -1
+		}
+		return r
+	}, s)
+}
+
+// format returns the number s, written in the conventions of
+// package strconv, in l's conventions, without digit groups.
+func (l Locale) format(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r == '.' {
+			return l.Decimal
+		}
+		return r
+	}, s)
+}
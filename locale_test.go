@@ -0,0 +1,234 @@
+// © 2014 Steve McCoy.
+
+package table
+
+import (
+	"bytes"
+	"encoding/csv"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLocaleTag(t *testing.T) {
+	type X struct {
+		A float64  `table:",locale=de"`
+		B float64  `table:",locale=en"`
+		C int      `table:",locale=fr"`
+		D float64  `table:",locale=de,unit=m"`
+		E *float32 `table:",locale=ch"`
+	}
+	lines := `
+"1.234,5","1,234.5",1 234 567,"1,5km",1'234.25
+`
+	dec := NewDecoder(csv.NewReader(strings.NewReader(lines)), WithStrict())
+	var x X
+	if err := dec.Decode(&x); err != nil {
+		t.Fatal("Expected no error, got", err)
+	}
+	if x.A != 1234.5 || x.B != 1234.5 || x.C != 1234567 || x.D != 1500 || x.E == nil || *x.E != 1234.25 {
+		t.Errorf("Unexpected result: %+v", x)
+	}
+
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	enc := NewEncoder(w)
+	if err := enc.Encode(x); err != nil {
+		t.Fatal("Expected no error, got", err)
+	}
+	w.Flush()
+	if want := "\"1234,5\",1234.5,1234567,1500,1234.25\n"; buf.String() != want {
+		t.Errorf("Expected %q, got %q", want, buf.String())
+	}
+
+	type Y struct {
+		A float64 `table:",locale=test"`
+	}
+	dec = NewDecoder(csv.NewReader(strings.NewReader("1_000·5\n1_000·5\n")), WithStrict())
+	var y Y
+	dec.Decode(&y) // plans Y before test is registered
+	RegisterLocale("test", Locale{Decimal: '·', Group: "_"})
+	if err := dec.Decode(&y); err != nil || y.A != 1000.5 {
+		t.Error("Expected 1000.5, got", y.A, err)
+	}
+
+	type Bad struct {
+		A string  `table:",locale=de"`
+		B float64 `table:",locale=xx"`
+	}
+	err := Check(Bad{})
+	errs, ok := err.(Errors)
+	if !ok || len(errs) != 2 {
+		t.Fatal("Expected two errors, got", err)
+	}
+	for _, err := range errs {
+		if _, ok := err.(TagError); !ok {
+			t.Error("Expected a TagError, got", err)
+		}
+	}
+}
+
+func TestDecoderLocale(t *testing.T) {
+	type X struct {
+		A float64
+		B *int
+		C float64 `table:",locale=en"`
+		D time.Duration
+		E string
+	}
+	lines := "\"3,14\",1.234.567,\"1,234.5\",1.5h,\"1.234,5\"\n"
+	dec := NewDecoder(csv.NewReader(strings.NewReader(lines)), WithStrict(), WithLocale("de"))
+	var x X
+	if err := dec.Decode(&x); err != nil {
+		t.Fatal("Expected no error, got", err)
+	}
+	if x.A != 3.14 || x.B == nil || *x.B != 1234567 || x.C != 1234.5 || x.D != 90*time.Minute || x.E != "1.234,5" {
+		t.Errorf("Unexpected result: %+v", x)
+	}
+
+	dec = NewDecoder(csv.NewReader(strings.NewReader("n,s\n1 234 567,1 2\n")),
+		WithLocale("fr"), WithKinds(map[string]reflect.Kind{"n": reflect.Int}))
+	m := map[string]interface{}{}
+	if err := dec.Decode(m); err != nil || m["n"] != 1234567 || m["s"] != "1 2" {
+		t.Error("Expected n of 1234567, got", m, err)
+	}
+
+	dec = NewDecoder(csv.NewReader(strings.NewReader("1\n")), WithLocale("xx"))
+	var y struct{ N int }
+	if err := dec.Decode(&y); ErrorCode(err) != CodeInvalidTag {
+		t.Error("Expected a TagError for an unknown locale, got", err)
+	}
+}
+
+func TestAccounting(t *testing.T) {
+	type X struct {
+		A float64 `table:",accounting"`
+		B *int    `table:",accounting,locale=en"`
+		C float64
+	}
+	lines := "(1234.56),\"(1,234)\",(5)\n7,\" ( 8 ) \",-9\n"
+	dec := NewDecoder(csv.NewReader(strings.NewReader(lines)))
+	var x X
+	if err := dec.Decode(&x); err != nil {
+		t.Fatal("Expected no error, got", err)
+	}
+	if x.A != -1234.56 || x.B == nil || *x.B != -1234 || x.C != 0 {
+		t.Errorf("Unexpected result: %+v", x)
+	}
+	if err := dec.Decode(&x); err != nil || x.A != 7 || *x.B != -8 || x.C != -9 {
+		t.Errorf("Unexpected result: %+v, %v", x, err)
+	}
+
+	dec = NewDecoder(csv.NewReader(strings.NewReader("\"(1.234,5)\",(2),(3)\n")), WithAccounting(), WithLocale("de"))
+	if err := dec.Decode(&x); err != nil || x.A != -1234.5 || *x.B != -2 || x.C != -3 {
+		t.Errorf("Unexpected result: %+v, %v", x, err)
+	}
+
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	enc := NewEncoder(w)
+	b := -1234
+	if err := enc.Encode(X{-1.5, &b, -2}); err != nil {
+		t.Fatal("Expected no error, got", err)
+	}
+	w.Flush()
+	if want := "(1.5),(1234),-2\n"; buf.String() != want {
+		t.Errorf("Expected %q, got %q", want, buf.String())
+	}
+
+	type Bad struct {
+		S string `table:",accounting"`
+	}
+	if err := Check(Bad{}); ErrorCode(err) != CodeInvalidTag {
+		t.Error("Expected a TagError for accounting on a string, got", err)
+	}
+}
+
+func TestCurrency(t *testing.T) {
+	type X struct {
+		Amount float64 `table:",currency=Unit"`
+		Unit   string
+		Fee    *int `table:",currency,accounting,locale=en"`
+	}
+	lines := "$12.50,\"(USD 1,000)\"\n-€3,5 EUR\n4 CHF,\n"
+	dec := NewDecoder(csv.NewReader(strings.NewReader(lines)))
+	var x X
+	if err := dec.Decode(&x); err != nil {
+		t.Fatal("Expected no error, got", err)
+	}
+	if x.Amount != 12.5 || x.Unit != "$" || x.Fee == nil || *x.Fee != -1000 {
+		t.Errorf("Unexpected result: %+v", x)
+	}
+	if err := dec.Decode(&x); err != nil || x.Amount != -3 || x.Unit != "€" || *x.Fee != 5 {
+		t.Errorf("Unexpected result: %+v, %v", x, err)
+	}
+	if err := dec.Decode(&x); err != nil || x.Amount != 4 || x.Unit != "CHF" || x.Fee != nil {
+		t.Errorf("Unexpected result: %+v, %v", x, err)
+	}
+
+	type Bad struct {
+		S string `table:",currency"`
+	}
+	if err := Check(Bad{}); ErrorCode(err) != CodeInvalidTag {
+		t.Error("Expected a TagError for currency on a string, got", err)
+	}
+	type BadSibling struct {
+		A float64 `table:",currency=B"`
+		B int
+	}
+	if err := Check(BadSibling{}); ErrorCode(err) != CodeInvalidTag {
+		t.Error("Expected a TagError for a currency field that is not a string, got", err)
+	}
+}
+
+func TestPercent(t *testing.T) {
+	type X struct {
+		A float64  `table:",percent"`
+		B *float32 `table:",percent,locale=de"`
+		C int      `table:",percent=whole"`
+		D float64  `table:",percent,accounting"`
+	}
+	lines := "12.5%,\"7,5 %\",40%,(0.07%)\n3,,100,1e-3%\n"
+	dec := NewDecoder(csv.NewReader(strings.NewReader(lines)))
+	var x X
+	if err := dec.Decode(&x); err != nil {
+		t.Fatal("Expected no error, got", err)
+	}
+	if x.A != 0.125 || x.B == nil || *x.B != 0.075 || x.C != 40 || x.D != -0.0007 {
+		t.Errorf("Unexpected result: %+v", x)
+	}
+	if err := dec.Decode(&x); err != nil || x.A != 0.03 || x.B != nil || x.C != 100 || x.D != 1e-5 {
+		t.Errorf("Unexpected result: %+v, %v", x, err)
+	}
+
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	enc := NewEncoder(w)
+	b := float32(0.075)
+	if err := enc.Encode(X{0.07, &b, 40, -0.125}); err != nil {
+		t.Fatal("Expected no error, got", err)
+	}
+	if err := enc.Encode(X{1, nil, -3, 1e-7}); err != nil {
+		t.Fatal("Expected no error, got", err)
+	}
+	w.Flush()
+	if want := "7%,\"7,5%\",40%,(12.5%)\n100%,,-3%,1e-05%\n"; buf.String() != want {
+		t.Errorf("Expected %q, got %q", want, buf.String())
+	}
+
+	type Bad struct {
+		A int     `table:",percent"`
+		B float64 `table:",percent=half"`
+		C string  `table:",percent=whole"`
+	}
+	errs, ok := Check(Bad{}).(Errors)
+	if !ok || len(errs) != 3 {
+		t.Fatal("Expected three errors, got", errs)
+	}
+	for _, err := range errs {
+		if ErrorCode(err) != CodeInvalidTag {
+			t.Error("Expected a TagError, got", err)
+		}
+	}
+}
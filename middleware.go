@@ -0,0 +1,24 @@
+// © 2014 Steve McCoy.
+
+package table
+
+import (
+	"reflect"
+)
+
+// A Middleware wraps next, the function that decodes the struct field f,
+// such as the function for its Kind in a Decoder's Modify map. It may
+// return next itself, to leave a field alone, or a function that does
+// something before or after calling next, such as timing it or adding
+// context to its errors. For a map column, f has only a Name, which is
+// the column's, and a Type.
+type Middleware func(f reflect.StructField, next func(*reflect.Value, string) error) func(*reflect.Value, string) error
+
+// wrap returns mod, the function that decodes f, wrapped in d.Middleware.
+// The first Middleware is the outermost.
+func (d *Decoder) wrap(f reflect.StructField, mod func(*reflect.Value, string) error) func(*reflect.Value, string) error {
+	for i := len(d.Middleware) - 1; i >= 0; i-- {
+		mod = d.Middleware[i](f, mod)
+	}
+	return mod
+}
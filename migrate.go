@@ -0,0 +1,66 @@
+// © 2014 Steve McCoy.
+
+package table
+
+// A Migration describes how the columns of files written under an earlier
+// version of a schema map onto the current one, so that one struct type
+// can decode files of every version. A Decoder applies the first of its
+// Migrations that matches its Header, and none if none does.
+type Migration struct {
+	// Version names the earlier schema, as returned by Decoder.Version.
+	Version string
+
+	// Renames maps the names of columns in files of the version
+	// to their current names.
+	Renames map[string]string
+
+	// Convert maps current column names to Transformers that rewrite
+	// the cells of files of the version into the current format,
+	// before any of the Decoder's Transform.
+	Convert map[string]Transformer
+
+	// Detect reports whether a file whose Header is header is of the
+	// version. If it is nil, files whose Headers have every old name in
+	// Renames are.
+	Detect func(header []string) bool
+}
+
+// matches reports whether a file whose Header is header is of m's version.
+func (m *Migration) matches(header []string) bool {
+	if m.Detect != nil {
+		return m.Detect(header)
+	}
+	if len(m.Renames) == 0 {
+		return false
+	}
+	for old := range m.Renames {
+		if !containsString(header, old) {
+			return false
+		}
+	}
+	return true
+}
+
+// migration returns the first of d.Migrations that matches header, or nil.
+func (d *Decoder) migration(header []string) *Migration {
+	for i := range d.Migrations {
+		if m := &d.Migrations[i]; m.matches(header) {
+			return m
+		}
+	}
+	return nil
+}
+
+// Version returns the Version of the Migration that d applies to the
+// columns of its Header, or "" if it applies none or the Header is not
+// known yet.
+func (d *Decoder) Version() string {
+	if d.Header == nil {
+		return ""
+	}
+	r, err := d.resolve()
+	if err != nil || r.migration == nil {
+		return ""
+	}
+	return r.migration.Version
+}
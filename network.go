@@ -0,0 +1,60 @@
+// © 2014 Steve McCoy.
+
+package table
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"reflect"
+)
+
+var (
+	ipNetType = reflect.TypeOf(net.IPNet{})
+	urlType   = reflect.TypeOf(url.URL{})
+)
+
+// isNetwork reports whether t is net.IPNet or url.URL, which have no
+// text methods of their own, but are decoded and encoded as text.
+// (net.IP and the types of package net/netip have them.)
+func isNetwork(t reflect.Type) bool {
+	return t == ipNetType || t == urlType
+}
+
+// modNetwork sets v, a net.IPNet or url.URL, to f, as parsed by
+// net.ParseCIDR or url.Parse. An empty cell sets either to its zero value.
+func modNetwork(v *reflect.Value, f string) error {
+	if f == "" {
+		v.Set(reflect.Zero(v.Type()))
+		return nil
+	}
+	if v.Type() == ipNetType {
+		_, n, err := net.ParseCIDR(f)
+		if err != nil {
+			return err
+		}
+		v.Set(reflect.ValueOf(*n))
+		return nil
+	}
+	u, err := url.Parse(f)
+	if err != nil {
+		return err
+	}
+	v.Set(reflect.ValueOf(*u))
+	return nil
+}
+
+// formatNetwork formats v, a net.IPNet or url.URL, which need not be
+// addressable, with its String method. A net.IPNet with no IP is
+// written as an empty cell.
+func formatNetwork(v reflect.Value) (string, error) {
+	if n, ok := v.Interface().(net.IPNet); ok && n.IP == nil {
+		return "", nil
+	}
+	if !v.CanAddr() {
+		p := reflect.New(v.Type()).Elem()
+		p.Set(v)
+		v = p
+	}
+	return v.Addr().Interface().(fmt.Stringer).String(), nil
+}
@@ -0,0 +1,62 @@
+// © 2014 Steve McCoy.
+
+package table
+
+import (
+	"errors"
+	"math"
+	"reflect"
+	"strconv"
+)
+
+// A NonFinitePolicy says what a Decoder does with cells such as "NaN",
+// "Inf", and "-Inf", which strconv.ParseFloat accepts, in floating-point
+// fields and columns, and what an Encoder does with such values.
+type NonFinitePolicy int
+
+const (
+	NonFiniteAccept NonFinitePolicy = iota // they are decoded, and written as strconv.FormatFloat writes them
+	NonFiniteReject                        // they are ErrNonFinite errors
+	NonFiniteZero                          // they are decoded, and written, as 0
+)
+
+// ErrNonFinite is the error, wrapped in a *strconv.NumError, for a NaN or
+// infinite value decoded or encoded under NonFiniteReject.
+var ErrNonFinite = errors.New("value is not finite")
+
+// isFloat reports whether k is a floating-point Kind.
+func isFloat(k reflect.Kind) bool {
+	return k == reflect.Float32 || k == reflect.Float64
+}
+
+// modNonFinite returns a function that decodes floating-point numbers
+// with mod, and then applies p to those that are NaN or infinite.
+func modNonFinite(mod func(*reflect.Value, string) error, p NonFinitePolicy) func(*reflect.Value, string) error {
+	return func(v *reflect.Value, f string) error {
+		if err := mod(v, f); err != nil {
+			return err
+		}
+		if n := v.Float(); !math.IsNaN(n) && !math.IsInf(n, 0) {
+			return nil
+		}
+		v.SetFloat(0)
+		if p == NonFiniteReject {
+			return &strconv.NumError{Func: "ParseFloat", Num: f, Err: ErrNonFinite}
+		}
+		return nil
+	}
+}
+
+// formatNonFinite returns a function that formats floating-point numbers
+// with fm, after applying p to those that are NaN or infinite.
+func formatNonFinite(fm func(reflect.Value) (string, error), p NonFinitePolicy) func(reflect.Value) (string, error) {
+	return func(v reflect.Value) (string, error) {
+		if n := v.Float(); !math.IsNaN(n) && !math.IsInf(n, 0) {
+			return fm(v)
+		}
+		if p == NonFiniteReject {
+			return "", &strconv.NumError{Func: "FormatFloat", Num: strconv.FormatFloat(v.Float(), 'g', -1, 64), Err: ErrNonFinite}
+		}
+		return fm(reflect.Zero(v.Type()))
+	}
+}
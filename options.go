@@ -0,0 +1,330 @@
+// © 2014 Steve McCoy.
+
+package table
+
+import (
+	"context"
+	"reflect"
+	"time"
+)
+
+// An Option configures a Decoder. Options are applied in order by
+// NewDecoder and Check.
+type Option func(*Decoder)
+
+// WithModify sets the function that decodes values of Kind k.
+// Unlike assigning to d.Modify[k], it copies the Modify map first, so
+// that it does not affect other Decoders given the same map.
+func WithModify(k reflect.Kind, f func(*reflect.Value, string) error) Option {
+	return func(d *Decoder) {
+		mods := make(map[reflect.Kind]func(*reflect.Value, string) error, len(d.Modify)+1)
+		for k, f := range d.Modify {
+			mods[k] = f
+		}
+		mods[k] = f
+		d.Modify = mods
+	}
+}
+
+// WithModifyField sets the function that decodes the named field of the
+// struct type of v, as with ModifyField.
+func WithModifyField(v interface{}, field string, f func(*reflect.Value, string) error) Option {
+	return func(d *Decoder) {
+		d.ModifyField(v, field, f)
+	}
+}
+
+// WithHeader sets the Decoder's Header.
+func WithHeader(header ...string) Option {
+	return func(d *Decoder) {
+		d.Header = header
+	}
+}
+
+// WithUseHeader sets the Decoder's UseHeader.
+func WithUseHeader() Option {
+	return func(d *Decoder) {
+		d.UseHeader = true
+	}
+}
+
+// WithDuplicates sets the Decoder's Duplicates.
+func WithDuplicates(p DuplicatePolicy) Option {
+	return func(d *Decoder) {
+		d.Duplicates = p
+	}
+}
+
+// WithKinds sets the Decoder's Kinds.
+func WithKinds(kinds map[string]reflect.Kind) Option {
+	return func(d *Decoder) {
+		d.Kinds = kinds
+	}
+}
+
+// WithFormatter sets the Decoder's Formatter.
+func WithFormatter(f Formatter) Option {
+	return func(d *Decoder) {
+		d.Formatter = f
+	}
+}
+
+// WithRateLimit sets the Decoder's RateLimit, in rows per second.
+func WithRateLimit(rowsPerSecond float64) Option {
+	return func(d *Decoder) {
+		d.RateLimit = rowsPerSecond
+	}
+}
+
+// WithRelativeDates sets the Decoder's RelativeDates.
+func WithRelativeDates() Option {
+	return func(d *Decoder) {
+		d.RelativeDates = true
+	}
+}
+
+// WithClock sets the Decoder's Clock.
+func WithClock(c Clock) Option {
+	return func(d *Decoder) {
+		d.Clock = c
+	}
+}
+
+// WithCoerceInts sets the Decoder's CoerceInts.
+func WithCoerceInts() Option {
+	return func(d *Decoder) {
+		d.CoerceInts = true
+	}
+}
+
+// WithRounding sets the Decoder's Rounding.
+func WithRounding(r Rounding) Option {
+	return func(d *Decoder) {
+		d.Rounding = r
+	}
+}
+
+// WithExactInts sets the Decoder's ExactInts.
+func WithExactInts() Option {
+	return func(d *Decoder) {
+		d.ExactInts = true
+	}
+}
+
+// WithEmptyRows sets the Decoder's EmptyRows.
+func WithEmptyRows(p EmptyRowPolicy) Option {
+	return func(d *Decoder) {
+		d.EmptyRows = p
+	}
+}
+
+// WithTextColumns appends names to the Decoder's TextColumns.
+func WithTextColumns(names ...string) Option {
+	return func(d *Decoder) {
+		d.TextColumns = append(d.TextColumns[:len(d.TextColumns):len(d.TextColumns)], names...)
+	}
+}
+
+// WithMissingCells sets the Decoder's MissingCells.
+func WithMissingCells(p MissingCellPolicy) Option {
+	return func(d *Decoder) {
+		d.MissingCells = p
+	}
+}
+
+// WithOnBadRow sets the Decoder's OnBadRow.
+func WithOnBadRow(f func(row []string, err error)) Option {
+	return func(d *Decoder) {
+		d.OnBadRow = f
+	}
+}
+
+// WithSkipBadLines sets the Decoder's SkipBadLines.
+func WithSkipBadLines() Option {
+	return func(d *Decoder) {
+		d.SkipBadLines = true
+	}
+}
+
+// WithRowTimeout sets the Decoder's RowTimeout.
+func WithRowTimeout(t time.Duration) Option {
+	return func(d *Decoder) {
+		d.RowTimeout = t
+	}
+}
+
+// WithCopyRows sets the Decoder's CopyRows.
+func WithCopyRows() Option {
+	return func(d *Decoder) {
+		d.CopyRows = true
+	}
+}
+
+// WithAllErrors sets the Decoder's AllErrors.
+func WithAllErrors() Option {
+	return func(d *Decoder) {
+		d.AllErrors = true
+	}
+}
+
+// WithAllowLongRows sets the Decoder's AllowLongRows.
+func WithAllowLongRows() Option {
+	return func(d *Decoder) {
+		d.AllowLongRows = true
+	}
+}
+
+// WithAllowShortRows sets the Decoder's MissingCells to MissingNull,
+// so that the fields of a short row's missing cells are set to their
+// zero values, as the counterpart of WithAllowLongRows.
+func WithAllowShortRows() Option {
+	return func(d *Decoder) {
+		d.MissingCells = MissingNull
+	}
+}
+
+// WithEmptyStrings sets the Decoder's EmptyStrings.
+func WithEmptyStrings() Option {
+	return func(d *Decoder) {
+		d.EmptyStrings = true
+	}
+}
+
+// WithNulls appends tokens to the Decoder's Nulls.
+func WithNulls(tokens ...string) Option {
+	return func(d *Decoder) {
+		d.Nulls = append(d.Nulls[:len(d.Nulls):len(d.Nulls)], tokens...)
+	}
+}
+
+// WithMiddleware appends mw to the Decoder's Middleware.
+func WithMiddleware(mw ...Middleware) Option {
+	return func(d *Decoder) {
+		d.Middleware = append(d.Middleware[:len(d.Middleware):len(d.Middleware)], mw...)
+	}
+}
+
+// WithTimeLayout sets the Decoder's TimeLayout.
+func WithTimeLayout(layout string) Option {
+	return func(d *Decoder) {
+		d.TimeLayout = layout
+	}
+}
+
+// WithMaxErrors sets the Decoder's MaxErrors.
+func WithMaxErrors(n int) Option {
+	return func(d *Decoder) {
+		d.MaxErrors = n
+	}
+}
+
+// WithMaxErrorRate sets the Decoder's MaxErrorRate, a proportion
+// between 0 and 1.
+func WithMaxErrorRate(p float64) Option {
+	return func(d *Decoder) {
+		d.MaxErrorRate = p
+	}
+}
+
+// WithDateOrder sets the Decoder's DateOrder.
+func WithDateOrder(o DateOrder) Option {
+	return func(d *Decoder) {
+		d.DateOrder = o
+	}
+}
+
+// WithStrict sets the Decoder's Strict.
+func WithStrict() Option {
+	return func(d *Decoder) {
+		d.Strict = true
+	}
+}
+
+// WithValidate sets the Decoder's Validate.
+func WithValidate(validate func(ctx context.Context, v interface{}) error) Option {
+	return func(d *Decoder) {
+		d.Validate = validate
+	}
+}
+
+// WithAccounting sets the Decoder's Accounting.
+func WithAccounting() Option {
+	return func(d *Decoder) {
+		d.Accounting = true
+	}
+}
+
+// WithBoolTokens sets the Decoder's BoolTokens.
+func WithBoolTokens(tokens map[string]bool) Option {
+	return func(d *Decoder) {
+		d.BoolTokens = tokens
+	}
+}
+
+// WithPrefixedInts sets the Decoder's PrefixedInts.
+func WithPrefixedInts() Option {
+	return func(d *Decoder) {
+		d.PrefixedInts = true
+	}
+}
+
+// WithClamp sets the Decoder's Clamp.
+func WithClamp() Option {
+	return func(d *Decoder) {
+		d.Clamp = true
+	}
+}
+
+// WithOnClamp sets the Decoder's OnClamp.
+func WithOnClamp(f func(row []string, clamped []OverflowError)) Option {
+	return func(d *Decoder) {
+		d.OnClamp = f
+	}
+}
+
+// WithNonFinite sets the Decoder's NonFinite.
+func WithNonFinite(p NonFinitePolicy) Option {
+	return func(d *Decoder) {
+		d.NonFinite = p
+	}
+}
+
+// WithLocale sets the Decoder's Locale.
+func WithLocale(name string) Option {
+	return func(d *Decoder) {
+		d.Locale = name
+	}
+}
+
+// WithControlChars sets the Decoder's ControlChars.
+func WithControlChars(p ControlPolicy) Option {
+	return func(d *Decoder) {
+		d.ControlChars = p
+	}
+}
+
+// WithDuplicateRows sets the Decoder's DuplicateRows.
+func WithDuplicateRows(p DuplicateRowPolicy) Option {
+	return func(d *Decoder) {
+		d.DuplicateRows = p
+	}
+}
+
+// WithMigrations appends ms to the Decoder's Migrations.
+func WithMigrations(ms ...Migration) Option {
+	return func(d *Decoder) {
+		d.Migrations = append(d.Migrations[:len(d.Migrations):len(d.Migrations)], ms...)
+	}
+}
+
+// WithTransform appends ts to the Transformers for the named column.
+func WithTransform(column string, ts ...Transformer) Option {
+	return func(d *Decoder) {
+		transform := make(map[string][]Transformer, len(d.Transform)+1)
+		for k, v := range d.Transform {
+			transform[k] = v
+		}
+		transform[column] = append(transform[column][:len(transform[column]):len(transform[column])], ts...)
+		d.Transform = transform
+	}
+}
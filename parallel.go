@@ -0,0 +1,242 @@
+// © 2014 Steve McCoy.
+
+package table
+
+import (
+	"io"
+	"reflect"
+	"runtime"
+	"slices"
+	"sync"
+)
+
+// DecodeParallel decodes every remaining row from d into a T, a struct,
+// as DecodeAll does, but reads the rows from d's FieldReader on one
+// goroutine and decodes them on as many others as workers says, so that
+// rows whose cells are costly to parse, such as those of many numbers,
+// are decoded on several CPUs at once. If workers is less than 1, there
+// is one for each of GOMAXPROCS. If ordered is set, the rows are returned
+// in the order they were read; if not, they are returned as they are
+// decoded, so that none waits for a slower one read before it.
+//
+// Bad rows are handled as by Decode, with OnBadRow, MaxErrors, and
+// MaxErrorRate, except that if DecodeParallel stops at an error, the rows
+// read ahead of the bad one are lost. If ordered is set, the rows returned
+// are those before the bad one; if not, they are those decoded before it
+// was. OnBadRow and OnClamp are never called concurrently, but the
+// functions that decode fields, such as those of d.Modify, d.ModifyFields,
+// and d.Middleware, and d.Validate, are, and must be safe for it.
+func DecodeParallel[T any](d *Decoder, workers int, ordered bool) ([]T, error) {
+	t := reflect.TypeOf((*T)(nil)).Elem()
+	if t.Kind() != reflect.Struct {
+		return nil, InvalidDecodeError{reflect.PointerTo(t)}
+	}
+	if workers < 1 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+	if d.budget.exceeded != nil {
+		return nil, d.format(d.budget.exceeded)
+	}
+	if d.UseHeader {
+		if err := d.readHeader(); err == io.EOF {
+			return nil, nil
+		} else if err != nil {
+			return nil, d.format(err)
+		}
+	}
+
+	var mu sync.Mutex // held while OnBadRow or OnClamp is called
+	if f := d.OnBadRow; f != nil {
+		d.OnBadRow = func(row []string, err error) {
+			mu.Lock()
+			defer mu.Unlock()
+			f(row, err)
+		}
+		defer func() { d.OnBadRow = f }()
+	}
+
+	jobs := make(chan parallelRow, workers)
+	results := make(chan parallelResult[T], workers)
+	stop := make(chan struct{})
+	var wg sync.WaitGroup
+	for range workers {
+		w := d.worker(&mu)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for row := range jobs {
+				results <- decodeParallel[T](w, row)
+			}
+		}()
+	}
+	go d.readParallel(jobs, stop)
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+
+	var all []T
+	var err error
+	end := false
+	pending := map[int]parallelResult[T]{}
+	next := 0
+	for r := range results {
+		if err != nil {
+			continue // draining after an error
+		}
+		var e bool
+		if !ordered {
+			all, e, err = settle(d, all, r)
+			end = end || e
+		} else {
+			pending[r.index] = r
+			for r, ok := pending[next]; ok && err == nil; r, ok = pending[next] {
+				delete(pending, next)
+				next++
+				all, e, err = settle(d, all, r)
+				end = end || e
+			}
+		}
+		if err != nil {
+			close(stop)
+		}
+	}
+	if err == nil && end && d.limited() {
+		err = d.spend(io.EOF)
+	}
+	if err == io.EOF {
+		err = nil
+	}
+	return all, err
+}
+
+// A parallelRow is a row read by DecodeParallel, to be decoded by a worker.
+type parallelRow struct {
+	index  int      // the number of the row among those read by DecodeParallel, from 0
+	fields []string // the row's cells
+	empty  bool     // whether the row is decoded as zero values
+	err    error    // the error from reading the row
+	read   bool     // whether a record was read, even with err
+	record int      // the number of the row, as by Decoder.Record
+	lines  rowLines // the line of each cell, if the FieldReader reports it
+}
+
+// A parallelResult is a row decoded by a worker of DecodeParallel.
+type parallelResult[T any] struct {
+	parallelRow
+	v       T
+	dropped bool // whether a field error was ignored
+}
+
+// readParallel reads rows from d's FieldReader and sends them to jobs,
+// until there are no more, or stop is closed.
+func (d *Decoder) readParallel(jobs chan<- parallelRow, stop <-chan struct{}) {
+	defer close(jobs)
+	for i := 0; ; i++ {
+		d.throttle()
+		before := d.records
+		fields, empty, err := d.readRow()
+		read := d.records != before
+		row := parallelRow{i, slices.Clone(fields), empty, err, read, d.records, d.lines(len(fields))}
+		select {
+		case jobs <- row:
+		case <-stop:
+			return
+		}
+		if err != nil && (!read || fatal(err)) {
+			return
+		}
+	}
+}
+
+// worker returns a copy of d that decodes the rows of a worker of
+// DecodeParallel, which calls OnClamp while holding mu.
+func (d *Decoder) worker(mu *sync.Mutex) *Decoder {
+	w := *d
+	w.plans = nil
+	w.budget = budget{}
+	w.seen = nil
+	w.pending = nil
+	if f := d.OnClamp; f != nil {
+		w.OnClamp = func(row []string, clamped []OverflowError) {
+			mu.Lock()
+			defer mu.Unlock()
+			f(row, clamped)
+		}
+	}
+	return &w
+}
+
+// decodeParallel decodes row into a T with w, a worker's Decoder.
+func decodeParallel[T any](w *Decoder, row parallelRow) parallelResult[T] {
+	r := parallelResult[T]{parallelRow: row}
+	if row.err != nil {
+		return r
+	}
+	w.r, w.row, w.records = nil, row.fields, row.record
+	if row.lines != nil {
+		w.r = row.lines
+	}
+	w.clamped, w.dropped = nil, false
+	r.err = w.decodeRow(reflect.ValueOf(&r.v).Elem(), row.fields, row.empty)
+	if r.err == nil && w.Validate != nil {
+		if verr := w.Validate(w.Context(), &r.v); verr != nil {
+			r.err = ValidationError{verr, w.records, w.line(0)}
+		}
+	}
+	r.dropped = w.dropped
+	return r
+}
+
+// settle appends the row of r to all, if it was decoded, and returns the
+// error at which DecodeParallel stops, if any, as Decode would return it.
+// It reports whether r is the end of the rows.
+func settle[T any](d *Decoder, all []T, r parallelResult[T]) ([]T, bool, error) {
+	err := r.err
+	if err == io.EOF {
+		return all, true, nil
+	}
+	if _, timeout := err.(TimeoutError); timeout || d.canceled(err) {
+		return all, false, d.format(err)
+	}
+	if d.limited() {
+		d.dropped = r.dropped
+		err = d.spend(err)
+	}
+	if err == nil {
+		return append(all, r.v), false, nil
+	}
+	if d.OnBadRow != nil && r.read && !fatal(err) {
+		d.OnBadRow(r.fields, d.format(err))
+		return all, false, nil
+	}
+	return all, false, d.format(err)
+}
+
+// rowLines gives the line on which each cell of a row begins, for a
+// worker of DecodeParallel to report, as FieldPos does.
+type rowLines []int
+
+func (rowLines) Read() ([]string, error) {
+	return nil, io.EOF
+}
+
+func (l rowLines) FieldPos(field int) (line, column int) {
+	return l[field], 0
+}
+
+// lines returns the line of each of the n cells of the record last read,
+// if d's FieldReader reports it with a FieldPos method, or else nil.
+func (d *Decoder) lines(n int) rowLines {
+	p, ok := d.r.(interface {
+		FieldPos(field int) (line, column int)
+	})
+	if !ok || n == 0 {
+		return nil
+	}
+	l := make(rowLines, n)
+	for i := range l {
+		l[i], _ = p.FieldPos(i)
+	}
+	return l
+}
@@ -0,0 +1,122 @@
+// © 2014 Steve McCoy.
+
+package table
+
+import (
+	"io"
+)
+
+// A PartitionEncoder encodes rows to one of several FieldWriters, chosen
+// by each row's key, such as one file per date or per country. Each
+// partition has its own Encoder, a copy of the one PartitionEncoder is
+// made from, and its FieldWriter is opened when its first row is encoded.
+type PartitionEncoder struct {
+	// Key returns the key of the partition of v, which is a value
+	// passed to Encode.
+	Key func(v interface{}) (string, error)
+
+	// Open returns the FieldWriter of the new partition with the
+	// given key, and the io.Closer, if any, to close after it is
+	// flushed, such as the file that the FieldWriter writes to.
+	Open func(key string) (FieldWriter, io.Closer, error)
+
+	// Headers makes each partition begin with a header, written by
+	// its Encoder's WriteHeader: the names of its view or its Columns,
+	// which are set by the partition's first row if it is a map. If the
+	// Encoder has neither, and the first row is a struct, the header is
+	// the names of the struct's columns, as bound by a Decoder with
+	// UseHeader.
+	Headers bool
+
+	enc   Encoder
+	parts map[string]*partition
+	keys  []string // keys of parts, in the order they were opened
+}
+
+type partition struct {
+	enc    Encoder
+	closer io.Closer
+}
+
+// NewPartitionEncoder returns a PartitionEncoder that encodes rows with
+// copies of e, whose own FieldWriter is not used, to the FieldWriters
+// returned by open for the keys returned by key.
+func NewPartitionEncoder(e Encoder, key func(v interface{}) (string, error), open func(key string) (FieldWriter, io.Closer, error)) *PartitionEncoder {
+	return &PartitionEncoder{Key: key, Open: open, enc: e, parts: map[string]*partition{}}
+}
+
+// Encode encodes v, as by Encoder.Encode, to the partition of its key,
+// opening the partition if it is new.
+func (p *PartitionEncoder) Encode(v interface{}) error {
+	key, err := p.Key(v)
+	if err != nil {
+		return err
+	}
+	part, ok := p.parts[key]
+	if !ok {
+		w, c, err := p.Open(key)
+		if err != nil {
+			return err
+		}
+		part = &partition{enc: p.enc, closer: c}
+		part.enc.w = w
+		p.parts[key] = part
+		p.keys = append(p.keys, key)
+		if p.Headers {
+			if err := part.enc.writeHeaderOf(v); err != nil {
+				return err
+			}
+		}
+	}
+	return part.enc.Encode(v)
+}
+
+// Keys returns the keys of the partitions opened so far, in the order
+// in which they were opened.
+func (p *PartitionEncoder) Keys() []string {
+	return append([]string(nil), p.keys...)
+}
+
+// Flush flushes the FieldWriter of each partition that can be flushed,
+// as a csv.Writer can, and returns the first error.
+func (p *PartitionEncoder) Flush() error {
+	var first error
+	for _, key := range p.keys {
+		if err := flush(p.parts[key].enc.w); err != nil && first == nil {
+			first = err
+		}
+	}
+	return first
+}
+
+// Close flushes every partition, then closes their io.Closers, and
+// returns the first error. The PartitionEncoder may be used again
+// afterwards, opening its partitions anew.
+func (p *PartitionEncoder) Close() error {
+	first := p.Flush()
+	for _, key := range p.keys {
+		if c := p.parts[key].closer; c != nil {
+			if err := c.Close(); err != nil && first == nil {
+				first = err
+			}
+		}
+	}
+	p.parts = map[string]*partition{}
+	p.keys = nil
+	return first
+}
+
+// flush flushes w, if it has a Flush method, and returns any error it
+// reports, by the method or, as for csv.Writer, by an Error method.
+func flush(w FieldWriter) error {
+	switch f := w.(type) {
+	case interface{ Flush() error }:
+		return f.Flush()
+	case interface{ Flush() }:
+		f.Flush()
+		if e, ok := w.(interface{ Error() error }); ok {
+			return e.Error()
+		}
+	}
+	return nil
+}
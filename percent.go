@@ -0,0 +1,116 @@
+// © 2014 Steve McCoy.
+
+package table
+
+import (
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// percent reports whether tg, the tag of f, has the percent option, and
+// returns tg without it, and whether the option is percent=whole, so that
+// numbers are decoded as written, rather than as fractions. It is an error
+// for a field that is not a number, or a pointer to one, or, unless the
+// option is percent=whole, a floating-point number.
+func percent(f reflect.StructField, tg Tag) (rest Tag, whole, ok bool, err error) {
+	form, ok := tg.Lookup("percent")
+	if !ok {
+		return tg, false, false, nil
+	}
+	switch form {
+	case "":
+	case "whole":
+		whole = true
+	default:
+		return tg, false, false, tagError(f, "unknown percent form "+form)
+	}
+	t := f.Type
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if !isNumber(t.Kind()) || t == durationType {
+		return tg, false, false, tagError(f, "percent applies only to numbers")
+	}
+	if !whole && t.Kind() != reflect.Float32 && t.Kind() != reflect.Float64 {
+		return tg, false, false, tagError(f, "percent applies only to floating-point numbers, unless it is percent=whole")
+	}
+	return tg.without("percent"), whole, true, nil
+}
+
+// modPercent returns a function that decodes a percentage, as in 12.5%,
+// with mod, as a fraction, 0.125, or, if whole is set, as written, 12.5.
+// The percent sign may be left out.
+func modPercent(mod func(*reflect.Value, string) error, whole bool) func(*reflect.Value, string) error {
+	return func(v *reflect.Value, f string) error {
+		f = strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(f), "%"))
+		if !whole {
+			f = shiftPoint(f, -2)
+		}
+		return mod(v, f)
+	}
+}
+
+// formatPercent returns a function that formats a number with fm as a
+// percentage: followed by a percent sign, and, unless whole is set,
+// multiplied by 100.
+func formatPercent(fm func(reflect.Value) (string, error), whole bool) func(reflect.Value) (string, error) {
+	return func(v reflect.Value) (string, error) {
+		s, err := fm(v)
+		if err != nil || s == "" {
+			return s, err
+		}
+		if !whole {
+			s = shiftPoint(s, 2)
+		}
+		return s + "%", nil
+	}
+}
+
+// shiftPoint returns the decimal number s, written in the conventions of
+// package strconv, multiplied by 10 to the power of n, by moving its
+// decimal point, or adjusting its exponent, so that no precision is lost.
+// Strings that are not such numbers are returned as they are, for the
+// function that parses them to report.
+func shiftPoint(s string, n int) string {
+	sign := ""
+	if strings.HasPrefix(s, "-") || strings.HasPrefix(s, "+") {
+		sign, s = s[:1], s[1:]
+	}
+	if i := strings.IndexAny(s, "eE"); i >= 0 {
+		exp, err := strconv.Atoi(s[i+1:])
+		if err != nil {
+			return sign + s
+		}
+		exp += n
+		es := "+"
+		if exp < 0 {
+			es, exp = "-", -exp
+		}
+		if exp < 10 {
+			es += "0"
+		}
+		return sign + s[:i+1] + es + strconv.Itoa(exp)
+	}
+	whole, frac, _ := strings.Cut(s, ".")
+	if strings.Trim(whole+frac, "0123456789") != "" || whole+frac == "" {
+		return sign + s
+	}
+	digits := whole + frac
+	point := len(whole) + n
+	for point > len(digits) {
+		digits += "0"
+	}
+	for point < 0 {
+		digits = "0" + digits
+		point++
+	}
+	whole, frac = strings.TrimLeft(digits[:point], "0"), digits[point:]
+	if whole == "" {
+		whole = "0"
+	}
+	if frac == "" {
+		return sign + whole
+	}
+	return sign + whole + "." + frac
+}
@@ -0,0 +1,687 @@
+// © 2014 Steve McCoy.
+
+package table
+
+import (
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// A field describes how one exported field of a struct is bound to
+// the columns of a row, and how it is decoded.
+type field struct {
+	index   []int  // index sequence of the field in the struct, as for FieldByIndex
+	name    string // name of the field in the struct, qualified by any nested struct's
+	prefix  string // prefix of the field's column name, from nested structs
+	column  int    // index of the field's first column in a row
+	width   int    // number of columns bound to the field
+	twin    bool   // whether the field is a Raw field sharing its twin's column
+	indexed bool   // whether the field's column is given by its tag
+	rest    bool   // whether the field is a slice or map of the rest of the columns
+
+	// currency is the index sequence of the string field that receives
+	// the currencies of the field's cells, if its tag names one.
+	currency []int
+
+	sf  reflect.StructField
+	tag Tag
+
+	// mod sets the field from its column's text.
+	// It is nil for Raw fields, which are set directly,
+	// and for fields with a Combiner.
+	mod func(*reflect.Value, string) error
+
+	// fast, if not nil, sets the field, given the struct, from its
+	// column's text, as mod would, but more quickly; see fastField.
+	fast func(reflect.Value, string) error
+
+	// format returns the field's text, when it is encoded.
+	format func(reflect.Value) (string, error)
+
+	// combiner, if its Columns is not zero, builds the field from its
+	// width columns.
+	combiner Combiner
+}
+
+// layout returns the fields of the struct type t that are bound to columns,
+// in order, along with every problem in their tags. Fields tagged
+// `table:"-"` are not bound to any column. A field whose tag gives a
+// column index is bound to that column, and the fields after it to the
+// columns that follow. The fields of an untagged embedded struct, or
+// pointer to a struct, are bound in its place, as if they were t's own.
+// So are those of a struct field tagged with a prefix option, such as
+// `table:",prefix=addr_"`, whose columns are named with the prefix:
+// addr_street, addr_city, and so on. A slice or map field tagged with the
+// rest option, which must be the last, is bound to the rest of the
+// columns, however many there are. The fields of a generated protocol
+// buffer message are named as in its .proto file, and its unexported
+// fields, XXX_ fields, oneofs, and untagged fields of other message types
+// are skipped. If strict is set,
+// unexported fields that shift the columns of later fields are reported.
+func layout(t reflect.Type, strict bool) ([]field, Errors) {
+	l := layoutState{strict: strict, bound: map[int]string{}}
+	l.walk(t, nil, "", "")
+	return l.fields, l.errs
+}
+
+// layoutState is the state of layout as it walks a struct type
+// and those embedded in it.
+type layoutState struct {
+	strict     bool
+	fields     []field
+	errs       Errors
+	col        int            // col is the index of the next column
+	bound      map[int]string // the field bound to each column, except Raw fields
+	unexported string         // the unexported field, if any, since the last exported one
+	rest       string         // the field bound to the rest of the columns, if any
+}
+
+// walk adds the fields of the struct type t, which is at the index
+// sequence index in the outermost struct, to l. The column names of t's
+// fields are prefixed with prefix, and their Go names with path.
+func (l *layoutState) walk(t reflect.Type, index []int, prefix, path string) {
+	proto := protoMessage(t)
+	siblings := currencySiblings(t)
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if flattened(f) {
+			et := f.Type
+			if et.Kind() == reflect.Ptr {
+				et = et.Elem()
+			}
+			l.walk(et, append(index[:len(index):len(index)], i), prefix, path)
+			continue
+		}
+		if f.PkgPath != "" {
+			if l.unexported == "" && !proto {
+				l.unexported = f.Name
+			}
+			continue
+		}
+		if f.Tag.Get("table") == "-" || proto && protoInternal(f) || siblings[f.Name] {
+			continue
+		}
+		if l.unexported != "" && l.strict {
+			l.errs = append(l.errs, UnexportedFieldError(l.unexported))
+		}
+		l.unexported = ""
+		if p, ok := nestedPrefix(f); ok {
+			et := f.Type
+			if et.Kind() == reflect.Ptr {
+				et = et.Elem()
+			}
+			if !fieldsOf(et) {
+				l.errs = append(l.errs, tagError(f, "prefix applies only to structs"))
+				continue
+			}
+			l.walk(et, append(index[:len(index):len(index)], i), prefix+p, path+f.Name+".")
+			continue
+		}
+		l.add(f, append(index[:len(index):len(index)], i), prefix, path)
+		if tg, err := ParseTag(f.Tag.Get("table")); err == nil {
+			cur, err := currencyField(t, f, tg)
+			if err != nil {
+				l.errs = append(l.errs, err)
+			} else if cur != nil {
+				l.fields[len(l.fields)-1].currency = append(index[:len(index):len(index)], cur...)
+			}
+		}
+	}
+}
+
+// nestedPrefix returns the prefix option of f's tag, if it has one.
+func nestedPrefix(f reflect.StructField) (string, bool) {
+	tg, err := ParseTag(f.Tag.Get("table"))
+	if err != nil {
+		return "", false
+	}
+	return tg.Lookup("prefix")
+}
+
+// flattened reports whether f is an embedded struct, or pointer to a
+// struct, whose fields are bound in its place. Embedded structs that are
+// tagged, or decoded as a whole, as time.Time, Unmarshalers, and
+// sql.Scanners are, are not; nor are pointers to unexported types,
+// which could not be allocated.
+func flattened(f reflect.StructField) bool {
+	if !f.Anonymous || f.Tag.Get("table") != "" {
+		return false
+	}
+	t := f.Type
+	if t.Kind() == reflect.Ptr {
+		if f.PkgPath != "" {
+			return false
+		}
+		t = t.Elem()
+	}
+	return fieldsOf(t)
+}
+
+// fieldsOf reports whether t is a struct type whose fields can be bound
+// in its place, rather than one decoded as a whole.
+func fieldsOf(t reflect.Type) bool {
+	if _, ok := converter(t); ok {
+		return false
+	}
+	return t.Kind() == reflect.Struct && t != timeType && t != rawType && !isNetwork(t) &&
+		!reflect.PointerTo(t).Implements(unmarshalerType) && !unmarshalsText(t) &&
+		!reflect.PointerTo(t).Implements(scannerType)
+}
+
+// add adds the exported field f, at the index sequence index, to l.
+// Its column name is prefixed with prefix, and its Go name with path.
+func (l *layoutState) add(f reflect.StructField, index []int, prefix, path string) {
+	col := l.col
+
+	tg, err := parseTag(f)
+	if err != nil {
+		l.errs = append(l.errs, err)
+	}
+	if tg.Name == "" {
+		tg.Name = protoName(f)
+	}
+	for _, o := range tg.Options {
+		if !knownTagOption(o.Key) {
+			l.errs = append(l.errs, tagError(f, "unknown option "+o.Key))
+		}
+	}
+
+	fd := field{index: index, name: path + f.Name, prefix: prefix, column: col, width: 1, sf: f, tag: tg}
+	if l.rest != "" {
+		l.errs = append(l.errs, tagError(f, "field follows "+l.rest+", which is bound to the rest of the columns"))
+	}
+	if _, ok := tg.Lookup("rest"); ok {
+		if f.Type.Kind() == reflect.Slice || f.Type.Kind() == reflect.Map && f.Type.Key().Kind() == reflect.String {
+			fd.rest = true
+			fd.width = 0
+			l.rest = fd.name
+		} else {
+			l.errs = append(l.errs, tagError(f, "rest applies only to slices and maps with string keys"))
+		}
+	}
+	idx, indexed, err := columnIndex(f, tg)
+	if err != nil {
+		l.errs = append(l.errs, err)
+	}
+	switch {
+	case indexed:
+		fd.column = idx
+		fd.indexed = true
+	case f.Type == rawType && col > 0:
+		fd.column = col - 1
+		fd.twin = true
+	}
+	if name, ok := tg.Lookup("combine"); ok {
+		c, err := combiner(f, name)
+		if err != nil {
+			l.errs = append(l.errs, err)
+		}
+		fd.combiner = c
+		fd.width = c.Columns
+	}
+	l.fields = append(l.fields, fd)
+	if fd.twin {
+		return
+	}
+	l.col = fd.column + fd.width
+
+	if f.Type == rawType {
+		return
+	}
+	for c := fd.column; c < l.col; c++ {
+		if other, ok := l.bound[c]; ok {
+			l.errs = append(l.errs, tagError(f, "column "+strconv.Itoa(c)+" is already bound to field "+other))
+			break
+		}
+		l.bound[c] = fd.name
+	}
+}
+
+// columnIndex returns the column index given by tg, the tag of f,
+// and whether it gives one. The index is given by an index option,
+// by a name of the form "index=N", or by a name that is a number.
+func columnIndex(f reflect.StructField, tg Tag) (int, bool, error) {
+	s, ok := explicitIndex(tg)
+	if !ok {
+		if tg.Name == "" || strings.Trim(tg.Name, "0123456789") != "" {
+			return 0, false, nil
+		}
+		s = tg.Name
+	}
+	i, err := strconv.Atoi(s)
+	if err != nil || i < 0 {
+		return 0, false, tagError(f, "bad column index "+s)
+	}
+	return i, true, nil
+}
+
+// explicitIndex returns the column index that tg spells out as
+// an index, rather than as a name that might be a header's.
+func explicitIndex(tg Tag) (string, bool) {
+	if s, ok := tg.Lookup("index"); ok {
+		return s, true
+	}
+	return strings.CutPrefix(tg.Name, "index=")
+}
+
+// sparse reports whether plan has fields bound to columns by index,
+// in which case not every column need be bound.
+func sparse(plan []field) bool {
+	for _, f := range plan {
+		if f.indexed {
+			return true
+		}
+	}
+	return false
+}
+
+// plan returns the fields of the struct type t that are decoded, in order,
+// along with every problem that would prevent t from being decoded.
+// The plan is made once for each type, and kept for the rows that
+// follow, unless d's Header changes, or a tag option, Combiner,
+// converter, UnitTable, or Locale is registered.
+func (d *Decoder) plan(t reflect.Type) ([]field, Errors) {
+	cleared := layoutsCleared.Load()
+	if p, ok := d.plans[t]; ok && p.cleared == cleared && (!d.UseHeader || sameStrings(p.header, d.Header)) {
+		return p.fields, p.errs
+	}
+	plan, errs := d.makePlan(t)
+	if d.plans == nil {
+		d.plans = map[reflect.Type]*decoderPlan{}
+	}
+	d.plans[t] = &decoderPlan{plan, errs, d.Header, cleared}
+	return plan, errs
+}
+
+// A decoderPlan is a plan made by a Decoder, which it keeps for the
+// rows of the same type that follow.
+type decoderPlan struct {
+	fields  []field
+	errs    Errors
+	header  []string // the Header to whose columns the fields are bound
+	cleared uint64   // the value of layoutsCleared when the plan was made
+}
+
+// makePlan returns the plan for decoding the struct type t, and any
+// problems that prevent it from being decoded, as plan does, without
+// the plans that d keeps.
+func (d *Decoder) makePlan(t reflect.Type) ([]field, Errors) {
+	plan, errs := cachedLayout(t, d.Strict && !d.UseHeader)
+	if d.UseHeader && d.Header != nil {
+		errs = append(errs, d.bindNames(plan)...)
+	}
+	for i := range plan {
+		f := &plan[i]
+		if f.sf.Type == rawType || f.combiner.Columns > 0 {
+			continue
+		}
+		if m, ok := d.ModifyFields[t][f.name]; ok {
+			f.mod = d.wrap(f.sf, m)
+			continue
+		}
+		sf := f.elem()
+		tg := f.tag
+		if _, ok := tg.Lookup("text"); !ok && isAny(sf.Type) && d.textColumn(f.columnName()) {
+			tg.Options = append(tg.Options[:len(tg.Options):len(tg.Options)], TagOption{Key: "text"})
+		}
+		if _, ok := tg.Lookup("locale"); !ok && d.Locale != "" && localizable(sf.Type) {
+			tg.Options = append(tg.Options[:len(tg.Options):len(tg.Options)], TagOption{Key: "locale", Value: d.Locale})
+		}
+		if _, ok := tg.Lookup("accounting"); !ok && d.Accounting && localizable(sf.Type) {
+			tg.Options = append(tg.Options[:len(tg.Options):len(tg.Options)], TagOption{Key: "accounting"})
+		}
+		m, err := d.modifier(sf, tg)
+		if de, ok := err.(DecodeError); ok {
+			de.Field, de.Column = f.name, f.column
+			err = de
+		}
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		f.mod = d.wrap(sf, m)
+		if k := sf.Type.Kind(); !f.rest && len(d.Middleware) == 0 && sameFunc(m, defaultMods[k]) {
+			f.fast = fastField(t, f.index, k)
+		}
+	}
+	return plan, errs
+}
+
+// sameFunc reports whether the functions f and g are the same function.
+func sameFunc(f, g func(*reflect.Value, string) error) bool {
+	return f != nil && g != nil && reflect.ValueOf(f).Pointer() == reflect.ValueOf(g).Pointer()
+}
+
+// bindNames binds the fields of plan to the columns of d.Header by name.
+func (d *Decoder) bindNames(plan []field) Errors {
+	r, err := d.resolve()
+	if err != nil {
+		return Errors{err}
+	}
+	var errs Errors
+	for i := range plan {
+		f := &plan[i]
+		if _, ok := explicitIndex(f.tag); ok {
+			continue
+		}
+		if f.rest {
+			f.column = 0 // It takes the columns that no other field does.
+			continue
+		}
+		if f.twin && f.tag.Name == "" {
+			f.column = plan[i-1].column + plan[i-1].width - 1
+			continue
+		}
+		col, ok := r.column(f.columnName(), f.tag.Name == "")
+		if !ok {
+			errs = append(errs, MissingColumnError{f.name, f.columnName()})
+			continue
+		}
+		f.column = col
+		f.twin = false
+		if col+f.width > len(r.names) {
+			errs = append(errs, MissingColumnError{f.name, f.columnName()})
+		}
+	}
+	return errs
+}
+
+// columnName returns the name of the column that f is bound to
+// by name: the name in its tag, or its Go name, after the prefix of any
+// nested struct it is in.
+func (f *field) columnName() string {
+	if f.tag.Name != "" && !strings.HasPrefix(f.tag.Name, "index=") {
+		return f.prefix + f.tag.Name
+	}
+	return f.prefix + f.sf.Name
+}
+
+// elem returns f's struct field, with the element type of its slice
+// or map if f is bound to the rest of the columns, so that it is decoded or
+// encoded one column at a time.
+func (f *field) elem() reflect.StructField {
+	sf := f.sf
+	if f.rest {
+		sf.Type = sf.Type.Elem()
+	}
+	return sf
+}
+
+// restColumns returns the columns of a row of n columns that are bound
+// to f, the field of plan bound to the rest of them: those from f's
+// column on that are not bound to any other field.
+func restColumns(plan []field, f *field, n int) []int {
+	var cols []int
+	for c := f.column; c < n; c++ {
+		if !boundTo(plan, c) {
+			cols = append(cols, c)
+		}
+	}
+	return cols
+}
+
+// boundTo reports whether a field of plan, other than one bound to the
+// rest of the columns, is bound to column c.
+func boundTo(plan []field, c int) bool {
+	for _, f := range plan {
+		if !f.twin && !f.rest && f.column <= c && c < f.column+f.width {
+			return true
+		}
+	}
+	return false
+}
+
+// hasRest reports whether a field of plan is bound to the rest of the
+// columns, in which case rows may have any number of columns more.
+func hasRest(plan []field) bool {
+	for _, f := range plan {
+		if f.rest {
+			return true
+		}
+	}
+	return false
+}
+
+// combinerName returns the name of f's Combiner.
+func (f *field) combinerName() string {
+	name, _ := f.tag.Lookup("combine")
+	return name
+}
+
+// modifier returns the function that decodes values of f, whose
+// `table` tag is tg. This is from d.Modify, except for time.Time,
+// time.Duration, and encoding.TextUnmarshalers, and for Unmarshalers,
+// which take precedence over all else but the json tag option and the
+// converters of RegisterConverter. A pointer is decoded as what it points
+// to, as is the value of a sql.NullString or the like. A sql.Scanner of a
+// Kind that d.Modify lacks is decoded by its Scan method.
+func (d *Decoder) modifier(f reflect.StructField, tg Tag) (func(*reflect.Value, string) error, error) {
+	t := f.Type
+	if _, ok := tg.Lookup("json"); ok {
+		return modJSON, nil
+	}
+	if m, ok := converter(t); ok {
+		return m, nil
+	}
+	if t.Kind() == reflect.Ptr {
+		ef := f
+		ef.Type = t.Elem()
+		m, err := d.modifier(ef, tg)
+		if err != nil {
+			return nil, err
+		}
+		return modPointer(m, d.EmptyStrings && ef.Type.Kind() == reflect.String), nil
+	}
+	if isSQLNull(t) {
+		ef := f
+		ef.Type = t.Field(0).Type
+		m, err := d.modifier(ef, tg)
+		if err != nil {
+			return nil, err
+		}
+		return modSQLNull(m, d.EmptyStrings && ef.Type.Kind() == reflect.String), nil
+	}
+	if t.Kind() != reflect.Interface && reflect.PointerTo(t).Implements(contextUnmarshalerType) {
+		return d.modFieldContext, nil
+	}
+	if t.Kind() != reflect.Interface && reflect.PointerTo(t).Implements(unmarshalerType) {
+		return modField, nil
+	}
+	if sp, ef, rest, ok, err := splitOf(f, tg); err != nil {
+		return nil, err
+	} else if ok {
+		m, err := d.modifier(ef, rest)
+		if err != nil {
+			return nil, err
+		}
+		return modSplit(m, sp), nil
+	}
+	if tokens, rest, ok, err := boolTokens(f, tg); err != nil {
+		return nil, err
+	} else if ok {
+		m, err := d.modifier(f, rest)
+		if err != nil {
+			return nil, err
+		}
+		return modBool(map[string]bool{tokens[0]: true, tokens[1]: false}, m), nil
+	}
+	if rest, ok, err := accounting(f, tg); err != nil {
+		return nil, err
+	} else if ok {
+		m, err := d.modifier(f, rest)
+		if err != nil {
+			return nil, err
+		}
+		return modAccounting(m), nil
+	}
+	if rest, ok, err := currency(f, tg); err != nil {
+		return nil, err
+	} else if ok {
+		m, err := d.modifier(f, rest)
+		if err != nil {
+			return nil, err
+		}
+		return modCurrency(m), nil
+	}
+	if l, rest, ok, err := locale(f, tg); err != nil {
+		return nil, err
+	} else if ok {
+		m, err := d.modifier(f, rest)
+		if err != nil {
+			return nil, err
+		}
+		return func(v *reflect.Value, s string) error {
+			return m(v, l.parse(s))
+		}, nil
+	}
+	if rest, whole, ok, err := percent(f, tg); err != nil {
+		return nil, err
+	} else if ok {
+		m, err := d.modifier(f, rest)
+		if err != nil {
+			return nil, err
+		}
+		return modPercent(m, whole), nil
+	}
+	if layout, ok, err := timeLayout(f, tg); err != nil {
+		return nil, err
+	} else if ok {
+		return modTimeWith(func(s string) (time.Time, error) { return time.Parse(layout, s) }), nil
+	}
+	if _, ok := tg.Lookup("text"); ok {
+		switch {
+		case isAny(t):
+			return modInterfaceText, nil
+		case t.Kind() != reflect.String:
+			return nil, tagError(f, "text applies only to strings and interface{}")
+		}
+	}
+	if format, ok := tg.Lookup("format"); ok {
+		if t != timeType {
+			return nil, tagError(f, "format applies only to time.Time")
+		}
+		m, ok := timeFormats[format]
+		if !ok {
+			return nil, tagError(f, "unknown time format "+format)
+		}
+		return m, nil
+	}
+	if bare, ok, err := bareUnit(f, tg); err != nil {
+		return nil, err
+	} else if ok {
+		return modDuration(bare), nil
+	}
+	rounding, round, err := d.rounding(f, tg)
+	if err != nil {
+		return nil, err
+	}
+	_, binary := tg.Lookup("binary")
+	if unit, ok := tg.Lookup("unit"); ok {
+		m, err := unitModifier(f, unit, binary, rounding)
+		if err != nil || !d.Clamp {
+			return m, err
+		}
+		return d.modClamp(m), nil
+	} else if binary {
+		return nil, tagError(f, "binary applies only to fields with a unit")
+	}
+	if base, ok, err := intBase(f, tg); err != nil {
+		return nil, err
+	} else if ok {
+		if d.Clamp {
+			return d.modClamp(modBase(base)), nil
+		}
+		return modBase(base), nil
+	}
+	if t == durationType {
+		return modDuration(""), nil
+	}
+	if t == bigFloatType {
+		return modBigFloat, nil
+	}
+	if isNetwork(t) {
+		return modNetwork, nil
+	}
+
+	if t == timeType && d.TimeLayout != "" {
+		return d.modTimeLayout, nil
+	}
+	if t == timeType {
+		return d.modTime, nil
+	}
+	if t.Kind() != reflect.Interface && unmarshalsText(t) {
+		return modText, nil
+	}
+	if t.Kind() == reflect.Interface && t.NumMethod() != 0 {
+		return nil, decodeError(t.String())
+	}
+	m, ok := d.Modify[t.Kind()]
+	if !ok && t.Kind() != reflect.Interface && reflect.PointerTo(t).Implements(scannerType) {
+		return modScan, nil
+	}
+	if !ok {
+		return nil, decodeError(t.Kind().String())
+	}
+	if d.PrefixedInts && isInteger(t.Kind()) {
+		m = modPrefixedInt(m)
+	}
+	if (d.CoerceInts || round) && isInteger(t.Kind()) {
+		m = modCoerceInt(m, rounding)
+	}
+	if t.Kind() == reflect.Bool && len(d.BoolTokens) > 0 {
+		return modBool(d.BoolTokens, m), nil
+	}
+	if d.NonFinite != NonFiniteAccept && isFloat(t.Kind()) {
+		m = modNonFinite(m, d.NonFinite)
+	}
+	if d.Clamp && isNumber(t.Kind()) {
+		return d.modClamp(m), nil
+	}
+	return m, nil
+}
+
+// modPointer returns a function that sets a pointer to nil for an empty
+// cell, unless keepEmpty is set, and otherwise to a new value set by mod.
+func modPointer(mod func(*reflect.Value, string) error, keepEmpty bool) func(*reflect.Value, string) error {
+	return func(v *reflect.Value, f string) error {
+		if f == "" && !keepEmpty {
+			v.Set(reflect.Zero(v.Type()))
+			return nil
+		}
+		p := reflect.New(v.Type().Elem())
+		e := p.Elem()
+		if err := mod(&e, f); err != nil {
+			return err
+		}
+		v.Set(p)
+		return nil
+	}
+}
+
+// fieldOf returns the field of the struct v at the index sequence index,
+// allocating the embedded structs on the way to it that are nil pointers.
+func fieldOf(v reflect.Value, index []int) reflect.Value {
+	for i, x := range index {
+		if i > 0 && v.Kind() == reflect.Ptr {
+			if v.IsNil() {
+				v.Set(reflect.New(v.Type().Elem()))
+			}
+			v = v.Elem()
+		}
+		v = v.Field(x)
+	}
+	return v
+}
+
+// columns returns the number of columns that plan binds.
+func columns(plan []field) int {
+	n := 0
+	for _, f := range plan {
+		if f.column+f.width > n {
+			n = f.column + f.width
+		}
+	}
+	return n
+}
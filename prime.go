@@ -0,0 +1,86 @@
+// © 2014 Steve McCoy.
+
+package table
+
+import (
+	"fmt"
+	"reflect"
+	"sync"
+	"sync/atomic"
+)
+
+// layouts caches the results of layout, which depend only on the struct
+// type, strictness, and the registered tag options and Combiners.
+var layouts sync.Map // layoutKey → *layoutResult
+
+type layoutKey struct {
+	t      reflect.Type
+	strict bool
+}
+
+type layoutResult struct {
+	fields []field
+	errs   Errors
+}
+
+// cachedLayout returns what layout(t, strict) does, computing it only
+// the first time. The fields are a copy, which the caller may change.
+func cachedLayout(t reflect.Type, strict bool) ([]field, Errors) {
+	k := layoutKey{t, strict}
+	r, ok := layouts.Load(k)
+	if !ok {
+		fields, errs := layout(t, strict)
+		r, _ = layouts.LoadOrStore(k, &layoutResult{fields, errs})
+	}
+	lr := r.(*layoutResult)
+	return append([]field(nil), lr.fields...), lr.errs[:len(lr.errs):len(lr.errs)]
+}
+
+// layoutsCleared counts the times the cache of layouts was emptied, so
+// that Decoders know when their plans, which are built on layouts, are
+// out of date.
+var layoutsCleared atomic.Uint64
+
+// forgetLayouts empties the cache of layouts, whose errors may be out
+// of date once a tag option, Combiner, converter, UnitTable, or Locale
+// is registered.
+func forgetLayouts() {
+	layouts.Clear()
+	layoutsCleared.Add(1)
+}
+
+// Prime prepares d to decode values of the types of vs, which may be
+// structs or pointers to structs, so that the first row of each type
+// is decoded as quickly as the rest. It is meant to be called at
+// startup by services for which the first request's latency matters.
+// The work that depends only on a type, such as parsing its fields'
+// tags, is shared by every Decoder and Encoder; the rest, such as
+// choosing the functions that decode the fields, is kept by d.
+//
+// Prime returns every problem that would prevent the types from being
+// decoded, as Check does; a nil argument is an InvalidDecodeError.
+// Types whose columns are bound by name cannot be fully checked until
+// d has read its header.
+func (d *Decoder) Prime(vs ...interface{}) error {
+	var errs Errors
+	for _, v := range vs {
+		t := reflect.TypeOf(v)
+		if t == nil {
+			errs = append(errs, InvalidDecodeError{nil})
+			continue
+		}
+		for t != nil && t.Kind() == reflect.Ptr {
+			t = t.Elem()
+		}
+		if t.Kind() != reflect.Struct {
+			errs = append(errs, decodeError(fmt.Sprint(reflect.TypeOf(v))))
+			continue
+		}
+		_, perrs := d.plan(t)
+		errs = append(errs, perrs...)
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
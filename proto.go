@@ -0,0 +1,42 @@
+// © 2014 Steve McCoy.
+
+package table
+
+import (
+	"reflect"
+	"strings"
+)
+
+// protoMessage reports whether the struct type t is a protocol buffer
+// message generated by protoc-gen-go, whose pointers have a ProtoMessage
+// method. Such structs have fields for protobuf's own use, which are not
+// bound to columns.
+func protoMessage(t reflect.Type) bool {
+	_, ok := reflect.PointerTo(t).MethodByName("ProtoMessage")
+	return ok
+}
+
+// protoInternal reports whether f, an exported field of a generated
+// message, is not bound to a column: an XXX_ field, a oneof, or, unless
+// it is tagged, a field of another message type.
+func protoInternal(f reflect.StructField) bool {
+	if strings.HasPrefix(f.Name, "XXX_") || f.Tag.Get("protobuf_oneof") != "" {
+		return true
+	}
+	t := f.Type
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return t.Kind() == reflect.Struct && protoMessage(t) && f.Tag.Get("table") == ""
+}
+
+// protoName returns the name of f in its message's .proto file, as given
+// by its protobuf tag, or "" if it has none.
+func protoName(f reflect.StructField) string {
+	for _, p := range strings.Split(f.Tag.Get("protobuf"), ",") {
+		if name, ok := strings.CutPrefix(p, "name="); ok {
+			return name
+		}
+	}
+	return ""
+}
@@ -0,0 +1,92 @@
+// © 2014 Steve McCoy.
+
+package table
+
+import (
+	"reflect"
+	"strconv"
+)
+
+// intBase returns the base given by the base option of tg, the tag of f,
+// in which its cells are written, and whether it gives one. Base 0 means
+// that of the prefix of each cell, as for strconv.ParseInt. It is an
+// error for a field that is not an integer, or a pointer to one.
+func intBase(f reflect.StructField, tg Tag) (int, bool, error) {
+	s, ok := tg.Lookup("base")
+	if !ok {
+		return 0, false, nil
+	}
+	t := f.Type
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if !isInteger(t.Kind()) || t == durationType {
+		return 0, false, tagError(f, "base applies only to integers")
+	}
+	base, err := strconv.Atoi(s)
+	if err != nil || base == 1 || base < 0 || base > 36 {
+		return 0, false, tagError(f, "base must be 0, or from 2 to 36")
+	}
+	return base, true, nil
+}
+
+// modBase returns a function that decodes integers written in base, as
+// by strconv.ParseInt.
+func modBase(base int) func(*reflect.Value, string) error {
+	return func(v *reflect.Value, f string) error {
+		if isUnsigned(v.Kind()) {
+			n, err := strconv.ParseUint(f, base, v.Type().Bits())
+			if err != nil {
+				n = 0
+			}
+			v.SetUint(n)
+			return err
+		}
+		n, err := strconv.ParseInt(f, base, v.Type().Bits())
+		if err != nil {
+			n = 0
+		}
+		v.SetInt(n)
+		return err
+	}
+}
+
+// modPrefixedInt returns a function that decodes integers with mod, or,
+// if mod cannot, with a prefix giving their base, as in 0x1F, 0o17, or
+// 0b101, and with underscores between their digits, as in 1_000.
+func modPrefixedInt(mod func(*reflect.Value, string) error) func(*reflect.Value, string) error {
+	prefixed := modBase(0)
+	return func(v *reflect.Value, f string) error {
+		err := mod(v, f)
+		if err == nil {
+			return nil
+		}
+		if perr := prefixed(v, f); perr != nil {
+			return err
+		}
+		return nil
+	}
+}
+
+// formatBase returns a function that formats integers in base, without
+// a prefix, or, for base 0, in base 10.
+func formatBase(base int) func(reflect.Value) (string, error) {
+	if base == 0 {
+		base = 10
+	}
+	return func(v reflect.Value) (string, error) {
+		if isUnsigned(v.Kind()) {
+			return strconv.FormatUint(v.Uint(), base), nil
+		}
+		return strconv.FormatInt(v.Int(), base), nil
+	}
+}
+
+// isUnsigned reports whether k is an unsigned integer Kind.
+func isUnsigned(k reflect.Kind) bool {
+	switch k {
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return true
+	}
+	return false
+}
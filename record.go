@@ -0,0 +1,51 @@
+// © 2014 Steve McCoy.
+
+package table
+
+// A Record is a row whose cells are named, for pipelines whose columns
+// are not known until run time. Unlike a map, a Record keeps its columns
+// in order.
+type Record struct {
+	Columns []string
+	Values  []interface{}
+}
+
+// Get returns the value of the named column, and whether there is one.
+func (r *Record) Get(column string) (interface{}, bool) {
+	for i, c := range r.Columns {
+		if c == column {
+			return r.Values[i], true
+		}
+	}
+	return nil, false
+}
+
+// Set sets the value of the named column, appending the column
+// if the Record does not have it.
+func (r *Record) Set(column string, value interface{}) {
+	for i, c := range r.Columns {
+		if c == column {
+			r.Values[i] = value
+			return
+		}
+	}
+	r.Columns = append(r.Columns, column)
+	r.Values = append(r.Values, value)
+}
+
+// decodeRecord decodes the next row into r, as decodeMap does into a map.
+func (d *Decoder) decodeRecord(r *Record) error {
+	m := map[string]interface{}{}
+	if err := d.decodeMap(m); err != nil {
+		return err
+	}
+	r.Columns = r.Columns[:0]
+	r.Values = r.Values[:0]
+	for _, name := range d.resolved.names {
+		if name != "" {
+			r.Columns = append(r.Columns, name)
+			r.Values = append(r.Values, m[name])
+		}
+	}
+	return nil
+}
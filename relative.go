@@ -0,0 +1,116 @@
+// © 2014 Steve McCoy.
+
+package table
+
+import (
+	"strconv"
+	"strings"
+	"time"
+)
+
+// parseRelative parses f as a date relative to now, in now's location:
+//
+//	now
+//	today, yesterday, tomorrow
+//	2 days ago, an hour ago, in 3 weeks
+//	last monday, next friday, last week, next month
+//
+// Dates are at midnight, except that "now", and seconds, minutes and hours
+// ago or to come, are relative to now itself. Case is ignored.
+func parseRelative(f string, now time.Time) (time.Time, error) {
+	words := strings.Fields(strings.ToLower(f))
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	switch len(words) {
+	case 1:
+		switch words[0] {
+		case "now":
+			return now, nil
+		case "today":
+			return today, nil
+		case "yesterday":
+			return today.AddDate(0, 0, -1), nil
+		case "tomorrow":
+			return today.AddDate(0, 0, 1), nil
+		}
+	case 2:
+		sign := 0
+		switch words[0] {
+		case "last":
+			sign = -1
+		case "next":
+			sign = 1
+		}
+		if sign == 0 {
+			break
+		}
+		if wd, ok := weekdays[words[1]]; ok {
+			days := (int(wd) - int(today.Weekday()) + 7*sign) % 7
+			if days == 0 {
+				days = 7 * sign
+			}
+			return today.AddDate(0, 0, days), nil
+		}
+		if t, ok := addUnits(now, today, words[1], sign); ok {
+			return t, nil
+		}
+	case 3:
+		sign := 0
+		n, unit := words[0], words[1]
+		switch {
+		case words[2] == "ago":
+			sign = -1
+		case words[0] == "in":
+			sign = 1
+			n, unit = words[1], words[2]
+		}
+		count, ok := relativeCount(n)
+		if sign == 0 || !ok {
+			break
+		}
+		if t, ok := addUnits(now, today, strings.TrimSuffix(unit, "s"), sign*count); ok {
+			return t, nil
+		}
+	}
+	return time.Time{}, &time.ParseError{Value: f, Message: ": not a relative date"}
+}
+
+var weekdays = map[string]time.Weekday{
+	"sunday":    time.Sunday,
+	"monday":    time.Monday,
+	"tuesday":   time.Tuesday,
+	"wednesday": time.Wednesday,
+	"thursday":  time.Thursday,
+	"friday":    time.Friday,
+	"saturday":  time.Saturday,
+}
+
+// relativeCount returns the number given by s, which is a numeral or "a" or "an".
+func relativeCount(s string) (int, bool) {
+	if s == "a" || s == "an" {
+		return 1, true
+	}
+	n, err := strconv.Atoi(s)
+	return n, err == nil && n >= 0
+}
+
+// addUnits returns n of unit added to now, for units shorter than a day,
+// or to today, for the others. It reports false for an unknown unit.
+func addUnits(now, today time.Time, unit string, n int) (time.Time, bool) {
+	switch unit {
+	case "second":
+		return now.Add(time.Duration(n) * time.Second), true
+	case "minute":
+		return now.Add(time.Duration(n) * time.Minute), true
+	case "hour":
+		return now.Add(time.Duration(n) * time.Hour), true
+	case "day":
+		return today.AddDate(0, 0, n), true
+	case "week":
+		return today.AddDate(0, 0, 7*n), true
+	case "month":
+		return today.AddDate(0, n, 0), true
+	case "year":
+		return today.AddDate(n, 0, 0), true
+	}
+	return time.Time{}, false
+}
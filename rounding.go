@@ -0,0 +1,82 @@
+// © 2014 Steve McCoy.
+
+package table
+
+import (
+	"math"
+	"reflect"
+)
+
+// A Rounding says how a Decoder sets an integer field to a number with a
+// fractional part, such as 2.7, as from a spreadsheet that writes whole
+// numbers as floats, or a cell with a unit.
+type Rounding int
+
+const (
+	RoundNearest  Rounding = iota // to the nearest integer, and halfway cases away from zero, as math.Round
+	RoundHalfEven                 // to the nearest integer, and halfway cases to the even one, as math.RoundToEven
+	RoundTruncate                 // toward zero, as math.Trunc
+	RoundFloor                    // down, as math.Floor
+	RoundCeil                     // up, as math.Ceil
+	RoundExact                    // not at all: a number with a fractional part is an ErrFraction error
+)
+
+// roundings are the names of the Roundings, for the round tag option.
+var roundings = map[string]Rounding{
+	"nearest":  RoundNearest,
+	"even":     RoundHalfEven,
+	"truncate": RoundTruncate,
+	"floor":    RoundFloor,
+	"ceil":     RoundCeil,
+	"exact":    RoundExact,
+}
+
+// round returns n rounded to an integer by r, and reports whether it may
+// be, which it may not if r is RoundExact and n has a fractional part.
+func (r Rounding) round(n float64) (float64, bool) {
+	switch r {
+	case RoundHalfEven:
+		return math.RoundToEven(n), true
+	case RoundTruncate:
+		return math.Trunc(n), true
+	case RoundFloor:
+		return math.Floor(n), true
+	case RoundCeil:
+		return math.Ceil(n), true
+	case RoundExact:
+		return n, n == math.Trunc(n)
+	}
+	return math.Round(n), true
+}
+
+// rounding returns the Rounding of integers for f, a field whose tag is
+// tg: that given by the round option of tg, as in round=truncate, or else
+// that of d, and whether the tag gives one, which lets f accept cells
+// such as "2.7" as though d.CoerceInts were set. It is an error for the
+// tag of a field that is not an integer, or a pointer to one, to give one.
+func (d *Decoder) rounding(f reflect.StructField, tg Tag) (Rounding, bool, error) {
+	name, ok := tg.Lookup("round")
+	if !ok {
+		return d.defaultRounding(), false, nil
+	}
+	t := f.Type
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if !isInteger(t.Kind()) || t == durationType {
+		return 0, false, tagError(f, "round applies only to integers")
+	}
+	r, ok := roundings[name]
+	if !ok {
+		return 0, false, tagError(f, "unknown rounding "+name)
+	}
+	return r, true, nil
+}
+
+// defaultRounding returns d.Rounding, or RoundExact if d.ExactInts is set.
+func (d *Decoder) defaultRounding() Rounding {
+	if d.ExactInts {
+		return RoundExact
+	}
+	return d.Rounding
+}
@@ -0,0 +1,97 @@
+// © 2014 Steve McCoy.
+
+package table
+
+import (
+	"reflect"
+	"sort"
+	"strconv"
+)
+
+// A Schema describes how the fields of a struct type are bound to the
+// columns of a table. It can be marshaled to JSON, so that a service can
+// publish the format of the files it accepts.
+type Schema struct {
+	Columns []SchemaColumn `json:"columns"`
+}
+
+// A SchemaColumn describes the column, or adjacent columns, bound to
+// one field of a struct.
+type SchemaColumn struct {
+	Index int    `json:"index"`         // zero-based index of the first column
+	Width int    `json:"width"`         // number of columns, which is more than 1 for a Combiner
+	Name  string `json:"name"`          // name of the column, when bound by header
+	Field string `json:"field"`         // name of the struct field
+	Type  string `json:"type"`          // Go type of the field
+	Raw   bool   `json:"raw,omitempty"` // whether the field's Raw twin also receives the column
+	Tag   string `json:"tag,omitempty"` // the field's `table` tag
+
+	// Options holds the options of the tag, such as format, layout, unit,
+	// or combine, which constrain the text of the column.
+	Options map[string]string `json:"options,omitempty"`
+}
+
+// Hash returns a hash of the format that s describes, as a string of
+// hexadecimal digits: the index, width, name, and type of each column,
+// whether it is Raw, and its options. The names of the struct fields are
+// left out, so that renaming a field does not change the hash.
+// A pipeline can store the hash of the Schema it was written for, and
+// compare it to that of the Schema it runs with.
+func (s Schema) Hash() string {
+	var parts []string
+	for _, c := range s.Columns {
+		parts = append(parts, strconv.Itoa(c.Index), strconv.Itoa(c.Width), c.Name, c.Type, strconv.FormatBool(c.Raw))
+		keys := make([]string, 0, len(c.Options))
+		for k := range c.Options {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		parts = append(parts, strconv.Itoa(len(keys)))
+		for _, k := range keys {
+			parts = append(parts, k, c.Options[k])
+		}
+	}
+	return hashStrings(parts)
+}
+
+// SchemaOf returns the Schema of the struct, or pointer to a struct, v,
+// with its columns in order. Fields whose tags are malformed are
+// described as far as they can be; Check reports such problems.
+// SchemaOf returns an empty Schema if v is not a struct.
+func SchemaOf(v interface{}) Schema {
+	t := reflect.TypeOf(v)
+	for t != nil && t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t == nil || t.Kind() != reflect.Struct {
+		return Schema{}
+	}
+
+	plan, _ := cachedLayout(t, false)
+	s := Schema{Columns: []SchemaColumn{}}
+	for _, f := range plan {
+		if f.twin {
+			s.Columns[len(s.Columns)-1].Raw = true
+			continue
+		}
+		c := SchemaColumn{
+			Index: f.column,
+			Width: f.width,
+			Name:  f.columnName(),
+			Field: f.name,
+			Type:  f.sf.Type.String(),
+			Tag:   f.sf.Tag.Get("table"),
+		}
+		for _, o := range f.tag.Options {
+			if c.Options == nil {
+				c.Options = map[string]string{}
+			}
+			c.Options[o.Key] = o.Value
+		}
+		s.Columns = append(s.Columns, c)
+	}
+	sort.SliceStable(s.Columns, func(i, j int) bool {
+		return s.Columns[i].Index < s.Columns[j].Index
+	})
+	return s
+}
@@ -0,0 +1,65 @@
+// © 2014 Steve McCoy.
+
+package table
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestSchemaOf(t *testing.T) {
+	type X struct {
+		ID      int `table:"id"`
+		IDRaw   Raw
+		Name    string
+		skip    int
+		When    time.Time `table:"when,layout=2006-01-02"`
+		Ignored string    `table:"-"`
+		At      time.Time `table:",combine=datetime"`
+		Notes   string    `table:"index=7"`
+	}
+	b, err := json.Marshal(SchemaOf(&X{}))
+	if err != nil {
+		t.Fatal("Expected no error, got", err)
+	}
+	want := `{"columns":[` +
+		`{"index":0,"width":1,"name":"id","field":"ID","type":"int","raw":true,"tag":"id"},` +
+		`{"index":1,"width":1,"name":"Name","field":"Name","type":"string"},` +
+		`{"index":2,"width":1,"name":"when","field":"When","type":"time.Time","tag":"when,layout=2006-01-02","options":{"layout":"2006-01-02"}},` +
+		`{"index":3,"width":2,"name":"At","field":"At","type":"time.Time","tag":",combine=datetime","options":{"combine":"datetime"}},` +
+		`{"index":7,"width":1,"name":"Notes","field":"Notes","type":"string","tag":"index=7"}` +
+		`]}`
+	if string(b) != want {
+		t.Errorf("Expected\n%s\ngot\n%s", want, b)
+	}
+
+	if s := SchemaOf(3); len(s.Columns) != 0 {
+		t.Error("Expected an empty Schema, got", s)
+	}
+}
+
+func TestSchemaHash(t *testing.T) {
+	type X struct {
+		ID   int       `table:"id"`
+		When time.Time `table:"when,layout=2006-01-02"`
+	}
+	type Renamed struct {
+		Key  int       `table:"id"`
+		Date time.Time `table:"when,layout=2006-01-02"`
+	}
+	type Relaid struct {
+		ID   int       `table:"id"`
+		When time.Time `table:"when,layout=02/01/2006"`
+	}
+	h := SchemaOf(X{}).Hash()
+	if len(h) != 64 {
+		t.Error("Expected a SHA-256 hash, got", h)
+	}
+	if SchemaOf(Renamed{}).Hash() != h {
+		t.Error("Renaming fields changed the hash")
+	}
+	if SchemaOf(Relaid{}).Hash() == h {
+		t.Error("Changing a layout did not change the hash")
+	}
+}
@@ -0,0 +1,128 @@
+// © 2014 Steve McCoy.
+
+package table
+
+import (
+	"bufio"
+	"hash/fnv"
+	"io"
+	"math"
+	"strconv"
+)
+
+// A Hash hashes a Key to choose its shard, as by Key.ShardHash.
+// Every worker of the same rows must use the same Hash.
+type Hash func(k Key) uint64
+
+// FNV64a is the Hash that Shard uses, the 64-bit FNV-1a hash of k.
+func FNV64a(k Key) uint64 {
+	h := fnv.New64a()
+	h.Write([]byte(k))
+	return h.Sum64()
+}
+
+// Shard returns the shard, from 0 to n-1, that k hashes to, so that
+// workers that each process one of n shards of the same rows can tell
+// which are theirs, whatever order they read them in. It panics if n
+// is not positive.
+func (k Key) Shard(n int) int {
+	return k.ShardHash(n, FNV64a)
+}
+
+// ShardHash is like Shard, but hashes k with hash, so that the shards
+// can agree with those of another system, such as a database or queue
+// partitioned by the same columns.
+func (k Key) ShardHash(n int, hash Hash) int {
+	if n <= 0 {
+		panic("table: Shard of non-positive count")
+	}
+	return int(hash(k) % uint64(n))
+}
+
+// ShardBy returns a function that returns the shard, from 0 to n-1, of a
+// struct, or pointer to one, by the Key of the given columns, as returned
+// by KeyOf. Rows with equal values in those columns are in the same shard.
+func ShardBy(n int, columns ...string) func(v interface{}) (int, error) {
+	return ShardByHash(n, FNV64a, columns...)
+}
+
+// ShardByHash is like ShardBy, but hashes the Keys with hash.
+func ShardByHash(n int, hash Hash, columns ...string) func(v interface{}) (int, error) {
+	return func(v interface{}) (int, error) {
+		k, err := KeyOf(v, columns...)
+		if err != nil {
+			return 0, err
+		}
+		return k.ShardHash(n, hash), nil
+	}
+}
+
+// PartitionByShard returns a function for a PartitionEncoder's Key that
+// puts the rows of each of n shards, as given by ShardBy, in a partition
+// whose key is the shard's number, from "0" to n-1.
+func PartitionByShard(n int, columns ...string) func(v interface{}) (string, error) {
+	return PartitionByShardHash(n, FNV64a, columns...)
+}
+
+// PartitionByShardHash is like PartitionByShard, but hashes the Keys
+// with hash, as ShardByHash does.
+func PartitionByShardHash(n int, hash Hash, columns ...string) func(v interface{}) (string, error) {
+	shard := ShardByHash(n, hash, columns...)
+	return func(v interface{}) (string, error) {
+		i, err := shard(v)
+		if err != nil {
+			return "", err
+		}
+		return strconv.Itoa(i), nil
+	}
+}
+
+// ScanRecords is a bufio.SplitFunc that splits CSV input into its raw
+// records, each with the newline that ends it, if any, so that the
+// records can be handed out to workers before they are parsed. Newlines
+// in quoted fields do not end records, as for a csv.Reader; input with
+// bare quotes, which a csv.Reader accepts only with LazyQuotes, may be
+// split in the wrong places.
+func ScanRecords(data []byte, atEOF bool) (advance int, token []byte, err error) {
+	quoted := false
+	for i, b := range data {
+		switch b {
+		case '"':
+			quoted = !quoted
+		case '\n':
+			if !quoted {
+				return i + 1, data[:i+1], nil
+			}
+		}
+	}
+	if atEOF && len(data) > 0 {
+		return len(data), data, nil
+	}
+	return 0, nil, nil
+}
+
+// RecordBoundaries returns the offsets in r, CSV input of size bytes,
+// at which each of n parts of about equal size begins, at the start of
+// a record, as found by ScanRecords, so that each part can be read and
+// decoded by a worker of its own, such as through an io.SectionReader.
+// The first offset is 0, and each part ends where the next begins, or at
+// the end of r; the first part has the header, if there is one. There
+// are fewer than n parts if r has too few records. It panics if n is not
+// positive.
+func RecordBoundaries(r io.Reader, size int64, n int) ([]int64, error) {
+	if n <= 0 {
+		panic("table: RecordBoundaries of non-positive count")
+	}
+	s := bufio.NewScanner(r)
+	s.Buffer(nil, math.MaxInt)
+	s.Split(ScanRecords)
+	offsets := []int64{0}
+	var off int64
+	for s.Scan() && len(offsets) < n {
+		off += int64(len(s.Bytes()))
+		if off < size && off >= int64(len(offsets))*size/int64(n) {
+			offsets = append(offsets, off)
+		}
+	}
+	return offsets, s.Err()
+}
@@ -0,0 +1,116 @@
+// © 2014 Steve McCoy.
+
+package table
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// A splitter says how a cell is split into the elements of a slice or
+// map field, as given by its tag's split and kv options.
+type splitter struct {
+	sep string // separates elements, or a map's pairs
+	kv  string // separates a map's keys from their values
+}
+
+// splitOf returns the splitter given by tg, the tag of the slice or map
+// field f, along with f as a field of the element type and tg without
+// the split and kv options, and whether tg has a split option.
+func splitOf(f reflect.StructField, tg Tag) (splitter, reflect.StructField, Tag, bool, error) {
+	sep, ok := tg.Lookup("split")
+	kv, hasKV := tg.Lookup("kv")
+	if !ok {
+		if hasKV {
+			return splitter{}, f, tg, false, tagError(f, "kv applies only with split")
+		}
+		return splitter{}, f, tg, false, nil
+	}
+	switch {
+	case f.Type.Kind() == reflect.Map && f.Type.Key().Kind() == reflect.String:
+		if !hasKV {
+			kv = "="
+		}
+	case f.Type.Kind() != reflect.Slice:
+		return splitter{}, f, tg, false, tagError(f, "split applies only to slices and maps with string keys")
+	case hasKV:
+		return splitter{}, f, tg, false, tagError(f, "kv applies only to maps")
+	}
+	if sep == "" || f.Type.Kind() == reflect.Map && kv == "" {
+		return splitter{}, f, tg, false, tagError(f, "split needs a separator")
+	}
+	ef := f
+	ef.Type = f.Type.Elem()
+	return splitter{sep, kv}, ef, tg.without("split").without("kv"), true, nil
+}
+
+// modSplit returns a function that sets a slice to the pieces of a cell
+// between instances of sp.sep, each decoded by mod, or a map to the
+// pairs of such pieces, split by sp.kv, whose values are decoded by mod.
+// An empty cell sets either to nil.
+func modSplit(mod func(*reflect.Value, string) error, sp splitter) func(*reflect.Value, string) error {
+	return func(v *reflect.Value, f string) error {
+		if f == "" {
+			v.Set(reflect.Zero(v.Type()))
+			return nil
+		}
+		pieces := strings.Split(f, sp.sep)
+		if v.Kind() == reflect.Map {
+			m := reflect.MakeMapWithSize(v.Type(), len(pieces))
+			for _, p := range pieces {
+				k, s, ok := strings.Cut(p, sp.kv)
+				if !ok {
+					return fmt.Errorf("no %q between key and value in %q", sp.kv, p)
+				}
+				e := reflect.New(v.Type().Elem()).Elem()
+				if err := mod(&e, s); err != nil {
+					return err
+				}
+				m.SetMapIndex(reflect.ValueOf(k).Convert(v.Type().Key()), e)
+			}
+			v.Set(m)
+			return nil
+		}
+		s := reflect.MakeSlice(v.Type(), len(pieces), len(pieces))
+		for i, p := range pieces {
+			e := s.Index(i)
+			if err := mod(&e, p); err != nil {
+				return err
+			}
+		}
+		v.Set(s)
+		return nil
+	}
+}
+
+// formatSplit returns a function that formats the elements of a slice
+// with fm, and joins them with sp.sep, or the pairs of a map, in the
+// order of their keys, with sp.kv between each key and its value.
+func formatSplit(fm func(reflect.Value) (string, error), sp splitter) func(reflect.Value) (string, error) {
+	return func(v reflect.Value) (string, error) {
+		if v.Kind() == reflect.Map {
+			keys := v.MapKeys()
+			sort.Slice(keys, func(i, j int) bool { return keys[i].String() < keys[j].String() })
+			pieces := make([]string, len(keys))
+			for i, k := range keys {
+				s, err := fm(v.MapIndex(k))
+				if err != nil {
+					return "", err
+				}
+				pieces[i] = k.String() + sp.kv + s
+			}
+			return strings.Join(pieces, sp.sep), nil
+		}
+		pieces := make([]string, v.Len())
+		for i := range pieces {
+			p, err := fm(v.Index(i))
+			if err != nil {
+				return "", err
+			}
+			pieces[i] = p
+		}
+		return strings.Join(pieces, sp.sep), nil
+	}
+}
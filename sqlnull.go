@@ -0,0 +1,56 @@
+// © 2014 Steve McCoy.
+
+package table
+
+import (
+	"database/sql"
+	"reflect"
+)
+
+var scannerType = reflect.TypeOf((*sql.Scanner)(nil)).Elem()
+
+// isSQLNull reports whether t is one of the nullable types of package
+// database/sql, such as sql.NullString or sql.Null[T], or one like them:
+// a struct whose pointer is a sql.Scanner, and whose fields are its
+// value and a bool named Valid.
+func isSQLNull(t reflect.Type) bool {
+	return t.Kind() == reflect.Struct && reflect.PointerTo(t).Implements(scannerType) &&
+		t.NumField() == 2 && t.Field(0).PkgPath == "" &&
+		t.Field(1).Name == "Valid" && t.Field(1).Type.Kind() == reflect.Bool
+}
+
+// modSQLNull returns a function that sets a nullable type of package
+// database/sql to a value decoded by mod, and makes it Valid, unless the
+// cell is empty, and keepEmpty is not set, in which case it is not Valid.
+func modSQLNull(mod func(*reflect.Value, string) error, keepEmpty bool) func(*reflect.Value, string) error {
+	return func(v *reflect.Value, f string) error {
+		v.Set(reflect.Zero(v.Type()))
+		if f == "" && !keepEmpty {
+			return nil
+		}
+		e := v.Field(0)
+		if err := mod(&e, f); err != nil {
+			return err
+		}
+		v.Field(1).SetBool(true)
+		return nil
+	}
+}
+
+// modScan sets v, whose pointer type is a sql.Scanner, by calling its
+// Scan with f.
+func modScan(v *reflect.Value, f string) error {
+	return v.Addr().Interface().(sql.Scanner).Scan(f)
+}
+
+// formatSQLNull returns a function that formats the value of a nullable
+// type of package database/sql with fm, or writes an empty cell if it
+// is not Valid.
+func formatSQLNull(fm func(reflect.Value) (string, error)) func(reflect.Value) (string, error) {
+	return func(v reflect.Value) (string, error) {
+		if !v.Field(1).Bool() {
+			return "", nil
+		}
+		return fm(v.Field(0))
+	}
+}
@@ -1,7 +1,8 @@
 // © 2014 Steve McCoy.
 
 /*
-Package table is used to decode CSV-like streams into arbitrary structs.
+Package table is used to decode CSV-like streams into arbitrary structs,
+and to encode them again.
 
 For example:
 
@@ -22,44 +23,79 @@ For example:
 		}
 		fmt.Println(x.A, x.B, x.c)
 	}
+
+Or, to decode every row at once:
+
+	xs, err := table.DecodeAll[X](dec)
+
+DecodeEvery does the same, but skips the rows it cannot decode and
+reports all of their errors at the end.
+
+Rows ranges over the rows without the io.EOF check:
+
+	for x, err := range table.Rows[X](dec) {
+		...
+	}
+
+An Encoder writes structs back out as rows:
+
+	enc := table.NewEncoder(csvWriter)
+	if err := enc.Encode(x); err != nil {
+		fmt.Fprintln(os.Stderr, "oops:", err)
+	}
+	csvWriter.Flush()
+
+The output of an Encoder is deterministic: the same values, encoded by
+Encoders with the same settings, are written as the same rows, byte for
+byte, by this version of the package and later ones, so that generated
+files can be diffed and builds reproduced. The cells of a struct are in
+the order of its fields' declarations, or of its Encoder's View; those of
+maps, whether rows, fields bound to the rest of the columns, or fields
+whose tags split them, are in the order of their keys; and a row's
+errors are reported in the same order. A change to the text of any value
+that this package formats is a change to its API.
 */
 package table
 
 import (
+	"context"
+	"crypto/sha256"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"iter"
+	"maps"
 	"reflect"
+	"slices"
 	"strconv"
+	"strings"
+	"time"
 )
 
-// RowError is returned from Decode when the number of fields in a row
-// does not equal the number of exported fields in the destination struct.
-// If there are more row fields than struct fields, MissingField will contain
-// the name of the next available field.
-type RowError struct {
-	RowLen int
-	StructLen int
-	MissingField string
-}
-
-func (r RowError) Error() string {
-	msg := "row mismatch: row length = " + strconv.Itoa(r.RowLen) +
-		", but struct length = " + strconv.Itoa(r.StructLen)
-	if r.MissingField != "" {
-		msg += " (field " + r.MissingField + ")"
-	}
-	return msg
-}
-
-// DecodeError is returned from Decode if a field is of a Kind that
-// does not have an associated function in Modify.
-type DecodeError string
+// Raw is a field type that receives the unparsed text of a cell,
+// bypassing d.Modify. A Raw field does not consume a column of its own;
+// it shares the column of the field declared before it, so that a struct
+// can hold both the parsed and original forms of a value:
+//
+//	type X struct {
+//		Price    float64
+//		PriceRaw table.Raw
+//	}
+//
+// A Raw field declared before any other exported field has no twin and
+// is bound to the next column like any other field.
+type Raw string
 
-func (d DecodeError) Error() string {
-	return string(d) + " is not decodable"
-}
+var rawType = reflect.TypeOf(Raw(""))
 
 // FieldReader represents anything that behaves similarly to
 // encoding/csv's Reader type. Any errors encoundered
 // by the reader will be immediately returned by Decode.
+// A FieldReader may reuse the slice it returns for the next row, as a
+// *csv.Reader with ReuseRecord does; a Decoder keeps no row but the
+// header, which it copies. See Decoder.CopyRows for FieldReaders that
+// reuse the memory of the cells themselves.
 type FieldReader interface {
 	Read() ([]string, error)
 }
@@ -67,79 +103,1078 @@ type FieldReader interface {
 // Decoder contains a map of functions from reflect.Kinds to 
 // functions that should set a *reflect.Value of the associated Kind
 // with the value represented by a provided string.
+//
+// A Decoder works out how to decode each struct type from its fields on
+// the first row of that type, and keeps that for the rows that follow,
+// so its fields should be set before then, as by the Options of
+// NewDecoder; ModifyField may be called at any time. In programs built
+// with the tableunsafe build tag, fields of basic Kinds that d.Modify's
+// default functions decode, without tags or Middleware that change how,
+// are set by writing to their memory directly, rather than by reflect.
 type Decoder struct {
 	Modify map[reflect.Kind]func(*reflect.Value, string)error
-	r FieldReader
+
+	// ModifyFields holds functions for particular fields of struct
+	// types, by type and then field name, which are used instead of
+	// those in Modify or chosen by the fields' tags. See ModifyField.
+	ModifyFields map[reflect.Type]map[string]func(*reflect.Value, string) error
+
+	// Header names the columns of the stream. It is used when decoding
+	// into a map, whose keys are the column names, and when UseHeader is set.
+	// If Header is nil when it is needed, the first row read is taken as the header.
+	Header []string
+
+	// UseHeader binds struct fields to the columns of Header by name,
+	// instead of by position. A field's name is the name in its `table`
+	// tag, which must match exactly, or else its Go name, which may
+	// match in any case. Columns not bound to any field are ignored.
+	// An untagged Raw field shares the column of the field declared before it.
+	UseHeader bool
+
+	// Duplicates says what to do when Header names a column more than once.
+	Duplicates DuplicatePolicy
+
+	// Kinds maps column names to the Kind that the column's values are
+	// decoded as when the destination is a map. Columns with no entry
+	// are decoded as strings.
+	Kinds map[string]reflect.Kind
+
+	// Transform maps column names to Transformers that are applied,
+	// in order, to the column's cells before they are decoded.
+	// A column's name is its entry in Header, if there is one, as resolved
+	// by Duplicates, or else the name of the struct field bound to it.
+	// Raw fields receive their cells untransformed.
+	Transform map[string][]Transformer
+
+	// Migrations describe the columns of files written under earlier
+	// versions of the schema: the first whose Header matches renames
+	// the columns of the Header, and converts their cells, to those of
+	// the current version. See Migration.
+	Migrations []Migration
+
+	// Locale names the Locale, as registered by RegisterLocale, whose
+	// conventions the numbers of every column are written in, such as
+	// "de" for 1.234,5, unless a field's tag gives its own with the
+	// locale option. It applies to numeric fields, and columns of map
+	// destinations, but not to time.Durations, or to types decoded by
+	// methods or converters of their own. If it is empty, numbers are
+	// read as strconv reads them.
+	Locale string
+
+	// Accounting makes numbers in parentheses, as in (1,234.56), be read
+	// as negatives, as they are written in financial reports, in the
+	// fields and columns that Locale applies to. A field's tag may do the
+	// same with the accounting option.
+	Accounting bool
+
+	// BoolTokens gives cells, such as "yes" and "no", that are read as
+	// the bools they map to, regardless of case, in bool fields and
+	// columns of Kind Bool, besides those that strconv.ParseBool accepts.
+	// A field's tag may give a pair of its own with the bool option, as
+	// in bool=ja/nein, the first of which is true.
+	BoolTokens map[string]bool
+
+	// PrefixedInts makes integers with a prefix giving their base, as in
+	// 0x1F, 0o17, or 0b101, or with underscores between their digits,
+	// as in 1_000, be read as strconv.ParseInt reads them with base 0, in
+	// integer fields and columns. A field's tag may instead give the base
+	// its cells are written in with the base option, as in base=16, or
+	// base=0 for prefixes.
+	PrefixedInts bool
+
+	// Clamp makes numbers out of the range of the types of their fields
+	// and columns, such as 300 for an int8, be set to the nearest bound
+	// of the type, 127, instead of causing OverflowErrors. Negative
+	// numbers are set to 0 in unsigned fields and columns.
+	Clamp bool
+
+	// OnClamp, if not nil, is called with each row that has numbers that
+	// Clamp set to bounds, and an OverflowError for each of them, after
+	// the row is decoded.
+	OnClamp func(row []string, clamped []OverflowError)
+
+	// NonFinite says what Decode does with cells such as "NaN", "Inf",
+	// and "-Inf" in floating-point fields and columns, which it otherwise
+	// decodes as strconv.ParseFloat does.
+	NonFinite NonFinitePolicy
+
+	// TextColumns names the columns, as for Transform, whose cells are
+	// decoded as strings into interface{} fields and columns of Kind
+	// Interface, instead of as the values they look like, so that IDs,
+	// postal codes, and phone numbers keep their leading zeros and digits.
+	// A field's tag may do the same with the text option.
+	TextColumns []string
+
+	// CoerceInts lets integer fields accept cells in decimal or
+	// exponent notation, such as "3.0" or "1e3", that their function in
+	// Modify rejects. Values with a fractional part are rounded by
+	// Rounding, as they are for fields with a unit, unless ExactInts is
+	// set, in which case they are an ErrFraction error, as for RoundExact.
+	// A field's tag may give its own Rounding with the round option, as in
+	// round=truncate, which also lets it accept such cells; the Roundings
+	// are named nearest, even, truncate, floor, ceil, and exact.
+	CoerceInts bool
+	ExactInts  bool
+	Rounding   Rounding
+
+	// EmptyRows says what Decode does with a row that has no fields,
+	// or whose fields are all empty. By default, such a row is decoded
+	// like any other, which typically causes a RowError or FieldErrors.
+	EmptyRows EmptyRowPolicy
+
+	// DuplicateRows says what Decode does with a row that is exactly
+	// like one read before, as from an export that was run twice. By
+	// default, such a row is decoded like any other.
+	DuplicateRows DuplicateRowPolicy
+
+	// ControlChars says what Decode does with control characters, such
+	// as newlines in quoted cells, in the cells of rows, before any
+	// Transformers. By default, they are decoded like any others.
+	ControlChars ControlPolicy
+
+	// MissingCells says what Decode does with a row that lacks trailing
+	// cells. By default, such a row causes a RowError. With MissingNull,
+	// as set by WithAllowShortRows, the fields of the missing cells are
+	// set to their zero values. Producers that write NULL by leaving
+	// cells out can be told from those that write empty strings with
+	// MissingNull and EmptyStrings.
+	MissingCells MissingCellPolicy
+
+	// OnBadRow, if not nil, is called with each row that Decode cannot
+	// decode, and the error, before Decode goes on to the next row, so
+	// that bad rows can be logged or quarantined without ending the
+	// stream. Rows with errors that Decode ignores are not bad rows.
+	// The bad rows still count against MaxErrors and MaxErrorRate.
+	OnBadRow func(row []string, err error)
+
+	// SkipBadLines makes Decode skip the lines that a *csv.Reader cannot
+	// parse, such as stray log lines or truncated records, or whose
+	// number of fields is wrong for its FieldsPerRecord, instead of
+	// returning the *csv.ParseError. The errors are passed to OnBadRow,
+	// if it is set, with the record, if any.
+	SkipBadLines bool
+
+	// CopyRows makes Decode copy each row it reads, with all of its
+	// cells in one new string, before decoding it, so that no field,
+	// error, or header holds memory of the FieldReader's. It is needed
+	// only for FieldReaders whose cells are views of a buffer that they
+	// reuse; a *csv.Reader, even with ReuseRecord, makes new cells for
+	// every row, and reuses only the slice that holds them.
+	CopyRows bool
+
+	// AllErrors makes Decode decode every field of a row, even after
+	// one fails, and return an Errors with a FieldError, or OverflowError,
+	// for each that did, along with any RowError, instead of only the
+	// first. It implies Strict's handling of errors from the functions
+	// in Modify.
+	AllErrors bool
+
+	// AllowLongRows makes Decode ignore the cells of a row after the last
+	// column it needs, instead of returning a RowError for them.
+	AllowLongRows bool
+
+	// EmptyStrings makes an empty cell set a pointer to a string to
+	// a pointer to an empty string, instead of to nil. Cells that are
+	// null, by Nulls or MissingCells, still set it to nil.
+	EmptyStrings bool
+
+	// Nulls are the cells, such as "NULL" or "N/A", that stand for no
+	// value. After any Transformers, a cell that is one of them sets its
+	// field to the zero value, or nil, and its map entry to nil, instead
+	// of being decoded. Raw fields and fields with a Combiner are
+	// unaffected.
+	Nulls []string
+
+	// Middleware wraps the functions that decode struct fields and map
+	// columns, after they are chosen from Modify or otherwise.
+	// The first Middleware is the outermost.
+	Middleware []Middleware
+
+	// Formatter, if not nil, overrides the messages of errors returned
+	// by Decode.
+	Formatter Formatter
+
+	// RateLimit, if positive, is the maximum number of rows per second
+	// that Decode returns. Decode sleeps as needed to stay within it.
+	RateLimit float64
+
+	// Strict makes errors of what is otherwise silent: errors from the
+	// functions in Modify are returned as FieldErrors, or OverflowErrors
+	// for values out of range, instead of leaving
+	// whatever the function set; and an unexported field followed
+	// by exported ones, which Decode skips without consuming a column,
+	// causes an UnexportedFieldError. Strict is recommended for new code.
+	Strict bool
+
+	// DateOrder is the order of the day and month in slash-delimited
+	// dates decoded into time.Time fields. If it is UnknownOrder, such
+	// dates are not accepted.
+	DateOrder DateOrder
+
+	// TimeLayout, if not empty, is the layout, as for time.Parse, of the
+	// cells decoded into time.Time fields, in place of the RFC 3339 and
+	// other layouts that are otherwise accepted.
+	TimeLayout string
+
+	// RelativeDates accepts dates relative to the time of Clock in
+	// time.Time fields, such as "yesterday", "2 days ago", "in 3 weeks",
+	// or "last monday". Such dates are at midnight in the Clock's
+	// location, except for those given in seconds, minutes, or hours.
+	RelativeDates bool
+
+	// RowTimeout, if positive, is the longest that Decode waits for its
+	// FieldReader to return each record, as for a FieldReader that reads
+	// from a network, before it returns a TimeoutError. The Read goes on,
+	// and the next Decode waits for its record, rather than reading again.
+	RowTimeout time.Duration
+
+	// Validate, if not nil, is called with the Context, as returned by
+	// Context, and each value that Decode decodes without error, such
+	// as a pointer to a struct, to check it as a whole. An error it
+	// returns is returned by Decode as a ValidationError.
+	Validate func(ctx context.Context, v interface{}) error
+
+	// Clock is the time source for RateLimit and RelativeDates.
+	// If it is nil, SystemClock is used.
+	Clock Clock
+
+	// MaxErrors, if positive, is the number of rows with errors after
+	// which Decode gives up, returning a BudgetError. MaxErrorRate, if
+	// positive, is the greatest proportion of rows with errors before
+	// Decode gives up; it is checked once 100 rows have been read, and
+	// in place of returning io.EOF.
+	MaxErrors    int
+	MaxErrorRate float64
+
+	r        FieldReader
+	next     time.Time       // earliest time the next row may be decoded under RateLimit
+	budget   budget          // rows and errors counted for MaxErrors and MaxErrorRate
+	dropped  bool            // whether a field error was ignored in the current row
+	resolved *resolved       // Header, with its duplicates resolved
+	records  int             // number of records read from r, including the header
+	row      []string        // the record last read
+	pending  chan readResult // the result of a Read that outlasted RowTimeout or its Context
+	ctx      context.Context // the context of DecodeContext, if it is in progress
+
+	seen       map[[sha256.Size]byte]int // the first record of each distinct row, for DuplicateRows
+	duplicates int                       // number of duplicate rows found
+
+	clamped []OverflowError // the numbers clamped in the row being decoded
+
+	plans map[reflect.Type]*decoderPlan // the plans made so far, by struct type
 }
 
 // NewDecoder returns a Decoder that reads from r and has a default
-// Modify map that can set values for bool, int types, float types, and strings.
-func NewDecoder(r FieldReader) Decoder {
-	return Decoder{defaultMods, r}
+// Modify map that can set values for bool, int types, float types, complex
+// types, strings, and empty interfaces. An empty interface receives the
+// cell as an int64, float64, bool, time.Time, or string, whichever it
+// parses as first.
+// Fields of type time.Time are also decoded, from RFC 3339 timestamps,
+// dates like 2006-01-02, and slash-delimited dates in d.DateOrder.
+// Fields of type time.Duration are decoded by time.ParseDuration, as "5m30s".
+// Fields whose types, or pointers to them, implement encoding.TextUnmarshaler,
+// such as net.IP, are decoded by UnmarshalText instead of by d.Modify.
+// So are math/big's Int and Rat, while a big.Float is given the precision
+// to keep every digit of its cell. Fields of type net.IPNet and url.URL,
+// or pointers to them, are decoded by net.ParseCIDR and url.Parse, and
+// net.IP and the types of net/netip, such as netip.Addr, by UnmarshalText.
+// Those that implement Unmarshaler are decoded by UnmarshalField,
+// whatever else they implement or their tags say.
+// A type may be given a converter that every Decoder uses instead of
+// any of these with RegisterConverter.
+// A pointer field is decoded as what it points to, except that an empty
+// cell sets it to nil, so that a missing value can be told from a zero.
+// So is a field of one of database/sql's nullable types, such as
+// sql.NullString, sql.NullInt64, or sql.Null[T], which an empty cell
+// makes not Valid, so that the struct can be passed on to a database.
+// Fields of other types whose pointers implement sql.Scanner, and that
+// d.Modify has no function for, such as structs, are decoded by Scan,
+// which is passed the cell as a string.
+//
+// The Decoder's Modify map is its own, so that changing it does not
+// affect other Decoders. The opts are applied to the Decoder in order.
+func NewDecoder(r FieldReader, opts ...Option) *Decoder {
+	d := &Decoder{Modify: maps.Clone(defaultMods), r: r}
+	for _, opt := range opts {
+		opt(d)
+	}
+	return d
 }
 
 // Decode sets the exported fields of the struct s with the values
 // represented by the fields in the next row provided by d's FieldReader.
 // Fields are parsed and set using the functions in d.Modify.
 //
+// If s is a map[string]interface{} or a pointer to one, each column is
+// stored under its name in d.Header, decoded as the Kind given in d.Kinds.
+// A *Record is decoded likewise, keeping the columns in order.
+//
 // Any errors from Read are returned immediately.
-// If s is not a pointer to a struct, Decode returns nil and *s is not modified.
+// If s is not a pointer to a struct or a map, or is a nil pointer,
+// Decode returns an InvalidDecodeError and reads no row.
+// A field's `table` tag may bind it to a zero-based column index, as in
+// `table:"2"` or `table:"index=2"`; the fields after it are bound to the
+// columns that follow. If any field is bound by index, rows may have
+// columns that are not bound to any field. With d.UseHeader, only the
+// index= form binds by index; a bare number is the name of a column.
+// An exported field whose `table` tag is "-" is skipped, like an unexported
+// one. (A field with the tag "-," is bound to a column named "-".)
+// The fields of an untagged embedded struct, or pointer to a struct, are
+// bound to columns in its place, in order, and nil pointers are allocated.
+// So are those of a struct field, or pointer to a struct, tagged with a
+// prefix, as in `table:",prefix=addr_"`, whose columns are named with the
+// prefix before their own names: addr_street, addr_city, and so on.
+// The last field may be a slice tagged with the rest option, as in
+// `table:",rest"`, which takes the cells of the rest of the columns,
+// however many a row has; with d.UseHeader, it takes those of the columns
+// not bound to other fields. Its elements are decoded as fields are.
+// It may instead be a map with string keys, which keeps unknown columns
+// by their names in d.Header, or, without one, by their indexes.
+// (A csv.Reader must have a FieldsPerRecord of -1 to read such rows.)
+// A protocol buffer message generated by protoc-gen-go may be decoded
+// into directly: its fields are named by the names in its .proto file,
+// rather than their Go names, and the fields that protobuf uses itself,
+// along with oneofs and fields of other message types, are not bound.
+// The `table` tag of a time.Time field may select an ISO 8601 variant
+// with the format option: format=isoweek for week dates (2023-W05-1),
+// format=ordinal for ordinal dates (2023-032), or format=yearmonth (2023-02),
+// or give its layout, as for time.Parse, as in layout=2006-01-02. Commas
+// in a layout must be escaped with a backslash, as in layout=Jan 2\, 2006.
+// The tag of a slice field may split its cell into elements on a
+// separator, as in split=; or split=\, for a comma. Each piece is decoded
+// as an element, with the rest of the tag, and an empty cell sets the
+// slice to nil. The tag of a map field with string keys may do the same
+// for the pairs of a cell such as a=1;b=2, whose keys are separated from
+// their values by =, or by the kv option, as in split=;,kv=:.
+// The tag of a field of any type may have the json option, as in
+// `table:"payload,json"`, so that its cell is decoded by json.Unmarshal,
+// and an empty cell sets it to its zero value.
+// The tag of a field may name a Combiner, as in combine=datetime, to build
+// the field from several adjacent columns; see RegisterCombiner.
+// The tag of a numeric field may give a unit, as in unit=m, so that cells
+// such as "10km" or "5 cm" are converted to that unit; see RegisterUnits.
+// Sizes in bytes may be given in decimal or binary multiples, as in 3.5GB,
+// 3.5G, 3.5GiB, or 3.5Gi; with the binary option, as in unit=B,binary,
+// the decimal multiples are taken to be binary ones, as many tools mean them.
+// A time.Duration field may have a unit of time, as in unit=ms, which is
+// the unit of cells with none, such as "250"; "2s" is still two seconds.
+// Such a field is encoded as a number of its unit.
+// The tag of a time.Duration field may give the unit of cells that are
+// plain integers, as in bare=s for "90", which are otherwise errors.
+// The tag of a numeric field may give the locale whose conventions its
+// cells are written in, as in locale=de for "1.234,5"; see RegisterLocale.
+// d.Locale gives one for the numeric fields whose tags do not.
+// The tag of a numeric field may have the accounting option, so that
+// cells in parentheses, as in (1,234.56), are read as negatives.
+// The tag of a numeric field may have the currency option, so that a
+// currency symbol or code before or after its number, as in $5 or 5 EUR,
+// is removed. The option may name a string field of the same struct, as
+// in currency=Unit, which receives the currency, or "" if a cell has
+// none, in place of being bound to a column of its own.
+// The tag of a floating-point field may have the percent option, so that
+// a percentage, as in 12.5%, is decoded as a fraction, 0.125, or, with
+// percent=whole, which also applies to integers, as written, 12.5.
+// The tag of an integer field may give the base its cells are written
+// in, as in base=16 for "ff", or base=0 for prefixes, as in 0xff; see also
+// PrefixedInts.
+// The tag of a bool field may give a pair of tokens for true and false,
+// as in bool=yes/no, which are read, regardless of case, besides those
+// that strconv.ParseBool accepts; see also BoolTokens.
+// The tag of an interface{} field may have the text option, so that its
+// cells are decoded as strings rather than inferred; see TextColumns.
+//
 // A DecodeError is returned for the first field whose Kind has
-// no entry in d.Modify. A RowError is returned when the row has too many
-// or too few fields for s.
+// no entry in d.Modify, and a TagError for the first malformed tag.
+// A RowError is returned when the row has too many or too few fields
+// for s.
+//
+// Once d.MaxErrors or d.MaxErrorRate is exceeded, Decode returns a
+// BudgetError and reads no more rows.
+//
+// If d.OnBadRow is set, the rows that cannot be decoded are passed to it
+// with their errors, and Decode goes on to the next row. Only errors that
+// are not particular to a row are returned, such as a TagError, a
+// BudgetError, or an error from the FieldReader that reads no row.
+//
+// If d.Formatter is set, it provides the message of any RowError or
+// DecodeError that is returned. The original error can still be recovered
+// with errors.As.
 func (d *Decoder) Decode(s interface{}) error {
-	fields, err := d.r.Read()
+	for {
+		if d.budget.exceeded != nil {
+			return d.format(d.budget.exceeded)
+		}
+		d.throttle()
+		d.dropped = false
+		before := d.records
+		err := d.decode(s)
+		if _, timeout := err.(TimeoutError); timeout || d.canceled(err) {
+			return d.format(err)
+		}
+		if err == nil && d.Validate != nil {
+			if verr := d.Validate(d.Context(), s); verr != nil {
+				err = ValidationError{verr, d.records, d.line(0)}
+			}
+		}
+		if d.limited() {
+			err = d.spend(err)
+		}
+		if err != nil && err != io.EOF && d.OnBadRow != nil && d.records != before && !fatal(err) {
+			d.OnBadRow(d.row, d.format(err))
+			continue
+		}
+		return d.format(err)
+	}
+}
+
+// DecodeAll decodes every remaining row from d into a T, as with Decode,
+// and returns them when d's FieldReader returns io.EOF. If any other error
+// occurs, DecodeAll returns the rows decoded before it, and the error.
+func DecodeAll[T any](d *Decoder) ([]T, error) {
+	var all []T
+	for {
+		var t T
+		if err := d.Decode(&t); err == io.EOF {
+			return all, nil
+		} else if err != nil {
+			return all, err
+		}
+		all = append(all, t)
+	}
+}
+
+// DecodeEvery decodes every remaining row from d into a T, as with Decode,
+// but keeps going after rows that cannot be decoded, which are left out.
+// It returns the rows that were decoded and, if there were any errors,
+// an Errors of the error of each row that was not.
+// It stops early, with the error last in the Errors, if there is an error
+// that every row would have, such as a TagError, or a BudgetError, or if
+// d's FieldReader returns an error without reading a row.
+func DecodeEvery[T any](d *Decoder) ([]T, error) {
+	var all []T
+	var errs Errors
+	for {
+		var t T
+		before := d.records
+		err := d.Decode(&t)
+		if err == io.EOF {
+			break
+		}
+		if err == nil {
+			all = append(all, t)
+			continue
+		}
+		errs = append(errs, err)
+		if d.records == before || fatal(err) {
+			break
+		}
+	}
+	if len(errs) > 0 {
+		return all, errs
+	}
+	return all, nil
+}
+
+// fatal reports whether err, from Decode, is not particular to a row,
+// so that decoding the next row would be futile.
+func fatal(err error) bool {
+	switch ErrorCode(err) {
+	case CodeInvalidTag, CodeUnsupportedKind, CodeInvalidDest, CodeUnexported,
+		CodeDuplicateColumn, CodeMissingColumn, CodeErrorBudget:
+		return true
+	}
+	return false
+}
+
+// Rows returns an iterator over the remaining rows of d, each decoded into
+// a T, as with Decode:
+//
+//	for x, err := range table.Rows[X](dec) {
+//		if err != nil {
+//			return err
+//		}
+//		fmt.Println(x.A, x.B)
+//	}
+//
+// The iteration ends when d's FieldReader returns io.EOF, which is not
+// yielded, or after yielding any other error with the zero T.
+func Rows[T any](d *Decoder) iter.Seq2[T, error] {
+	return func(yield func(T, error) bool) {
+		for {
+			var t T
+			err := d.Decode(&t)
+			if err == io.EOF {
+				return
+			} else if err != nil {
+				var zero T
+				yield(zero, err)
+				return
+			}
+			if !yield(t, nil) {
+				return
+			}
+		}
+	}
+}
+
+// throttle sleeps until the next row may be decoded under d.RateLimit.
+func (d *Decoder) throttle() {
+	if d.RateLimit <= 0 {
+		return
+	}
+	c := d.clock()
+	now := c.Now()
+	if d.next.After(now) {
+		c.Sleep(d.next.Sub(now))
+	} else {
+		d.next = now
+	}
+	d.next = d.next.Add(time.Duration(float64(time.Second) / d.RateLimit))
+}
+
+func (d *Decoder) decode(s interface{}) error {
+	switch m := s.(type) {
+	case map[string]interface{}:
+		return d.decodeMap(m)
+	case *map[string]interface{}:
+		if *m == nil {
+			*m = map[string]interface{}{}
+		}
+		return d.decodeMap(*m)
+	case *Record:
+		return d.decodeRecord(m)
+	}
+
+	t := reflect.TypeOf(s)
+	if t == nil || t.Kind() != reflect.Ptr || t.Elem().Kind() != reflect.Struct || reflect.ValueOf(s).IsNil() {
+		return InvalidDecodeError{t}
+	}
+	t = t.Elem()
+
+	if d.UseHeader {
+		if err := d.readHeader(); err != nil {
+			return err
+		}
+	}
+
+	fields, empty, err := d.readRow()
 	if err != nil {
 		return err
 	}
+	return d.decodeRow(reflect.ValueOf(s).Elem(), fields, empty)
+}
 
-	t := reflect.TypeOf(s)
-	if t == nil || t.Kind() != reflect.Ptr || t.Elem().Kind() != reflect.Struct {
+// decodeRow decodes fields, the row just read, into val, a struct.
+// If empty is set, val is set to its zero value instead.
+func (d *Decoder) decodeRow(val reflect.Value, fields []string, empty bool) error {
+	plan, errs := d.plan(val.Type())
+	if len(errs) > 0 {
+		return d.locate(errs[0], fields)
+	}
+
+	if empty {
+		val.Set(reflect.Zero(val.Type()))
 		return nil
 	}
-	t = t.Elem()
 
-	val := reflect.ValueOf(s).Elem()
+	n := columns(plan)
+	if d.UseHeader {
+		n = len(d.Header)
+	}
+	fields, read := d.fill(fields, n)
+	if d.UseHeader {
+		if err := d.checkHeaderRow(fields); err != nil {
+			return err
+		}
+	}
 
-	j := 0 // j is the index of val's field i in the fields slice
-	for i := 0; i < t.NumField(); i++ {
-		f := t.Field(i)
-		fv := val.Field(i)
-		if !fv.CanSet() {
+	for _, f := range plan {
+		end := f.column + f.width
+		if end > len(fields) {
+			err := RowError{ len(fields), end, f.name, d.records, d.line(0) }
+			if !d.AllErrors {
+				return err
+			}
+			errs = append(errs, err)
+			break
+		}
+		fv := fieldOf(val, f.index)
+		col := f.column
+		var err error
+		switch {
+		case f.rest:
+			if col, err = d.decodeRest(&fv, plan, &f, fields); err != nil {
+				end = col + 1
+			}
+		case d.missing(f.column, read):
+			fv.Set(reflect.Zero(fv.Type()))
+		case f.combiner.Columns > 0:
+			cells := make([]string, f.width)
+			for i := range cells {
+				cells[i] = d.transform(f.column+i, f.name, fields[f.column+i])
+			}
+			err = f.combiner.Combine(&fv, cells)
+		case f.mod == nil:
+			fv.SetString(fields[f.column])
+		default:
+			cell := d.transform(f.column, f.name, fields[f.column])
+			if f.currency != nil {
+				_, code := splitCurrency(cell)
+				fieldOf(val, f.currency).SetString(code)
+			}
+			if d.isNull(cell) {
+				fv.Set(reflect.Zero(fv.Type()))
+				break
+			}
+			if f.fast != nil {
+				err = f.fast(val, cell)
+				break
+			}
+			err = f.mod(&fv, cell)
+		}
+		d.clampedField(f.name, col)
+		if err != nil && (d.Strict || d.AllErrors) {
+			fe := d.fieldError(f.name, col, strings.Join(fields[col:end], ","), f.sf.Type, err)
+			if !d.AllErrors {
+				return fe
+			}
+			errs = append(errs, fe)
 			continue
 		}
+		d.dropped = d.dropped || err != nil
+	}
+	d.reportClamped(fields)
 
-		if j >= len(fields) {
-			return RowError{ len(fields), j+1, t.Field(i).Name }
+	if n < len(fields) && !d.UseHeader && !sparse(plan) && !hasRest(plan) && !d.AllowLongRows {
+		err := RowError{ len(fields), n, "", d.records, d.line(0) }
+		if !d.AllErrors {
+			return err
+		}
+		errs = append(errs, err)
+	}
+
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// decodeRest sets v, the slice or map field f of plan, to the cells of
+// fields in the rest of the columns. If a cell cannot be decoded, it
+// returns its column with the error.
+func (d *Decoder) decodeRest(v *reflect.Value, plan []field, f *field, fields []string) (int, error) {
+	cols := restColumns(plan, f, len(fields))
+	var s reflect.Value
+	if v.Kind() == reflect.Map {
+		s = reflect.MakeMapWithSize(v.Type(), len(cols))
+	} else {
+		s = reflect.MakeSlice(v.Type(), len(cols), len(cols))
+	}
+	var first error
+	firstCol := f.column
+	for i, c := range cols {
+		var e reflect.Value
+		if v.Kind() == reflect.Map {
+			e = reflect.New(v.Type().Elem()).Elem()
+		} else {
+			e = s.Index(i)
 		}
+		cell := d.transform(c, f.name, fields[c])
+		if !d.isNull(cell) {
+			if err := f.mod(&e, cell); err != nil && first == nil {
+				first, firstCol = err, c
+			}
+		}
+		if v.Kind() == reflect.Map {
+			s.SetMapIndex(reflect.ValueOf(d.columnKey(c)).Convert(v.Type().Key()), e)
+		}
+	}
+	v.Set(s)
+	return firstCol, first
+}
+
+// columnKey returns the name of column c in d.Header, or, if it has none,
+// its index.
+func (d *Decoder) columnKey(c int) string {
+	if d.UseHeader && d.resolved != nil && c < len(d.resolved.names) && d.resolved.names[c] != "" {
+		return d.resolved.names[c]
+	}
+	return strconv.Itoa(c)
+}
+
+// readHeader sets d.Header to the next row, if it is not set already.
+func (d *Decoder) readHeader() error {
+	if d.Header != nil {
+		return nil
+	}
+	header, err := d.read()
+	if err != nil {
+		return err
+	}
+	d.Header = slices.Clone(header)
+	return nil
+}
 
-		m, ok := d.Modify[f.Type.Kind()]
-		if !ok {
-			return DecodeError(f.Type.Kind().String())
+// read reads the next record from d's FieldReader, counting it.
+// Records that come with an error, such as csv.ErrFieldCount, count too.
+// Bad lines are skipped if d.SkipBadLines is set.
+func (d *Decoder) read() ([]string, error) {
+	for {
+		fields, err := d.readTimed()
+		if _, timeout := err.(TimeoutError); timeout {
+			return nil, err
 		}
-		m(&fv, fields[j])
-		j++
+		if err == nil || fields != nil {
+			if d.CopyRows {
+				fields = copyRow(fields)
+			}
+			d.records++
+			d.row = fields
+		}
+		var pe *csv.ParseError
+		if d.SkipBadLines && errors.As(err, &pe) {
+			if d.OnBadRow != nil {
+				d.OnBadRow(fields, err)
+			}
+			continue
+		}
+		return fields, err
+	}
+}
+
+// copyRow returns a copy of row, whose cells share one new string.
+func copyRow(row []string) []string {
+	if row == nil {
+		return nil
 	}
+	n := 0
+	for _, f := range row {
+		n += len(f)
+	}
+	var b strings.Builder
+	b.Grow(n)
+	for _, f := range row {
+		b.WriteString(f)
+	}
+	s := b.String()
+	fields := make([]string, len(row))
+	for i, f := range row {
+		fields[i], s = s[:len(f)], s[len(f):]
+	}
+	return fields
+}
+
+// readResult is the result of a FieldReader's Read.
+type readResult struct {
+	fields []string
+	err    error
+}
+
+// readTimed returns the result of d's FieldReader's next Read, or of the
+// Read that timed out before, waiting at most d.RowTimeout, if it is positive.
+func (d *Decoder) readTimed() ([]string, error) {
+	done := d.Context().Done()
+	if d.RowTimeout <= 0 && d.pending == nil && done == nil {
+		return d.r.Read()
+	}
+	if d.pending == nil {
+		c := make(chan readResult, 1)
+		go func(r FieldReader) {
+			fields, err := r.Read()
+			c <- readResult{fields, err}
+		}(d.r)
+		d.pending = c
+	}
+	var timeout <-chan time.Time
+	if d.RowTimeout > 0 {
+		t := time.NewTimer(d.RowTimeout)
+		defer t.Stop()
+		timeout = t.C
+	}
+	select {
+	case r := <-d.pending:
+		d.pending = nil
+		return r.fields, r.err
+	case <-timeout:
+		return nil, TimeoutError{d.RowTimeout}
+	case <-done:
+		return nil, d.ctx.Err()
+	}
+}
 
-	if j < len(fields) {
-		return RowError{ len(fields), j, "" }
+// Record returns the number of records d has read from its FieldReader,
+// including any header. While a row is decoded, and in the errors
+// returned for it, it is the number of that row, counting from 1.
+func (d *Decoder) Record() int {
+	return d.records
+}
+
+// line returns the line on which field col of the record last read
+// begins, if d's FieldReader reports it with a FieldPos method, as
+// *csv.Reader does, or else 0.
+func (d *Decoder) line(col int) int {
+	p, ok := d.r.(interface{ FieldPos(field int) (line, column int) })
+	if !ok || len(d.row) == 0 {
+		return 0
+	}
+	if col >= len(d.row) {
+		col = len(d.row) - 1
+	}
+	line, _ := p.FieldPos(col)
+	return line
+}
+
+// checkHeaderRow returns a RowError if fields does not have a cell
+// for each column in d.Header.
+func (d *Decoder) checkHeaderRow(fields []string) error {
+	if len(fields) < len(d.Header) {
+		return RowError{ len(fields), len(d.Header), d.Header[len(fields)], d.records, d.line(0) }
+	}
+	if len(fields) > len(d.Header) && !d.AllowLongRows {
+		return RowError{ len(fields), len(d.Header), "", d.records, d.line(0) }
+	}
+	return nil
+}
+
+func (d *Decoder) decodeMap(m map[string]interface{}) error {
+	if err := d.readHeader(); err != nil {
+		return err
+	}
+
+	fields, empty, err := d.readRow()
+	if err != nil {
+		return err
+	}
+
+	r, err := d.resolve()
+	if err != nil {
+		return err
+	}
+
+	if empty {
+		return d.zeroMap(m, r.names)
+	}
+
+	fields, read := d.fill(fields, len(d.Header))
+	if err := d.checkHeaderRow(fields); err != nil {
+		return err
+	}
+
+	var errs Errors
+	for i, name := range r.names {
+		if name == "" {
+			continue
+		}
+		if d.missing(i, read) {
+			m[name] = nil
+			continue
+		}
+		t, mod, err := d.columnKind(name)
+		if de, ok := err.(DecodeError); ok {
+			de.Column = i
+			return d.locate(de, fields)
+		}
+		if err != nil {
+			return err
+		}
+		cell := d.transform(i, name, fields[i])
+		if d.isNull(cell) {
+			m[name] = nil
+			continue
+		}
+		v := reflect.New(t).Elem()
+		err = mod(&v, cell)
+		d.clampedField(name, i)
+		if err != nil {
+			fe := d.fieldError(name, i, fields[i], t, err)
+			switch {
+			case d.AllErrors:
+				errs = append(errs, fe)
+				continue
+			case d.Strict:
+				return fe
+			}
+			d.dropped = true
+		}
+		m[name] = v.Interface()
+	}
+	d.reportClamped(fields)
+	if len(errs) > 0 {
+		return errs
 	}
 
 	return nil
 }
 
+// ModifyField sets the function that decodes the named field of the
+// struct type of v, which may be a struct or a pointer to one, in place
+// of the function in d.Modify for its Kind:
+//
+//	d.ModifyField(X{}, "Price", parsePrice)
+//
+// It does not affect other Decoders. ModifyField panics if the type
+// has no such field.
+func (d *Decoder) ModifyField(v interface{}, field string, f func(*reflect.Value, string) error) {
+	t := reflect.TypeOf(v)
+	for t != nil && t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t == nil || t.Kind() != reflect.Struct {
+		panic("table: ModifyField of non-struct " + fmt.Sprint(t))
+	}
+	if _, ok := t.FieldByName(field); !ok {
+		panic("table: ModifyField of unknown field " + t.String() + "." + field)
+	}
+
+	mods := make(map[reflect.Type]map[string]func(*reflect.Value, string) error, len(d.ModifyFields)+1)
+	for t, fs := range d.ModifyFields {
+		mods[t] = fs
+	}
+	fs := make(map[string]func(*reflect.Value, string) error, len(mods[t])+1)
+	for name, m := range mods[t] {
+		fs[name] = m
+	}
+	fs[field] = f
+	mods[t] = fs
+	d.ModifyFields = mods
+	d.plans = nil
+}
+
+// isNull reports whether cell is one of d.Nulls.
+func (d *Decoder) isNull(cell string) bool {
+	for _, n := range d.Nulls {
+		if cell == n {
+			return true
+		}
+	}
+	return false
+}
+
+// textColumn reports whether the column name is one of d.TextColumns.
+func (d *Decoder) textColumn(name string) bool {
+	for _, c := range d.TextColumns {
+		if c == name {
+			return true
+		}
+	}
+	return false
+}
+
+// transform applies the Transformers for column col, bound to the
+// struct field or map key name, to cell.
+// A Migration's converter for the column is applied first.
+func (d *Decoder) transform(col int, name, cell string) string {
+	if len(d.Transform) == 0 && len(d.Migrations) == 0 {
+		return cell
+	}
+	if d.Header != nil {
+		if r, err := d.resolve(); err == nil && col < len(r.names) {
+			name = r.names[col]
+			if m := r.migration; m != nil && m.Convert[name] != nil {
+				cell = m.Convert[name](cell)
+			}
+		}
+	}
+	for _, t := range d.Transform[name] {
+		cell = t(cell)
+	}
+	return cell
+}
+
+// locate returns err with the position of the row fields, which d has
+// just read, and the cell of its column, if it is a DecodeError.
+func (d *Decoder) locate(err error, fields []string) error {
+	de, ok := err.(DecodeError)
+	if !ok {
+		return err
+	}
+	if de.Column >= 0 && de.Column < len(fields) {
+		de.Value = fields[de.Column]
+	}
+	de.Record, de.Line = d.records, d.line(max(de.Column, 0))
+	return de
+}
+
+// columnKind returns the type that the named column is decoded as
+// in a map, and the function from d.Modify that decodes it.
+func (d *Decoder) columnKind(name string) (reflect.Type, func(*reflect.Value, string) error, error) {
+	k, ok := d.Kinds[name]
+	if !ok {
+		k = reflect.String
+	}
+	_, hasType := kindTypes[k]
+	mod, ok := d.Modify[k]
+	if !hasType || !ok {
+		de := decodeError(k.String())
+		de.Field = name
+		return nil, nil, de
+	}
+	t := kindTypes[k]
+	if k == reflect.Interface && d.textColumn(name) {
+		mod = modInterfaceText
+	}
+	mod, err := d.localize(k, mod)
+	if err != nil {
+		return nil, nil, err
+	}
+	if d.Accounting && isNumber(k) {
+		mod = modAccounting(mod)
+	}
+	if k == reflect.Bool && len(d.BoolTokens) > 0 {
+		mod = modBool(d.BoolTokens, mod)
+	}
+	if d.PrefixedInts && isInteger(k) {
+		mod = modPrefixedInt(mod)
+	}
+	if d.NonFinite != NonFiniteAccept && isFloat(k) {
+		mod = modNonFinite(mod, d.NonFinite)
+	}
+	if d.Clamp && isNumber(k) {
+		mod = d.modClamp(mod)
+	}
+	return t, d.wrap(reflect.StructField{Name: name, Type: t}, mod), nil
+}
+
 func modInt(v *reflect.Value, f string, bitSize int) error {
-	n, err := strconv.ParseInt(f, 10, bitSize)
+	n, err := parseInt(f, bitSize)
 	v.SetInt(n)
 	return err
 }
 
 func modUint(v *reflect.Value, f string, bitSize int) error {
-	n, err := strconv.ParseUint(f, 10, bitSize)
+	n, err := parseUint(f, bitSize)
 	v.SetUint(n)
 	return err
 }
 
+// parseInt, parseUint, and parseFloat parse f as package strconv does,
+// but return 0 with any error, not the bound that strconv returns for
+// values out of range.
+
+func parseInt(f string, bitSize int) (int64, error) {
+	n, err := strconv.ParseInt(f, 10, bitSize)
+	if err != nil {
+		return 0, err
+	}
+	return n, nil
+}
+
+func parseUint(f string, bitSize int) (uint64, error) {
+	n, err := strconv.ParseUint(f, 10, bitSize)
+	if err != nil {
+		return 0, err
+	}
+	return n, nil
+}
+
+func parseFloat(f string, bitSize int) (float64, error) {
+	n, err := strconv.ParseFloat(f, bitSize)
+	if err != nil {
+		return 0, err
+	}
+	return n, nil
+}
+
 var defaultMods = map[reflect.Kind]func(*reflect.Value, string)error {
 	reflect.Bool: func(v *reflect.Value, f string) error {
 		b, err := strconv.ParseBool(f)
@@ -177,17 +1212,55 @@ var defaultMods = map[reflect.Kind]func(*reflect.Value, string)error {
 		return modUint(v, f, 64)
 	},
 	reflect.Float32: func(v *reflect.Value, f string) error {
-		n, err := strconv.ParseFloat(f, 32)
+		n, err := parseFloat(f, 32)
 		v.SetFloat(n)
 		return err
 	},
 	reflect.Float64: func(v *reflect.Value, f string) error {
-		n, err := strconv.ParseFloat(f, 64)
+		n, err := parseFloat(f, 64)
 		v.SetFloat(n)
 		return err
 	},
+	reflect.Complex64: func(v *reflect.Value, f string) error {
+		n, err := strconv.ParseComplex(f, 64)
+		if err != nil {
+			n = 0
+		}
+		v.SetComplex(n)
+		return err
+	},
+	reflect.Complex128: func(v *reflect.Value, f string) error {
+		n, err := strconv.ParseComplex(f, 128)
+		if err != nil {
+			n = 0
+		}
+		v.SetComplex(n)
+		return err
+	},
 	reflect.String: func(v *reflect.Value, f string) error {
 		v.SetString(f)
 		return nil
 	},
+	reflect.Interface: modInterface,
+}
+
+// kindTypes gives the type that a map value of each Kind is decoded as.
+var kindTypes = map[reflect.Kind]reflect.Type {
+	reflect.Bool: reflect.TypeOf(false),
+	reflect.Int: reflect.TypeOf(int(0)),
+	reflect.Int8: reflect.TypeOf(int8(0)),
+	reflect.Int16: reflect.TypeOf(int16(0)),
+	reflect.Int32: reflect.TypeOf(int32(0)),
+	reflect.Int64: reflect.TypeOf(int64(0)),
+	reflect.Uint: reflect.TypeOf(uint(0)),
+	reflect.Uint8: reflect.TypeOf(uint8(0)),
+	reflect.Uint16: reflect.TypeOf(uint16(0)),
+	reflect.Uint32: reflect.TypeOf(uint32(0)),
+	reflect.Uint64: reflect.TypeOf(uint64(0)),
+	reflect.Float32: reflect.TypeOf(float32(0)),
+	reflect.Float64: reflect.TypeOf(float64(0)),
+	reflect.Complex64: reflect.TypeOf(complex64(0)),
+	reflect.Complex128: reflect.TypeOf(complex128(0)),
+	reflect.String: reflect.TypeOf(""),
+	reflect.Interface: reflect.TypeOf((*interface{})(nil)).Elem(),
 }
@@ -0,0 +1,309 @@
+// © 2014 Steve McCoy.
+
+package tabletest
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"io"
+	"math"
+	"path"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"mccoy.space/g/table"
+)
+
+// A Generator writes a Go test that freezes what a Decoder decodes from
+// a sample file, so that changes in a feed's behavior show up as test
+// failures. The test is in the package of the decoded type, and needs
+// a function of that package to configure its Decoder as the sample's was.
+type Generator struct {
+	Package string // name of the package of the test
+	Name    string // name of the test function, such as "TestFeed"
+	Path    string // path of the sample file, as the test opens it, such as "testdata/feed.csv"
+
+	// Decoder is the name of a function that returns the Decoder,
+	// or a pointer to it, for the sample file:
+	//
+	//	func newFeedDecoder(r io.Reader) *table.Decoder
+	Decoder string
+
+	// Rows is the number of rows to freeze. If it is zero,
+	// every row is.
+	Rows int
+}
+
+// GenerateTest decodes rows of type T from dec, which reads the sample
+// file, and writes to w a test that decodes the same rows again and
+// expects the same values, or errors. As with table.DecodeEvery, it
+// stops after an error that every row would have, or that reads no row,
+// such as one from the FieldReader. It returns an error if a value
+// cannot be written as a Go literal, such as one of a type from another
+// package with unexported fields.
+func GenerateTest[T any](w io.Writer, dec *table.Decoder, g Generator) error {
+	typ := reflect.TypeOf((*T)(nil)).Elem()
+	lw := &literalWriter{pkg: typ.PkgPath(), imports: map[string]bool{}}
+	tname := lw.typeExpr(typ)
+
+	var rows bytes.Buffer
+	for i := 0; g.Rows == 0 || i < g.Rows; i++ {
+		var v T
+		before := dec.Record()
+		err := dec.Decode(&v)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			fmt.Fprintf(&rows, "{Err: %s},\n", strconv.Quote(err.Error()))
+			if dec.Record() == before || fatal(err) {
+				break
+			}
+			continue
+		}
+		lit, err := lw.literal(reflect.ValueOf(v), true)
+		if err != nil {
+			return fmt.Errorf("tabletest: row %d: %v", i, err)
+		}
+		fmt.Fprintf(&rows, "{Value: %s},\n", lit)
+	}
+
+	imports := []string{"os", "reflect", "testing"}
+	for p := range lw.imports {
+		imports = append(imports, p)
+	}
+	sort.Strings(imports)
+
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "// Code generated by tabletest.GenerateTest; DO NOT EDIT.\n\npackage %s\n\nimport (\n", g.Package)
+	for _, p := range imports {
+		fmt.Fprintf(&b, "%q\n", p)
+	}
+	fmt.Fprintf(&b, `)
+
+func %s(t *testing.T) {
+	f, err := os.Open(%q)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	dec := %s(f)
+
+	want := []struct {
+		Value %s
+		Err   string
+	}{
+%s	}
+	for i, w := range want {
+		var got %s
+		err := dec.Decode(&got)
+		switch {
+		case w.Err != "":
+			if err == nil || err.Error() != w.Err {
+				t.Errorf("row %%d: expected error %%q, got %%v", i, w.Err, err)
+			}
+		case err != nil:
+			t.Errorf("row %%d: %%v", i, err)
+		case !reflect.DeepEqual(got, w.Value):
+			t.Errorf("row %%d:\n got %%+v\nwant %%+v", i, got, w.Value)
+		}
+	}
+}
+`, g.Name, g.Path, g.Decoder, tname, rows.String(), tname)
+	if lw.ptr {
+		b.WriteString(`
+// ptr returns a pointer to v.
+func ptr[T any](v T) *T {
+	return &v
+}
+`)
+	}
+
+	src, err := format.Source(b.Bytes())
+	if err != nil {
+		return fmt.Errorf("tabletest: generated invalid Go: %v", err)
+	}
+	_, err = w.Write(src)
+	return err
+}
+
+// fatal reports whether err, from Decode, is not particular to a row,
+// so that decoding the next row would be futile, as for table.DecodeEvery.
+func fatal(err error) bool {
+	switch table.ErrorCode(err) {
+	case table.CodeInvalidTag, table.CodeUnsupportedKind, table.CodeInvalidDest, table.CodeUnexported,
+		table.CodeDuplicateColumn, table.CodeMissingColumn, table.CodeErrorBudget:
+		return true
+	}
+	return false
+}
+
+// A literalWriter writes values as Go literals, for a file in the
+// package whose path is pkg, and records the imports they need.
+type literalWriter struct {
+	pkg     string
+	imports map[string]bool
+	ptr     bool // whether the ptr function is needed
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// literal returns v as a Go expression. If typed is set, v's type is
+// known from the context, so constants need no conversion.
+func (lw *literalWriter) literal(v reflect.Value, typed bool) (string, error) {
+	t := v.Type()
+	if t == timeType {
+		return lw.timeLiteral(v.Interface().(time.Time)), nil
+	}
+
+	var lit string
+	switch t.Kind() {
+	case reflect.Bool:
+		lit = strconv.FormatBool(v.Bool())
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		lit = strconv.FormatInt(v.Int(), 10)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		lit = strconv.FormatUint(v.Uint(), 10)
+	case reflect.Float32, reflect.Float64:
+		lit = lw.floatLiteral(v.Float())
+	case reflect.String:
+		lit = strconv.Quote(v.String())
+
+	case reflect.Interface:
+		if v.IsNil() {
+			return "nil", nil
+		}
+		return lw.literal(v.Elem(), false)
+
+	case reflect.Ptr:
+		if v.IsNil() {
+			return "nil", nil
+		}
+		elem, err := lw.literal(v.Elem(), true)
+		if err != nil {
+			return "", err
+		}
+		lw.ptr = true
+		return "ptr[" + lw.typeExpr(t.Elem()) + "](" + elem + ")", nil
+
+	case reflect.Slice, reflect.Array:
+		if t.Kind() == reflect.Slice && v.IsNil() {
+			return "nil", nil
+		}
+		elems := make([]string, v.Len())
+		for i := range elems {
+			e, err := lw.literal(v.Index(i), true)
+			if err != nil {
+				return "", err
+			}
+			elems[i] = e
+		}
+		return lw.typeExpr(t) + "{" + strings.Join(elems, ", ") + "}", nil
+
+	case reflect.Map:
+		if v.IsNil() {
+			return "nil", nil
+		}
+		var elems []string
+		for _, k := range v.MapKeys() {
+			ks, err := lw.literal(k, true)
+			if err != nil {
+				return "", err
+			}
+			vs, err := lw.literal(v.MapIndex(k), true)
+			if err != nil {
+				return "", err
+			}
+			elems = append(elems, ks+": "+vs)
+		}
+		sort.Strings(elems)
+		return lw.typeExpr(t) + "{" + strings.Join(elems, ", ") + "}", nil
+
+	case reflect.Struct:
+		var elems []string
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			if !f.IsExported() && t.PkgPath() != lw.pkg {
+				return "", fmt.Errorf("cannot write a literal of %s, which has unexported fields", t)
+			}
+			if v.Field(i).IsZero() {
+				continue
+			}
+			fs, err := lw.literal(v.Field(i), true)
+			if err != nil {
+				return "", err
+			}
+			elems = append(elems, f.Name+": "+fs)
+		}
+		return lw.typeExpr(t) + "{" + strings.Join(elems, ", ") + "}", nil
+
+	default:
+		return "", fmt.Errorf("cannot write a literal of %s", t)
+	}
+
+	if typed || t.Kind() == reflect.String && t.Name() == "string" ||
+		t.Kind() == reflect.Bool && t.Name() == "bool" ||
+		t.Kind() == reflect.Int && t.Name() == "int" ||
+		t.Kind() == reflect.Float64 && t.Name() == "float64" && strings.ContainsAny(lit, ".e(") {
+		return lit, nil
+	}
+	return lw.typeExpr(t) + "(" + lit + ")", nil
+}
+
+// floatLiteral returns f as a Go expression.
+func (lw *literalWriter) floatLiteral(f float64) string {
+	switch {
+	case math.IsNaN(f):
+		lw.imports["math"] = true
+		return "math.NaN()"
+	case math.IsInf(f, 1):
+		lw.imports["math"] = true
+		return "math.Inf(1)"
+	case math.IsInf(f, -1):
+		lw.imports["math"] = true
+		return "math.Inf(-1)"
+	}
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
+
+// timeLiteral returns t as a call to time.Date.
+func (lw *literalWriter) timeLiteral(t time.Time) string {
+	lw.imports["time"] = true
+	loc := "time.UTC"
+	if t.Location() != time.UTC {
+		name, offset := t.Zone()
+		loc = fmt.Sprintf("time.FixedZone(%q, %d)", name, offset)
+	}
+	return fmt.Sprintf("time.Date(%d, %d, %d, %d, %d, %d, %d, %s)",
+		t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), loc)
+}
+
+// typeExpr returns the Go expression for t.
+func (lw *literalWriter) typeExpr(t reflect.Type) string {
+	if t.Name() != "" {
+		if t.PkgPath() == "" || t.PkgPath() == lw.pkg {
+			return t.Name()
+		}
+		lw.imports[t.PkgPath()] = true
+		return path.Base(t.PkgPath()) + "." + t.Name()
+	}
+	switch t.Kind() {
+	case reflect.Ptr:
+		return "*" + lw.typeExpr(t.Elem())
+	case reflect.Slice:
+		return "[]" + lw.typeExpr(t.Elem())
+	case reflect.Array:
+		return "[" + strconv.Itoa(t.Len()) + "]" + lw.typeExpr(t.Elem())
+	case reflect.Map:
+		return "map[" + lw.typeExpr(t.Key()) + "]" + lw.typeExpr(t.Elem())
+	case reflect.Interface:
+		if t.NumMethod() == 0 {
+			return "interface{}"
+		}
+	}
+	return t.String()
+}
@@ -0,0 +1,183 @@
+// © 2014 Steve McCoy.
+
+/*
+Package tabletest provides helpers for testing code that uses package table:
+scripted FieldReaders, a manual Clock, golden-row comparisons,
+round-trip checks for custom Modify functions, and generated tests that
+freeze what a Decoder makes of a sample file.
+
+For example, to check that a Decoder with custom Modify functions
+decodes a feed as expected:
+
+	dec := table.NewDecoder(tabletest.CSV(feed))
+	tabletest.DecodeGolden(t, dec, []X{
+		{A: 1, B: "blonde"},
+		{A: 2, B: "on"},
+	})
+*/
+package tabletest
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"mccoy.space/g/table"
+)
+
+// A Step is one result of a Reader's Read: a row or an error.
+type Step struct {
+	Fields []string
+	Err    error
+}
+
+// Row returns a Step that reads fields.
+func Row(fields ...string) Step {
+	return Step{Fields: fields}
+}
+
+// Err returns a Step that fails with err.
+func Err(err error) Step {
+	return Step{Err: err}
+}
+
+// A Reader is a table.FieldReader that returns a scripted sequence of
+// rows and errors, then io.EOF.
+type Reader struct {
+	steps []Step
+	n     int
+}
+
+// NewReader returns a Reader that follows steps.
+func NewReader(steps ...Step) *Reader {
+	return &Reader{steps: steps}
+}
+
+// Read returns the next Step's fields and error.
+func (r *Reader) Read() ([]string, error) {
+	if r.n >= len(r.steps) {
+		return nil, io.EOF
+	}
+	s := r.steps[r.n]
+	r.n++
+	return s.Fields, s.Err
+}
+
+// Reads returns the number of times Read has returned a Step.
+func (r *Reader) Reads() int {
+	return r.n
+}
+
+// A Clock is a table.Clock whose time changes only when it is told to:
+// Sleep advances it by the duration instead of waiting.
+type Clock struct {
+	now   time.Time
+	slept time.Duration
+}
+
+// NewClock returns a Clock that reads now.
+func NewClock(now time.Time) *Clock {
+	return &Clock{now: now}
+}
+
+// Now returns c's time.
+func (c *Clock) Now() time.Time {
+	return c.now
+}
+
+// Sleep advances c by d.
+func (c *Clock) Sleep(d time.Duration) {
+	c.slept += d
+	c.Advance(d)
+}
+
+// Advance moves c's time forward by d, without counting it as sleep.
+func (c *Clock) Advance(d time.Duration) {
+	c.now = c.now.Add(d)
+}
+
+// Slept returns the total duration passed to Sleep.
+func (c *Clock) Slept() time.Duration {
+	return c.slept
+}
+
+// CSV returns a FieldReader for the CSV text s, with leading and trailing
+// blank lines trimmed so that fixtures can be written as raw strings.
+func CSV(s string) table.FieldReader {
+	return csv.NewReader(strings.NewReader(strings.Trim(s, "\n")))
+}
+
+// DecodeGolden decodes len(want) rows from dec, into values of want's
+// element type, and reports each row that is not deeply equal to its
+// counterpart in want. want must be a slice of structs or of pointers
+// to structs. It also reports if dec has rows left over.
+func DecodeGolden(t testing.TB, dec *table.Decoder, want interface{}) {
+	t.Helper()
+	wv := reflect.ValueOf(want)
+	if wv.Kind() != reflect.Slice {
+		t.Fatalf("DecodeGolden: want is a %T, not a slice", want)
+	}
+	et := wv.Type().Elem()
+	for i := 0; i < wv.Len(); i++ {
+		got := reflect.New(et)
+		dst := got.Interface()
+		if et.Kind() == reflect.Ptr {
+			got.Elem().Set(reflect.New(et.Elem()))
+			dst = got.Elem().Interface()
+		}
+		if err := dec.Decode(dst); err != nil {
+			t.Errorf("row %d: %v", i, err)
+			continue
+		}
+		if w := wv.Index(i).Interface(); !reflect.DeepEqual(got.Elem().Interface(), w) {
+			t.Errorf("row %d:\n got %s\nwant %s", i, show(got.Elem()), show(wv.Index(i)))
+		}
+	}
+
+	extra := reflect.New(et)
+	dst := extra.Interface()
+	if et.Kind() == reflect.Ptr {
+		extra.Elem().Set(reflect.New(et.Elem()))
+		dst = extra.Elem().Interface()
+	}
+	if err := dec.Decode(dst); err != io.EOF {
+		t.Errorf("row %d: expected io.EOF, got %v", wv.Len(), err)
+	}
+}
+
+// show formats v with its field names.
+func show(v reflect.Value) string {
+	return fmt.Sprintf("%+v", v.Interface())
+}
+
+// RoundTrip checks that parse and format are inverses for values of type
+// typ: each cell is parsed into a value, the value is formatted, and the
+// result is parsed again, which must produce an equal value. parse is a
+// function as in the Modify map of a table.Decoder.
+func RoundTrip(t testing.TB, typ reflect.Type, parse func(*reflect.Value, string) error, format func(reflect.Value) (string, error), cells ...string) {
+	t.Helper()
+	for _, cell := range cells {
+		v1 := reflect.New(typ).Elem()
+		if err := parse(&v1, cell); err != nil {
+			t.Errorf("parse(%q): %v", cell, err)
+			continue
+		}
+		s, err := format(v1)
+		if err != nil {
+			t.Errorf("format(%v) from %q: %v", v1, cell, err)
+			continue
+		}
+		v2 := reflect.New(typ).Elem()
+		if err := parse(&v2, s); err != nil {
+			t.Errorf("parse(%q), formatted from %q: %v", s, cell, err)
+			continue
+		}
+		if !reflect.DeepEqual(v1.Interface(), v2.Interface()) {
+			t.Errorf("%q parsed as %v, formatted as %q, then parsed as %v", cell, v1, s, v2)
+		}
+	}
+}
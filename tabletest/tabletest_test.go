@@ -0,0 +1,180 @@
+// © 2014 Steve McCoy.
+
+package tabletest
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"mccoy.space/g/table"
+)
+
+// recorder is a testing.TB that records failures instead of reporting them.
+type recorder struct {
+	testing.TB
+	errs []string
+}
+
+func (r *recorder) Helper() {}
+
+func (r *recorder) Errorf(format string, args ...interface{}) {
+	r.errs = append(r.errs, fmt.Sprintf(format, args...))
+}
+
+func (r *recorder) Fatalf(format string, args ...interface{}) {
+	r.Errorf(format, args...)
+}
+
+type X struct {
+	A int
+	B string
+}
+
+func TestReader(t *testing.T) {
+	oops := errors.New("oops")
+	r := NewReader(Row("1", "blonde"), Err(oops), Row("2", "on"))
+	dec := table.NewDecoder(r)
+
+	var x X
+	if err := dec.Decode(&x); err != nil || x != (X{1, "blonde"}) {
+		t.Error("Unexpected first row:", x, err)
+	}
+	if err := dec.Decode(&x); err != oops {
+		t.Error("Expected oops, got", err)
+	}
+	if err := dec.Decode(&x); err != nil || x != (X{2, "on"}) {
+		t.Error("Unexpected third row:", x, err)
+	}
+	if err := dec.Decode(&x); err != io.EOF {
+		t.Error("Expected io.EOF, got", err)
+	}
+	if r.Reads() != 3 {
+		t.Error("Expected 3 reads, got", r.Reads())
+	}
+}
+
+func TestDecodeGolden(t *testing.T) {
+	feed := `
+1,blonde
+2,on
+`
+	dec := table.NewDecoder(CSV(feed))
+	DecodeGolden(t, dec, []X{{1, "blonde"}, {2, "on"}})
+
+	dec = table.NewDecoder(CSV(feed))
+	DecodeGolden(t, dec, []*X{{1, "blonde"}, {2, "on"}})
+
+	var r recorder
+	dec = table.NewDecoder(CSV(feed))
+	DecodeGolden(&r, dec, []X{{1, "blonde"}})
+	if len(r.errs) != 1 {
+		t.Error("Expected a complaint about the extra row, got", r.errs)
+	}
+
+	r = recorder{}
+	dec = table.NewDecoder(CSV(feed))
+	DecodeGolden(&r, dec, []X{{1, "blonde"}, {3, "on"}})
+	if len(r.errs) != 1 {
+		t.Error("Expected a complaint about the second row, got", r.errs)
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	parse := func(v *reflect.Value, s string) error {
+		n, err := strconv.ParseInt(s, 0, 64)
+		v.SetInt(n)
+		return err
+	}
+	hex := func(v reflect.Value) (string, error) {
+		return "0x" + strconv.FormatInt(v.Int(), 16), nil
+	}
+	RoundTrip(t, reflect.TypeOf(0), parse, hex, "0", "10", "0x1f", "0o17")
+
+	var r recorder
+	half := func(v reflect.Value) (string, error) {
+		return strconv.FormatInt(v.Int()/2, 10), nil
+	}
+	RoundTrip(&r, reflect.TypeOf(0), parse, half, "0", "10")
+	if len(r.errs) != 1 {
+		t.Error("Expected one failed round trip, got", r.errs)
+	}
+}
+
+func TestClock(t *testing.T) {
+	start := time.Date(2014, 1, 1, 0, 0, 0, 0, time.UTC)
+	c := NewClock(start)
+	dec := table.NewDecoder(CSV("1\n2\n3\n"), table.WithRateLimit(4), table.WithClock(c))
+	for i := 0; i < 3; i++ {
+		var x struct{ A int }
+		if err := dec.Decode(&x); err != nil {
+			t.Fatal("Expected no error, got", err)
+		}
+	}
+	if c.Slept() != 500*time.Millisecond {
+		t.Error("Expected to sleep 500ms, slept", c.Slept())
+	}
+
+	c.Advance(time.Hour)
+	if want := start.Add(time.Hour + 500*time.Millisecond); !c.Now().Equal(want) {
+		t.Error("Expected", want, "got", c.Now())
+	}
+}
+
+func TestGenerateTest(t *testing.T) {
+	type Y struct {
+		A int
+		B *float64
+		C interface{}
+		D time.Time
+		E table.Raw
+		F []string
+	}
+	dec := table.NewDecoder(CSV(`
+1,2.5,x,2014-03-01,r
+2,,7,2014-03-02T10:00:00Z,
+oops
+3,,,2014-03-03,
+`), table.WithModify(reflect.Slice, func(v *reflect.Value, s string) error {
+		v.Set(reflect.ValueOf([]string{s}))
+		return nil
+	}))
+	var b strings.Builder
+	g := Generator{Package: "feed", Name: "TestFeed", Path: "testdata/feed.csv", Decoder: "newDecoder", Rows: 3}
+	if err := GenerateTest[Y](&b, dec, g); err != nil {
+		t.Fatal("Expected no error, got", err)
+	}
+	for _, want := range []string{
+		"// Code generated by tabletest.GenerateTest; DO NOT EDIT.\n\npackage feed\n",
+		"\t\"time\"\n",
+		`f, err := os.Open("testdata/feed.csv")`,
+		"dec := newDecoder(f)",
+		`{Value: Y{A: 1, B: ptr[float64](2.5), C: "x", D: time.Date(2014, 3, 1, 0, 0, 0, 0, time.UTC), E: "2014-03-01", F: []string{"r"}}},`,
+		`{Value: Y{A: 2, C: int64(7), D: time.Date(2014, 3, 2, 10, 0, 0, 0, time.UTC), E: "2014-03-02T10:00:00Z", F: []string{""}}},`,
+		`{Err: "record on line 3: wrong number of fields"},`,
+		"func ptr[T any](v T) *T {",
+	} {
+		if !strings.Contains(b.String(), want) {
+			t.Errorf("Expected the test to contain %q, got\n%s", want, b.String())
+		}
+	}
+	if strings.Contains(b.String(), "Y{A: 3") {
+		t.Error("Expected only 3 rows, got\n", b.String())
+	}
+}
+
+func TestGenerateTestStops(t *testing.T) {
+	var b strings.Builder
+	g := Generator{Package: "feed", Name: "TestFeed", Path: "testdata/feed.csv", Decoder: "newDecoder"}
+	if err := GenerateTest[int](&b, table.NewDecoder(CSV("1\n2\n")), g); err != nil {
+		t.Fatal("Expected no error, got", err)
+	}
+	if n := strings.Count(b.String(), "{Err: "); n != 1 {
+		t.Errorf("Expected one error, got %d in\n%s", n, b.String())
+	}
+}
@@ -0,0 +1,394 @@
+// © 2014 Steve McCoy.
+
+/*
+Package tablevet defines an Analyzer that reports struct types that
+package table cannot decode.
+
+It inspects the destinations of (*table.Decoder).Decode and table.Check
+calls, and the type arguments of DecodeAll, DecodeEvery, DecodeParallel,
+Rows, DriverRows, and NewTypedDecoder, and reports fields with malformed
+`table` tags or whose types have no default converter, fields bound to
+columns already bound to other fields, and structs that bind some fields
+to columns by position and others by name, which only a header can
+bind, so that mistakes are caught when vetting rather than on the first
+row of data.
+Types that a program teaches its Decoders about through Modify are
+reported too, since that cannot be known statically; such reports can be
+suppressed by decoding through a variable of interface type.
+*/
+package tablevet
+
+import (
+	"go/ast"
+	"go/types"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"golang.org/x/tools/go/analysis"
+	"golang.org/x/tools/go/analysis/passes/inspect"
+	"golang.org/x/tools/go/ast/inspector"
+	"mccoy.space/g/table"
+)
+
+const tablePath = "mccoy.space/g/table"
+
+var Analyzer = &analysis.Analyzer{
+	Name:     "tablevet",
+	Doc:      "report struct types that package table cannot decode",
+	Requires: []*analysis.Analyzer{inspect.Analyzer},
+	Run:      run,
+}
+
+func run(pass *analysis.Pass) (interface{}, error) {
+	insp := pass.ResultOf[inspect.Analyzer].(*inspector.Inspector)
+
+	nodeFilter := []ast.Node{(*ast.CallExpr)(nil)}
+	insp.Preorder(nodeFilter, func(n ast.Node) {
+		call := n.(*ast.CallExpr)
+		arg, t, ok := destination(pass, call)
+		if !ok {
+			return
+		}
+		for {
+			p, ok := t.(*types.Pointer)
+			if !ok {
+				break
+			}
+			t = p.Elem()
+		}
+		if st, ok := t.Underlying().(*types.Struct); ok {
+			cols := &columns{bound: map[int]string{}}
+			checkStruct(pass, arg, t, st, cols)
+			cols.checkMixed(pass, arg, t)
+		}
+	})
+	return nil, nil
+}
+
+// generic names the generic functions of package table whose type
+// argument is a decoding destination.
+var generic = map[string]bool{
+	"DecodeAll":       true,
+	"DecodeEvery":     true,
+	"DecodeParallel":  true,
+	"Rows":            true,
+	"DriverRows":      true,
+	"NewTypedDecoder": true,
+}
+
+// destination returns the decoding destination of call, if it is to
+// (*table.Decoder).Decode or table.Check, whose first argument is one,
+// or to one of the generic functions, whose type argument is one.
+// Reports about the destination are positioned at the returned expression.
+func destination(pass *analysis.Pass, call *ast.CallExpr) (ast.Expr, types.Type, bool) {
+	fun := call.Fun
+	switch x := fun.(type) {
+	case *ast.IndexExpr:
+		fun = x.X
+	case *ast.IndexListExpr:
+		fun = x.X
+	}
+	var id *ast.Ident
+	switch fun := fun.(type) {
+	case *ast.Ident:
+		id = fun
+	case *ast.SelectorExpr:
+		id = fun.Sel
+	default:
+		return nil, nil, false
+	}
+	fn, ok := pass.TypesInfo.Uses[id].(*types.Func)
+	if !ok || fn.Pkg() == nil || fn.Pkg().Path() != tablePath {
+		return nil, nil, false
+	}
+	sig := fn.Type().(*types.Signature)
+	switch {
+	case sig.Recv() == nil && generic[fn.Name()]:
+		inst, ok := pass.TypesInfo.Instances[id]
+		if !ok || inst.TypeArgs.Len() == 0 {
+			return nil, nil, false
+		}
+		return call, inst.TypeArgs.At(0), true
+	case len(call.Args) == 0:
+		return nil, nil, false
+	case sig.Recv() == nil && fn.Name() == "Check",
+		sig.Recv() != nil && fn.Name() == "Decode" && isNamed(sig.Recv().Type(), "Decoder"):
+		return call.Args[0], pass.TypesInfo.TypeOf(call.Args[0]), true
+	}
+	return nil, nil, false
+}
+
+// columns is the state of the columns of a struct checked by checkStruct.
+type columns struct {
+	col        int            // the index of the next column, if known
+	unknown    bool           // whether col is unknown, after a Combiner's columns
+	bound      map[int]string // the field bound to each known column
+	named      string         // the first field bound by name, if any
+	positional string         // the first field bound by position, if any
+}
+
+// bind binds the field named name, whose tag is tag, to its columns,
+// and reports it if one of them is already bound to another field.
+// raw is set for a table.Raw field, which shares the column of the
+// field before it, and rest for a field bound to the rest of the columns.
+func (c *columns) bind(pass *analysis.Pass, dest ast.Expr, t types.Type, name string, tag table.Tag, raw, rest bool) {
+	idx, indexed := columnIndex(tag)
+	if indexed {
+		c.col, c.unknown = idx, false
+		if c.positional == "" {
+			c.positional = name
+		}
+	} else if tag.Name != "" && c.named == "" {
+		c.named = name
+	}
+	if raw && !indexed && (c.col > 0 || c.unknown) {
+		return // a twin
+	}
+	if _, ok := tag.Lookup("combine"); ok {
+		c.unknown = true // Combiners are registered at run time.
+		return
+	}
+	if rest || c.unknown {
+		return
+	}
+	col := c.col
+	c.col++
+	if raw {
+		return
+	}
+	if other, ok := c.bound[col]; ok {
+		pass.Reportf(dest.Pos(), "field %s of %s: column %d is already bound to field %s",
+			name, types.TypeString(t, types.RelativeTo(pass.Pkg)), col, other)
+		return
+	}
+	c.bound[col] = name
+}
+
+// checkMixed reports t if it binds some fields by position and others
+// by name: under a Decoder's UseHeader, the positions are kept, whatever
+// the order of the header's columns, and without it, the names are ignored.
+func (c *columns) checkMixed(pass *analysis.Pass, dest ast.Expr, t types.Type) {
+	if c.named != "" && c.positional != "" {
+		pass.Reportf(dest.Pos(), "%s binds field %s by position and field %s by name",
+			types.TypeString(t, types.RelativeTo(pass.Pkg)), c.positional, c.named)
+	}
+}
+
+// columnIndex returns the column index given by tag, as table does:
+// by an index option, by a name of the form "index=N", or by a name
+// that is a number.
+func columnIndex(tag table.Tag) (int, bool) {
+	s, ok := tag.Lookup("index")
+	if !ok {
+		s, ok = strings.CutPrefix(tag.Name, "index=")
+	}
+	if !ok {
+		if tag.Name == "" || strings.Trim(tag.Name, "0123456789") != "" {
+			return 0, false
+		}
+		s = tag.Name
+	}
+	i, err := strconv.Atoi(s)
+	return i, err == nil && i >= 0
+}
+
+// isNamed reports whether t, or what it points to, is the named type
+// from package table.
+func isNamed(t types.Type, name string) bool {
+	if p, ok := t.(*types.Pointer); ok {
+		t = p.Elem()
+	}
+	n, ok := t.(*types.Named)
+	return ok && n.Obj().Pkg() != nil && n.Obj().Pkg().Path() == tablePath && n.Obj().Name() == name
+}
+
+// checkStruct reports the fields of st, the underlying type of t,
+// that cannot be decoded. Reports are positioned at dest.
+// The fields of embedded structs that table flattens, and of struct
+// fields tagged with a prefix, are checked as fields of t. The fields
+// of generated protocol buffer messages that table skips are not checked.
+func checkStruct(pass *analysis.Pass, dest ast.Expr, t types.Type, st *types.Struct, cols *columns) {
+	proto := protoMessage(st)
+	for i := 0; i < st.NumFields(); i++ {
+		f := st.Field(i)
+		raw := reflect.StructTag(st.Tag(i)).Get("table")
+		if proto && protoInternal(f, reflect.StructTag(st.Tag(i))) {
+			continue
+		}
+		if est, ok := flattened(f, raw); ok {
+			checkStruct(pass, dest, t, est, cols)
+			continue
+		}
+		if !f.Exported() {
+			continue
+		}
+		if raw == "-" {
+			continue
+		}
+		tag, err := table.ParseTag(raw)
+		if err != nil {
+			pass.Reportf(dest.Pos(), "field %s of %s: %v",
+				f.Name(), types.TypeString(t, types.RelativeTo(pass.Pkg)), err)
+			continue
+		}
+		_, rest := tag.Lookup("rest")
+		if _, ok := tag.Lookup("prefix"); !ok || !isStruct(f.Type()) {
+			cols.bind(pass, dest, t, f.Name(), tag, isNamed(f.Type(), "Raw"), rest)
+		}
+		if _, ok := tag.Lookup("combine"); ok {
+			continue // Combiners are registered at run time.
+		}
+		if _, ok := tag.Lookup("json"); ok {
+			continue // Any type can be, as far as the analyzer can tell.
+		}
+		if _, ok := tag.Lookup("prefix"); ok {
+			if est, ok := nested(f.Type()); ok {
+				checkStruct(pass, dest, t, est, cols)
+				continue
+			}
+		}
+		ft := f.Type()
+		if rest {
+			switch u := ft.Underlying().(type) {
+			case *types.Slice:
+				ft = u.Elem() // The elements are decoded a cell at a time.
+			case *types.Map:
+				ft = u.Elem()
+			}
+		}
+		if _, ok := tag.Lookup("split"); ok {
+			switch u := ft.Underlying().(type) {
+			case *types.Slice:
+				ft = u.Elem() // The elements are decoded a piece of the cell at a time.
+			case *types.Map:
+				ft = u.Elem()
+			}
+		}
+		if !decodable(ft) {
+			pass.Reportf(dest.Pos(), "field %s of %s has type %s, which table cannot decode by default",
+				f.Name(), types.TypeString(t, types.RelativeTo(pass.Pkg)), types.TypeString(f.Type(), types.RelativeTo(pass.Pkg)))
+		}
+	}
+}
+
+// flattened returns the struct type of f, if f is an embedded struct,
+// or pointer to a struct, whose fields table binds in its place, as it
+// does unless f is tagged or is decoded as a whole.
+func flattened(f *types.Var, tag string) (*types.Struct, bool) {
+	if !f.Embedded() || tag != "" {
+		return nil, false
+	}
+	t := f.Type()
+	if p, ok := t.Underlying().(*types.Pointer); ok {
+		if !f.Exported() {
+			return nil, false
+		}
+		t = p.Elem()
+	}
+	st, ok := t.Underlying().(*types.Struct)
+	if !ok || decodable(t) {
+		return nil, false
+	}
+	return st, true
+}
+
+// protoMessage reports whether st looks like a protocol buffer message
+// generated by protoc-gen-go: whether any of its fields has a protobuf tag.
+func protoMessage(st *types.Struct) bool {
+	for i := 0; i < st.NumFields(); i++ {
+		if _, ok := reflect.StructTag(st.Tag(i)).Lookup("protobuf"); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// protoInternal reports whether f, a field of a generated message whose
+// tag is tag, is one that table skips: an XXX_ field, a oneof, or an
+// untagged field of another message type.
+func protoInternal(f *types.Var, tag reflect.StructTag) bool {
+	if strings.HasPrefix(f.Name(), "XXX_") || tag.Get("protobuf_oneof") != "" {
+		return true
+	}
+	return tag.Get("table") == "" && hasMethod(f.Type(), "ProtoMessage")
+}
+
+// isStruct reports whether t, or what it points to, is a struct that
+// table binds the fields of in place of a field tagged with a prefix.
+func isStruct(t types.Type) bool {
+	_, ok := nested(t)
+	return ok
+}
+
+// nested returns the struct type of t, or of what t points to, if
+// table binds its fields in place of a field of type t tagged with a
+// prefix option.
+func nested(t types.Type) (*types.Struct, bool) {
+	if p, ok := t.Underlying().(*types.Pointer); ok {
+		t = p.Elem()
+	}
+	st, ok := t.Underlying().(*types.Struct)
+	if !ok || decodable(t) {
+		return nil, false
+	}
+	return st, true
+}
+
+// decodable reports whether the default Modify map of a table.Decoder
+// has a function for values of type t, whether t is one that it decodes
+// itself, such as time.Time or url.URL, or whether t is decoded by its
+// UnmarshalText, UnmarshalField, UnmarshalFieldContext, or Scan method.
+// Pointers are decoded as what they point to, and the nullable types
+// of database/sql, such as sql.NullString, as their values.
+func decodable(t types.Type) bool {
+	if isType(t, "time", "Time") || isType(t, "net", "IPNet") || isType(t, "net/url", "URL") {
+		return true
+	}
+	if _, ok := t.Underlying().(*types.Interface); !ok && (hasMethod(t, "UnmarshalText") || hasMethod(t, "UnmarshalField") || hasMethod(t, "UnmarshalFieldContext")) {
+		return true
+	}
+	if v, ok := sqlNull(t); ok {
+		return decodable(v)
+	}
+	switch u := t.Underlying().(type) {
+	case *types.Pointer:
+		return decodable(u.Elem())
+	case *types.Basic:
+		return u.Info()&(types.IsBoolean|types.IsInteger|types.IsFloat|types.IsComplex|types.IsString) != 0 && u.Kind() != types.Uintptr ||
+			hasMethod(t, "Scan")
+	case *types.Interface:
+		return u.NumMethods() == 0
+	}
+	return hasMethod(t, "Scan")
+}
+
+// isType reports whether t is the named type from the package at path.
+func isType(t types.Type, path, name string) bool {
+	n, ok := t.(*types.Named)
+	return ok && n.Obj().Pkg() != nil && n.Obj().Pkg().Path() == path && n.Obj().Name() == name
+}
+
+// sqlNull returns the type of the value of t, if t is like the nullable
+// types of database/sql: a struct with a Scan method whose fields are its
+// value and a bool named Valid.
+func sqlNull(t types.Type) (types.Type, bool) {
+	st, ok := t.Underlying().(*types.Struct)
+	if !ok || st.NumFields() != 2 || !hasMethod(t, "Scan") {
+		return nil, false
+	}
+	valid := st.Field(1)
+	if b, ok := valid.Type().(*types.Basic); !ok || valid.Name() != "Valid" || b.Kind() != types.Bool {
+		return nil, false
+	}
+	return st.Field(0).Type(), true
+}
+
+// hasMethod reports whether t, or a pointer to it, has the named method,
+// such as UnmarshalText, for encoding.TextUnmarshaler, or UnmarshalField,
+// for table.Unmarshaler.
+func hasMethod(t types.Type, name string) bool {
+	obj, _, _ := types.LookupFieldOrMethod(t, true, nil, name)
+	_, ok := obj.(*types.Func)
+	return ok
+}
@@ -0,0 +1,14 @@
+// © 2014 Steve McCoy.
+
+package tablevet_test
+
+import (
+	"testing"
+
+	"golang.org/x/tools/go/analysis/analysistest"
+	"mccoy.space/g/table/tablevet"
+)
+
+func TestAnalyzer(t *testing.T) {
+	analysistest.Run(t, analysistest.TestData(), tablevet.Analyzer, "a")
+}
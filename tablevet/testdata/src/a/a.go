@@ -0,0 +1,131 @@
+package a
+
+import (
+	"database/sql"
+	"fmt"
+	"net"
+	"net/url"
+	"time"
+
+	"mccoy.space/g/table"
+)
+
+type Good struct {
+	A int
+	B string
+	C table.Raw
+	D interface{}
+	E time.Time
+	F [2]float64 `table:",combine=latlon"`
+	G chan int   `table:"-"`
+	H net.IP
+	I *level
+	J *int
+	K money
+	L sql.NullString
+	M *sql.NullTime
+	N point
+	O net.IPNet
+	P *url.URL
+	e complex64
+	Meta
+	*Extra
+	meta
+	Addr Extra     `table:",prefix=addr_"`
+	Tags []int     `table:",split=;"`
+	Rest []float64 `table:",rest"`
+}
+
+type Msg struct {
+	state         struct{}
+	unknownFields []byte
+
+	UserId  int64  `protobuf:"varint,1,opt,name=user_id,proto3"`
+	Manager *Msg   `protobuf:"bytes,3,opt,name=manager,proto3"`
+	Contact isMsg_ `protobuf_oneof:"contact"`
+
+	XXX_unrecognized []byte `json:"-"`
+}
+
+func (*Msg) ProtoMessage() {}
+
+type isMsg_ interface{ isMsg_() }
+
+type Meta struct {
+	ID   int
+	When time.Time
+}
+
+type Extra struct{ Notes string }
+
+type meta struct{ Source string }
+
+type level int
+
+func (l *level) UnmarshalText(b []byte) error { return nil }
+
+type money struct{ cents int64 }
+
+type point struct{ X, Y float64 }
+
+func (p *point) Scan(src interface{}) error { return nil }
+
+func (m *money) UnmarshalField(s string) error { return nil }
+
+type Bad struct {
+	A uintptr
+	B fmt.Stringer
+	C []int
+	E *uintptr
+	D int `table:"d,,"`
+	Nested
+	Loc  *Nested   `table:",prefix=loc_"`
+	Tail []uintptr `table:",rest"`
+}
+
+type Nested struct {
+	Z uintptr
+}
+
+type Dup struct {
+	A int
+	B int `table:"index=0"`
+	C int `table:",index=4"`
+	D int `table:",combine=latlon"`
+	E int
+	F int `table:",index=4"`
+	G table.Raw
+}
+
+type Mixed struct {
+	Name  string `table:"name"`
+	Count int    `table:",index=1"`
+}
+
+type Header struct {
+	Name  string `table:"name"`
+	Count int    `table:"count"`
+	Note  table.Raw
+}
+
+func f(dec *table.Decoder) {
+	var g Good
+	dec.Decode(&g)
+	table.Check(Good{})
+	table.Check(&Msg{})
+
+	var b Bad
+	dec.Decode(&b)      // want "field A of Bad has type uintptr" "field B of Bad has type fmt.Stringer" "field C of Bad has type \\[\\]int" "field E of Bad has type \\*uintptr" "field D of Bad: bad table tag" "field Z of Bad has type uintptr" "field Z of Bad has type uintptr" "field Tail of Bad has type \\[\\]uintptr"
+	table.Check(&Bad{}) // want "field A of Bad" "field B of Bad" "field C of Bad" "field E of Bad" "field D of Bad" "field Z of Bad" "field Z of Bad" "field Tail of Bad"
+
+	dec.Decode(&Dup{})   // want "field B of Dup: column 0 is already bound to field A" "field F of Dup: column 4 is already bound to field C"
+	dec.Decode(&Mixed{}) // want "Mixed binds field Count by position and field Name by name"
+	dec.Decode(&Header{})
+	table.DecodeAll[Bad](dec)       // want "field A of Bad" "field B of Bad" "field C of Bad" "field E of Bad" "field D of Bad" "field Z of Bad" "field Z of Bad" "field Tail of Bad"
+	table.Rows[Mixed](dec)          // want "Mixed binds field Count by position"
+	table.NewTypedDecoder[Dup](nil) // want "field B of Dup" "field F of Dup"
+	table.DecodeParallel[Good](dec, 2, false)
+
+	var i interface{} = &b
+	dec.Decode(i)
+}
@@ -0,0 +1,19 @@
+package table
+
+type Decoder struct{}
+
+func (d *Decoder) Decode(s interface{}) error { return nil }
+
+func Check(v interface{}) error { return nil }
+
+type Raw string
+
+func DecodeAll[T any](d *Decoder) ([]T, error) { return nil, nil }
+
+func DecodeParallel[T any](d *Decoder, workers int, ordered bool) ([]T, error) { return nil, nil }
+
+func Rows[T any](d *Decoder) func(yield func(T, error) bool) { return nil }
+
+type TypedDecoder[T any] struct{}
+
+func NewTypedDecoder[T any](r interface{}) (*TypedDecoder[T], error) { return nil, nil }
@@ -0,0 +1,197 @@
+// © 2014 Steve McCoy.
+
+package table
+
+import (
+	"reflect"
+	"strings"
+	"sync"
+)
+
+// A Tag is a parsed `table` struct tag. Its syntax is a column name,
+// which may be empty, followed by comma-separated options, each of which
+// is a flag or a key=value pair:
+//
+//	Created time.Time `table:"created,format=isoweek"`
+//	Tags    []string  `table:"tags,split=\\,"`
+//
+// A backslash escapes the character after it, so that names and values
+// may contain commas, equals signs, and backslashes. A single trailing
+// comma, as in "-,", is allowed and adds no options. Keys may not be
+// empty or repeated.
+type Tag struct {
+	Name    string
+	Options []TagOption
+}
+
+// A TagOption is one option of a Tag. Flags have no Value.
+type TagOption struct {
+	Key   string
+	Value string
+}
+
+// ParseTag parses the `table` tag s. Any error is a TagError.
+// ParseTag is the inverse of Tag.String.
+func ParseTag(s string) (Tag, error) {
+	parts := splitEscaped(s, ',')
+	if len(parts) == 2 && parts[1] == "" {
+		parts = parts[:1] // "name," has no options
+	}
+	t := Tag{Name: unescape(parts[0])}
+	for _, opt := range parts[1:] {
+		kv := splitEscaped(opt, '=')
+		k := unescape(kv[0])
+		v := unescape(strings.Join(kv[1:], "="))
+		if k == "" {
+			return Tag{}, TagError{"", s, "empty option"}
+		}
+		if _, ok := t.Lookup(k); ok {
+			return Tag{}, TagError{"", s, "repeated option " + k}
+		}
+		t.Options = append(t.Options, TagOption{k, v})
+	}
+	return t, nil
+}
+
+// Lookup returns the value of the option key, and whether t has it.
+func (t Tag) Lookup(key string) (string, bool) {
+	for _, o := range t.Options {
+		if o.Key == key {
+			return o.Value, true
+		}
+	}
+	return "", false
+}
+
+// without returns t without its option key.
+func (t Tag) without(key string) Tag {
+	rest := Tag{Name: t.Name}
+	for _, o := range t.Options {
+		if o.Key != key {
+			rest.Options = append(rest.Options, o)
+		}
+	}
+	return rest
+}
+
+// String returns t in the syntax read by ParseTag.
+func (t Tag) String() string {
+	var b strings.Builder
+	b.WriteString(escape(t.Name, ",\\"))
+	for _, o := range t.Options {
+		b.WriteByte(',')
+		b.WriteString(escape(o.Key, ",=\\"))
+		if o.Value != "" {
+			b.WriteByte('=')
+			b.WriteString(escape(o.Value, ",\\"))
+		}
+	}
+	return b.String()
+}
+
+// splitEscaped splits s around each instance of sep that is not
+// escaped by a backslash. Escapes are left in place.
+func splitEscaped(s string, sep byte) []string {
+	var parts []string
+	start := 0
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case sep:
+			parts = append(parts, s[start:i])
+			start = i + 1
+		}
+	}
+	return append(parts, s[start:])
+}
+
+func unescape(s string) string {
+	if strings.IndexByte(s, '\\') < 0 {
+		return s
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+1 < len(s) {
+			i++
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
+
+func escape(s, special string) string {
+	if !strings.ContainsAny(s, special) {
+		return s
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if strings.IndexByte(special, s[i]) >= 0 {
+			b.WriteByte('\\')
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
+
+var (
+	tagOptionsMu sync.RWMutex
+
+	// tagOptions are the options that a `table` tag may have.
+	tagOptions = map[string]bool{
+		"accounting": true,
+		"bare":       true,
+		"base":       true,
+		"binary":     true,
+		"bool":       true,
+		"combine":    true,
+		"currency":   true,
+		"format":     true,
+		"index":      true,
+		"json":       true,
+		"kv":         true,
+		"layout":     true,
+		"locale":     true,
+		"pad":        true,
+		"percent":    true,
+		"prefix":     true,
+		"rest":       true,
+		"round":      true,
+		"split":      true,
+		"text":       true,
+		"unit":       true,
+	}
+)
+
+// RegisterTagOption allows fields' `table` tags to have the option key,
+// for use by converters outside of this package, which can find it with
+// ParseTag. Decoders and Encoders otherwise report unknown options
+// as TagErrors. RegisterTagOption is safe to call concurrently.
+func RegisterTagOption(key string) {
+	tagOptionsMu.Lock()
+	defer tagOptionsMu.Unlock()
+	tagOptions[key] = true
+	forgetLayouts()
+}
+
+func knownTagOption(key string) bool {
+	tagOptionsMu.RLock()
+	defer tagOptionsMu.RUnlock()
+	return tagOptions[key]
+}
+
+// parseTag parses the `table` tag of f.
+func parseTag(f reflect.StructField) (Tag, error) {
+	t, err := ParseTag(f.Tag.Get("table"))
+	if err != nil {
+		te := err.(TagError)
+		te.Field = f.Name
+		return Tag{}, te
+	}
+	return t, nil
+}
+
+// tagError returns a TagError for f's `table` tag.
+func tagError(f reflect.StructField, reason string) TagError {
+	return TagError{f.Name, f.Tag.Get("table"), reason}
+}
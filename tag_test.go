@@ -0,0 +1,58 @@
+// © 2014 Steve McCoy.
+
+package table
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseTag(t *testing.T) {
+	tests := []struct {
+		s   string
+		tag Tag
+	}{
+		{"", Tag{}},
+		{"name", Tag{Name: "name"}},
+		{"-,", Tag{Name: "-"}},
+		{",format=isoweek", Tag{Options: []TagOption{{"format", "isoweek"}}}},
+		{"n,rest,unit=m", Tag{Name: "n", Options: []TagOption{{"rest", ""}, {"unit", "m"}}}},
+		{`tags,split=\,`, Tag{Name: "tags", Options: []TagOption{{"split", ","}}}},
+		{`a\,b,eq=x=y,bs=\\`, Tag{Name: "a,b", Options: []TagOption{{"eq", "x=y"}, {"bs", `\`}}}},
+	}
+	for _, test := range tests {
+		tag, err := ParseTag(test.s)
+		if err != nil {
+			t.Errorf("Expected no error for %q, got %v", test.s, err)
+			continue
+		}
+		if !reflect.DeepEqual(tag, test.tag) {
+			t.Errorf("Expected %q to parse as %+v, got %+v", test.s, test.tag, tag)
+		}
+		again, err := ParseTag(tag.String())
+		if err != nil || !reflect.DeepEqual(again, tag) {
+			t.Errorf("Expected %q (from %q) to parse as %+v, got %+v, %v", tag.String(), test.s, tag, again, err)
+		}
+	}
+
+	for _, bad := range []string{"a,,b", "a,=x", "a,x,x=y"} {
+		if _, err := ParseTag(bad); err == nil {
+			t.Errorf("Expected an error for %q", bad)
+		} else if _, ok := err.(TagError); !ok {
+			t.Errorf("Expected a TagError for %q, got %v", bad, err)
+		}
+	}
+}
+
+func TestRegisterTagOption(t *testing.T) {
+	type X struct {
+		A string `table:",shade=dark"`
+	}
+	if err := Check(X{}); err == nil {
+		t.Error("Expected an error for the unknown option")
+	}
+	RegisterTagOption("shade")
+	if err := Check(X{}); err != nil {
+		t.Error("Expected no error, got", err)
+	}
+}
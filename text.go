@@ -0,0 +1,66 @@
+// © 2014 Steve McCoy.
+
+package table
+
+import (
+	"encoding"
+	"reflect"
+)
+
+// Unmarshaler is implemented by types that decode themselves from the
+// text of a cell. Decode calls UnmarshalField for a field whose type, or
+// a pointer to it, is an Unmarshaler, in preference to any other way of
+// decoding it.
+type Unmarshaler interface {
+	UnmarshalField(s string) error
+}
+
+// Marshaler is implemented by types that encode themselves as the text
+// of a cell, for Encode. It is the inverse of Unmarshaler.
+type Marshaler interface {
+	MarshalField() (string, error)
+}
+
+var (
+	unmarshalerType     = reflect.TypeOf((*Unmarshaler)(nil)).Elem()
+	marshalerType       = reflect.TypeOf((*Marshaler)(nil)).Elem()
+	textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+	textMarshalerType   = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+)
+
+// modField sets v, whose pointer type is an Unmarshaler,
+// by calling its UnmarshalField with f.
+func modField(v *reflect.Value, f string) error {
+	return v.Addr().Interface().(Unmarshaler).UnmarshalField(f)
+}
+
+// formatField returns the text of v, whose type is a Marshaler.
+// A nil pointer has no text.
+func formatField(v reflect.Value) (string, error) {
+	if v.Kind() == reflect.Ptr && v.IsNil() {
+		return "", nil
+	}
+	return v.Interface().(Marshaler).MarshalField()
+}
+
+// unmarshalsText reports whether values of t, or pointers to them,
+// implement encoding.TextUnmarshaler.
+func unmarshalsText(t reflect.Type) bool {
+	return t.Implements(textUnmarshalerType) || reflect.PointerTo(t).Implements(textUnmarshalerType)
+}
+
+// modText sets v, whose pointer type is an encoding.TextUnmarshaler,
+// by calling its UnmarshalText with f.
+func modText(v *reflect.Value, f string) error {
+	return v.Addr().Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(f))
+}
+
+// formatText returns the text of v, whose type is an encoding.TextMarshaler.
+// A nil pointer has no text.
+func formatText(v reflect.Value) (string, error) {
+	if v.Kind() == reflect.Ptr && v.IsNil() {
+		return "", nil
+	}
+	b, err := v.Interface().(encoding.TextMarshaler).MarshalText()
+	return string(b), err
+}
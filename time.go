@@ -0,0 +1,224 @@
+// © 2014 Steve McCoy.
+
+package table
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// A DateOrder is the order of the day and month in slash-delimited dates,
+// such as 03/04/2021, which is the 4th of March in MDY order and the 3rd
+// of April in DMY order.
+type DateOrder int
+
+const (
+	UnknownOrder DateOrder = iota // slash-delimited dates are not accepted
+	MDY                           // month/day/year
+	DMY                           // day/month/year
+)
+
+// slashLayouts returns the time layouts for slash-delimited dates in order o.
+func (o DateOrder) slashLayouts() []string {
+	switch o {
+	case MDY:
+		return []string{"1/2/2006", "1/2/2006 15:04:05", "1/2/2006 15:04"}
+	case DMY:
+		return []string{"2/1/2006", "2/1/2006 15:04:05", "2/1/2006 15:04"}
+	}
+	return nil
+}
+
+func (o DateOrder) String() string {
+	switch o {
+	case MDY:
+		return "MDY"
+	case DMY:
+		return "DMY"
+	}
+	return "unknown"
+}
+
+// DetectDateOrder reports which DateOrder the slash-delimited dates in
+// sample are consistent with. It returns UnknownOrder if they are
+// consistent with both, as when no day is greater than 12, and an error
+// if they are consistent with neither.
+func DetectDateOrder(sample []string) (DateOrder, error) {
+	mdy, dmy := true, true
+	for _, s := range sample {
+		mdy = mdy && parsesAs(s, MDY.slashLayouts())
+		dmy = dmy && parsesAs(s, DMY.slashLayouts())
+	}
+	switch {
+	case mdy && dmy:
+		return UnknownOrder, nil
+	case mdy:
+		return MDY, nil
+	case dmy:
+		return DMY, nil
+	}
+	return UnknownOrder, errors.New("table: sample dates are consistent with neither MDY nor DMY order")
+}
+
+func parsesAs(s string, layouts []string) bool {
+	_, err := parseTime(strings.TrimSpace(s), layouts)
+	return err == nil
+}
+
+// timeFormats holds the functions for the values of the format tag
+// option, which selects an ISO 8601 variant for a time.Time field:
+//
+//	isoweek    week dates, such as 2023-W05-1 or 2023W051; the day defaults to Monday
+//	ordinal    ordinal dates, such as 2023-032
+//	yearmonth  a year and month, such as 2023-02
+var timeFormats = map[string]func(*reflect.Value, string) error{
+	"isoweek":   modTimeWith(parseISOWeek),
+	"ordinal":   modTimeWith(parseOrdinal),
+	"yearmonth": modTimeWith(func(f string) (time.Time, error) { return time.Parse("2006-01", f) }),
+}
+
+// timeFormatters holds the inverses of timeFormats, for encoding.
+var timeFormatters = map[string]func(reflect.Value) (string, error){
+	"isoweek": func(v reflect.Value) (string, error) {
+		t := v.Interface().(time.Time)
+		year, week := t.ISOWeek()
+		day := (int(t.Weekday())+6)%7 + 1
+		return fmt.Sprintf("%04d-W%02d-%d", year, week, day), nil
+	},
+	"ordinal": func(v reflect.Value) (string, error) {
+		t := v.Interface().(time.Time)
+		return fmt.Sprintf("%04d-%03d", t.Year(), t.YearDay()), nil
+	},
+	"yearmonth": func(v reflect.Value) (string, error) {
+		return v.Interface().(time.Time).Format("2006-01"), nil
+	},
+}
+
+func modTimeWith(parse func(string) (time.Time, error)) func(*reflect.Value, string) error {
+	return func(v *reflect.Value, f string) error {
+		t, err := parse(f)
+		if err != nil {
+			return err
+		}
+		v.Set(reflect.ValueOf(t))
+		return nil
+	}
+}
+
+// parseISOWeek parses an ISO 8601 week date, in its extended
+// (2023-W05-1) or basic (2023W051) form. The day may be omitted.
+func parseISOWeek(f string) (time.Time, error) {
+	s := strings.ReplaceAll(f, "-", "")
+	if len(s) != 7 && len(s) != 8 || s[4] != 'W' {
+		return time.Time{}, &time.ParseError{Value: f, Message: ": not an ISO week date"}
+	}
+	year, err1 := strconv.Atoi(s[:4])
+	week, err2 := strconv.Atoi(s[5:7])
+	day := 1
+	var err3 error
+	if len(s) == 8 {
+		day, err3 = strconv.Atoi(s[7:])
+	}
+	if err1 != nil || err2 != nil || err3 != nil || week < 1 || day < 1 || day > 7 {
+		return time.Time{}, &time.ParseError{Value: f, Message: ": not an ISO week date"}
+	}
+
+	// January 4th is always in week 1.
+	jan4 := time.Date(year, time.January, 4, 0, 0, 0, 0, time.UTC)
+	monday := jan4.AddDate(0, 0, -(int(jan4.Weekday())+6)%7)
+	t := monday.AddDate(0, 0, (week-1)*7+day-1)
+	if y, w := t.ISOWeek(); y != year || w != week {
+		return time.Time{}, &time.ParseError{Value: f, Message: ": week out of range"}
+	}
+	return t, nil
+}
+
+// parseOrdinal parses an ISO 8601 ordinal date, such as 2023-032.
+func parseOrdinal(f string) (time.Time, error) {
+	ystr, dstr, ok := strings.Cut(f, "-")
+	year, err1 := strconv.Atoi(ystr)
+	day, err2 := strconv.Atoi(dstr)
+	if !ok || len(ystr) != 4 || len(dstr) != 3 || err1 != nil || err2 != nil || day < 1 {
+		return time.Time{}, &time.ParseError{Value: f, Message: ": not an ordinal date"}
+	}
+	t := time.Date(year, time.January, day, 0, 0, 0, 0, time.UTC)
+	if t.Year() != year {
+		return time.Time{}, &time.ParseError{Value: f, Message: ": day out of range"}
+	}
+	return t, nil
+}
+
+// modTime sets a time.Time from f, which may be in any of inferLayouts,
+// in a slash-delimited layout if d.DateOrder is known, or relative to
+// the time of d's Clock if d.RelativeDates is set.
+func (d *Decoder) modTime(v *reflect.Value, f string) error {
+	t, err := parseTime(f, inferLayouts)
+	if err != nil && d.DateOrder != UnknownOrder {
+		t, err = parseTime(f, d.DateOrder.slashLayouts())
+	}
+	if err != nil && d.RelativeDates {
+		if rt, rerr := parseRelative(f, d.clock().Now()); rerr == nil {
+			t, err = rt, nil
+		}
+	}
+	if err != nil {
+		return err
+	}
+	v.Set(reflect.ValueOf(t))
+	return nil
+}
+
+// modTimeLayout sets a time.Time from f, which must be in d.TimeLayout,
+// or relative to the time of d's Clock if d.RelativeDates is set.
+func (d *Decoder) modTimeLayout(v *reflect.Value, f string) error {
+	t, err := time.Parse(d.TimeLayout, f)
+	if err != nil && d.RelativeDates {
+		if rt, rerr := parseRelative(f, d.clock().Now()); rerr == nil {
+			t, err = rt, nil
+		}
+	}
+	if err != nil {
+		return err
+	}
+	v.Set(reflect.ValueOf(t))
+	return nil
+}
+
+// timeLayout returns the layout given by the layout option of tg, the tag
+// of f, and whether it gives one. It is an error for a field that is not
+// a time.Time, or whose tag also has a format option.
+func timeLayout(f reflect.StructField, tg Tag) (string, bool, error) {
+	layout, ok := tg.Lookup("layout")
+	if !ok {
+		return "", false, nil
+	}
+	if f.Type != timeType {
+		return "", false, tagError(f, "layout applies only to time.Time")
+	}
+	if _, ok := tg.Lookup("format"); ok {
+		return "", false, tagError(f, "layout and format cannot both be given")
+	}
+	if layout == "" {
+		return "", false, tagError(f, "empty layout")
+	}
+	return layout, true, nil
+}
+
+// parseTime returns f parsed by the first of layouts that accepts it,
+// or the error from the last layout.
+func parseTime(f string, layouts []string) (time.Time, error) {
+	var err error
+	for _, layout := range layouts {
+		var t time.Time
+		if t, err = time.Parse(layout, f); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, err
+}
@@ -0,0 +1,234 @@
+// © 2014 Steve McCoy.
+
+package table
+
+import (
+	"encoding/csv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDecodeTime(t *testing.T) {
+	type X struct {
+		A time.Time
+		B time.Time
+		C time.Time
+	}
+	lines := `
+2014-03-01,2014-03-01T10:11:12Z,03/04/2021
+`
+	tests := []struct {
+		order DateOrder
+		c     time.Time
+	}{
+		{UnknownOrder, time.Time{}},
+		{MDY, time.Date(2021, 3, 4, 0, 0, 0, 0, time.UTC)},
+		{DMY, time.Date(2021, 4, 3, 0, 0, 0, 0, time.UTC)},
+	}
+	for _, test := range tests {
+		dec := NewDecoder(csv.NewReader(strings.NewReader(lines)), WithDateOrder(test.order))
+		var x X
+		if err := dec.Decode(&x); err != nil {
+			t.Fatal("Expected no error, got", err)
+		}
+		if !x.A.Equal(time.Date(2014, 3, 1, 0, 0, 0, 0, time.UTC)) {
+			t.Error("Unexpected A:", x.A)
+		}
+		if !x.B.Equal(time.Date(2014, 3, 1, 10, 11, 12, 0, time.UTC)) {
+			t.Error("Unexpected B:", x.B)
+		}
+		if !x.C.Equal(test.c) {
+			t.Errorf("Expected C to be %v in %v order, got %v", test.c, test.order, x.C)
+		}
+	}
+}
+
+func TestDetectDateOrder(t *testing.T) {
+	tests := []struct {
+		sample []string
+		order  DateOrder
+		err    bool
+	}{
+		{[]string{"03/04/2021", "12/11/2020"}, UnknownOrder, false},
+		{[]string{"03/04/2021", "12/31/2020"}, MDY, false},
+		{[]string{"03/04/2021", "31/12/2020"}, DMY, false},
+		{[]string{"31/12/2020", "12/31/2020"}, UnknownOrder, true},
+		{[]string{"2020-12-31"}, UnknownOrder, true},
+	}
+	for _, test := range tests {
+		o, err := DetectDateOrder(test.sample)
+		if o != test.order {
+			t.Errorf("Expected %v for %v, got %v", test.order, test.sample, o)
+		}
+		if (err != nil) != test.err {
+			t.Errorf("Unexpected error for %v: %v", test.sample, err)
+		}
+	}
+}
+
+func TestDecodeTimeFormats(t *testing.T) {
+	type X struct {
+		A time.Time `table:",format=isoweek"`
+		B time.Time `table:",format=isoweek"`
+		C time.Time `table:",format=ordinal"`
+		D time.Time `table:",format=yearmonth"`
+	}
+	lines := `
+2023-W05-1,2020W537,2024-366,2023-02
+`
+	dec := NewDecoder(csv.NewReader(strings.NewReader(lines)))
+	var x X
+	if err := dec.Decode(&x); err != nil {
+		t.Fatal("Expected no error, got", err)
+	}
+	if !x.A.Equal(time.Date(2023, 1, 30, 0, 0, 0, 0, time.UTC)) {
+		t.Error("Unexpected A:", x.A)
+	}
+	if !x.B.Equal(time.Date(2021, 1, 3, 0, 0, 0, 0, time.UTC)) {
+		t.Error("Unexpected B:", x.B)
+	}
+	if !x.C.Equal(time.Date(2024, 12, 31, 0, 0, 0, 0, time.UTC)) {
+		t.Error("Unexpected C:", x.C)
+	}
+	if !x.D.Equal(time.Date(2023, 2, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Error("Unexpected D:", x.D)
+	}
+
+	for _, bad := range []string{"2023-W54-1", "2021-W53", "2023-W05-8", "2023-05-1"} {
+		if _, err := parseISOWeek(bad); err == nil {
+			t.Error("Expected an error for", bad)
+		}
+	}
+	if _, err := parseOrdinal("2023-366"); err == nil {
+		t.Error("Expected an error for 2023-366")
+	}
+}
+
+func TestTimeFormatTagErrors(t *testing.T) {
+	type X struct {
+		A time.Time `table:",format=julian"`
+		B int       `table:",format=isoweek"`
+		C time.Time `table:",,"`
+		D time.Time `table:",colour=red"`
+	}
+	errs, ok := Check(X{}).(Errors)
+	if !ok || len(errs) != 4 {
+		t.Fatal("Expected 4 errors, got", errs)
+	}
+	for _, err := range errs {
+		if _, ok := err.(TagError); !ok {
+			t.Error("Expected a TagError, got", err)
+		}
+	}
+	if errs[len(errs)-2].Error() != `bad table tag ",format=julian" on field A: unknown time format julian` {
+		t.Error("Unexpected error:", errs[len(errs)-2])
+	}
+}
+
+func TestRelativeDates(t *testing.T) {
+	// A Wednesday.
+	now := time.Date(2014, 3, 5, 15, 4, 5, 0, time.UTC)
+	day := func(d int) time.Time {
+		return time.Date(2014, 3, d, 0, 0, 0, 0, time.UTC)
+	}
+	tests := []struct {
+		cell string
+		want time.Time
+	}{
+		{"now", now},
+		{"Today", day(5)},
+		{"yesterday", day(4)},
+		{"tomorrow", day(6)},
+		{"2 days ago", day(3)},
+		{"a week ago", time.Date(2014, 2, 26, 0, 0, 0, 0, time.UTC)},
+		{"in 3 days", day(8)},
+		{"an hour ago", now.Add(-time.Hour)},
+		{"in 10 minutes", now.Add(10 * time.Minute)},
+		{"last monday", day(3)},
+		{"last wednesday", time.Date(2014, 2, 26, 0, 0, 0, 0, time.UTC)},
+		{"next friday", day(7)},
+		{"next wednesday", day(12)},
+		{"last month", time.Date(2014, 2, 5, 0, 0, 0, 0, time.UTC)},
+		{"next year", time.Date(2015, 3, 5, 0, 0, 0, 0, time.UTC)},
+	}
+	for _, test := range tests {
+		dec := NewDecoder(csv.NewReader(strings.NewReader(test.cell+"\n")),
+			WithRelativeDates(), WithClock(&fakeClock{now: now}), WithStrict())
+		var x struct{ A time.Time }
+		if err := dec.Decode(&x); err != nil {
+			t.Errorf("Expected no error for %q, got %v", test.cell, err)
+			continue
+		}
+		if !x.A.Equal(test.want) {
+			t.Errorf("Expected %q to be %v, got %v", test.cell, test.want, x.A)
+		}
+	}
+
+	for _, cell := range []string{"someday", "last blursday", "2 fortnights ago", "in a"} {
+		dec := NewDecoder(csv.NewReader(strings.NewReader(cell+"\n")),
+			WithRelativeDates(), WithClock(&fakeClock{now: now}), WithStrict())
+		var x struct{ A time.Time }
+		if err := dec.Decode(&x); err == nil {
+			t.Errorf("Expected an error for %q, got %v", cell, x.A)
+		}
+	}
+
+	dec := NewDecoder(csv.NewReader(strings.NewReader("yesterday\n")), WithStrict())
+	var x struct{ A time.Time }
+	if err := dec.Decode(&x); err == nil {
+		t.Error("Expected an error without RelativeDates, got", x.A)
+	}
+}
+
+func TestTimeLayout(t *testing.T) {
+	type X struct {
+		A time.Time
+		B time.Time `table:"b,layout=Jan 2\\, 2006"`
+		C time.Time `table:",layout=02.01.2006 15:04"`
+	}
+	lines := `
+03/01/2014 10:11,"Mar 1, 2014",01.03.2014 10:11
+`
+	dec := NewDecoder(csv.NewReader(strings.NewReader(lines)), WithTimeLayout("01/02/2006 15:04"), WithStrict())
+	var x X
+	if err := dec.Decode(&x); err != nil {
+		t.Fatal("Expected no error, got", err)
+	}
+	want := time.Date(2014, 3, 1, 0, 0, 0, 0, time.UTC)
+	if !x.A.Equal(want.Add(10*time.Hour+11*time.Minute)) || !x.B.Equal(want) || !x.C.Equal(want.Add(10*time.Hour+11*time.Minute)) {
+		t.Error("Unexpected result:", x)
+	}
+
+	dec = NewDecoder(csv.NewReader(strings.NewReader("2014-03-01,\"Mar 1, 2014\",01.03.2014 10:11\n")), WithTimeLayout("01/02/2006 15:04"), WithStrict())
+	if err := dec.Decode(&x); err == nil {
+		t.Error("Expected an error for a cell not in TimeLayout, got nil")
+	}
+
+	var buf strings.Builder
+	w := csv.NewWriter(&buf)
+	enc := NewEncoder(w)
+	enc.TimeLayout = "2006/01/02"
+	if err := enc.Encode(X{want, want, want}); err != nil {
+		t.Fatal("Expected no error, got", err)
+	}
+	w.Flush()
+	if buf.String() != "2014/03/01,\"Mar 1, 2014\",01.03.2014 00:00\n" {
+		t.Errorf("Unexpected output: %q", buf.String())
+	}
+
+	type Bad struct {
+		A int       `table:",layout=2006"`
+		B time.Time `table:",layout=2006,format=isoweek"`
+	}
+	err := Check(Bad{})
+	errs, ok := err.(Errors)
+	if !ok || len(errs) != 2 {
+		t.Fatal("Expected two errors, got", err)
+	}
+	for _, err := range errs {
+		if _, ok := err.(TagError); !ok {
+			t.Error("Expected a TagError, got", err)
+		}
+	}
+}
@@ -0,0 +1,36 @@
+// © 2014 Steve McCoy.
+
+package table
+
+import (
+	"strings"
+)
+
+// A Transformer cleans up the text of a cell before it is decoded.
+type Transformer func(string) string
+
+// Some Transformers for common kinds of mess.
+var (
+	TrimSpace Transformer = strings.TrimSpace
+	ToUpper   Transformer = strings.ToUpper
+	ToLower   Transformer = strings.ToLower
+
+	// StripQuotes removes one pair of matching single or double
+	// quotes surrounding the cell, if it has them.
+	StripQuotes Transformer = stripQuotes
+)
+
+func stripQuotes(s string) string {
+	if len(s) >= 2 && (s[0] == '"' || s[0] == '\'') && s[len(s)-1] == s[0] {
+		return s[1 : len(s)-1]
+	}
+	return s
+}
+
+// Trim returns a Transformer that removes leading and trailing
+// characters contained in cutset.
+func Trim(cutset string) Transformer {
+	return func(s string) string {
+		return strings.Trim(s, cutset)
+	}
+}
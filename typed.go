@@ -0,0 +1,47 @@
+// © 2014 Steve McCoy.
+
+package table
+
+import (
+	"reflect"
+)
+
+// A TypedDecoder decodes rows into values of the struct type T, with a
+// Decoder whose plan for T is made, and checked, when the TypedDecoder is.
+type TypedDecoder[T any] struct {
+	d *Decoder
+}
+
+// NewTypedDecoder returns a TypedDecoder that reads from r, with a
+// Decoder configured by opts, as by NewDecoder. If T is not a struct
+// type, or has fields that the Decoder cannot decode, it returns their
+// errors, as Check does, instead of returning them from the first Decode.
+// If the Decoder has UseHeader set, fields are bound to columns when the
+// header is read, unless it is given by WithHeader.
+func NewTypedDecoder[T any](r FieldReader, opts ...Option) (*TypedDecoder[T], error) {
+	d := NewDecoder(r, opts...)
+	t := reflect.TypeOf((*T)(nil)).Elem()
+	if t.Kind() != reflect.Struct {
+		return nil, InvalidDecodeError{reflect.PointerTo(t)}
+	}
+	if _, errs := d.plan(t); len(errs) > 0 {
+		return nil, errs
+	}
+	return &TypedDecoder[T]{d}, nil
+}
+
+// Decode decodes the next row into a T, as the Decoder's Decode does.
+// At the end of the stream, it returns io.EOF.
+func (td *TypedDecoder[T]) Decode() (T, error) {
+	var t T
+	if err := td.d.Decode(&t); err != nil {
+		var zero T
+		return zero, err
+	}
+	return t, nil
+}
+
+// Decoder returns td's Decoder, for such things as its Record.
+func (td *TypedDecoder[T]) Decoder() *Decoder {
+	return td.d
+}
@@ -0,0 +1,243 @@
+// © 2014 Steve McCoy.
+
+package table
+
+import (
+	"errors"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+// A UnitTable gives the size of each of a family of units, such as
+// lengths or durations, in terms of a common base unit of the family.
+// Keys are unit symbols, which are case-sensitive.
+type UnitTable map[string]float64
+
+var (
+	unitsMu sync.RWMutex
+	units   = []UnitTable{
+		{
+			"m":  1,
+			"km": 1e3,
+			"cm": 1e-2,
+			"mm": 1e-3,
+			"mi": 1609.344,
+			"yd": 0.9144,
+			"ft": 0.3048,
+			"in": 0.0254,
+		},
+		{
+			"B":   1,
+			"kB":  1e3,
+			"KB":  1e3,
+			"MB":  1e6,
+			"GB":  1e9,
+			"TB":  1e12,
+			"PB":  1e15,
+			"KiB": 1 << 10,
+			"MiB": 1 << 20,
+			"GiB": 1 << 30,
+			"TiB": 1 << 40,
+			"PiB": 1 << 50,
+			"K":   1e3,
+			"M":   1e6,
+			"G":   1e9,
+			"T":   1e12,
+			"P":   1e15,
+			"Ki":  1 << 10,
+			"Mi":  1 << 20,
+			"Gi":  1 << 30,
+			"Ti":  1 << 40,
+			"Pi":  1 << 50,
+		},
+		{
+			"ns":  1e-9,
+			"us":  1e-6,
+			"µs":  1e-6,
+			"ms":  1e-3,
+			"s":   1,
+			"min": 60,
+			"h":   3600,
+			"d":   86400,
+		},
+		{
+			"g":  1,
+			"mg": 1e-3,
+			"kg": 1e3,
+			"t":  1e6,
+			"lb": 453.59237,
+			"oz": 28.349523125,
+		},
+	}
+)
+
+// RegisterUnits makes the units in u available to the unit tag option.
+// Units in u take precedence over those of the same symbol
+// already registered. RegisterUnits is safe to call concurrently.
+func RegisterUnits(u UnitTable) {
+	unitsMu.Lock()
+	defer unitsMu.Unlock()
+	units = append([]UnitTable{u}, units...)
+	forgetLayouts()
+}
+
+// binarySymbols maps the decimal multiples of bytes to the binary
+// multiples that they mean in the tags of fields with the binary option.
+var binarySymbols = map[string]string{
+	"kB": "KiB", "KB": "KiB", "K": "KiB",
+	"MB": "MiB", "M": "MiB",
+	"GB": "GiB", "G": "GiB",
+	"TB": "TiB", "T": "TiB",
+	"PB": "PiB", "P": "PiB",
+}
+
+// unitTable returns the registered UnitTable that contains symbol.
+func unitTable(symbol string) (UnitTable, bool) {
+	unitsMu.RLock()
+	defer unitsMu.RUnlock()
+	for _, u := range units {
+		if _, ok := u[symbol]; ok {
+			return u, true
+		}
+	}
+	return nil, false
+}
+
+// unitModifier returns the function that decodes f, a numeric field whose
+// `table` tag has the option unit=base. Cells are numbers followed by an
+// optional unit from the same UnitTable as base, and are converted to base.
+// A number with no unit is taken to be in base already, and integers are
+// rounded by r. If binary is set, base must be a unit of bytes, and the decimal
+// multiples of bytes, such as KB or M, are taken to be binary ones, such
+// as KiB or Mi, as they are by many tools that report sizes.
+// A time.Duration field is set to the cell's nanoseconds, so that
+// base is only the unit of cells with no unit.
+func unitModifier(f reflect.StructField, base string, binary bool, r Rounding) (func(*reflect.Value, string) error, error) {
+	switch f.Type.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+	default:
+		return nil, tagError(f, "unit applies only to numbers")
+	}
+	table, ok := unitTable(base)
+	if !ok {
+		return nil, tagError(f, "unknown unit "+base)
+	}
+	if _, ok := table["KiB"]; binary && !ok {
+		return nil, tagError(f, "binary applies only to units of bytes")
+	}
+	var nanos map[string]float64
+	if f.Type == durationType {
+		var err error
+		if nanos, err = durationNanos(f, table, base); err != nil {
+			return nil, err
+		}
+	}
+	return func(v *reflect.Value, s string) error {
+		num, sym := splitUnit(s)
+		n, err := strconv.ParseFloat(num, 64)
+		if err != nil {
+			return err
+		}
+		if nanos != nil {
+			if sym == "" {
+				sym = base
+			}
+			size, ok := nanos[sym]
+			if !ok {
+				return &strconv.NumError{Func: "unit", Num: s, Err: strconv.ErrSyntax}
+			}
+			n *= size
+		} else if sym != "" {
+			if b, ok := binarySymbols[sym]; ok && binary {
+				sym = b
+			}
+			size, ok := table[sym]
+			if !ok {
+				return &strconv.NumError{Func: "unit", Num: s, Err: strconv.ErrSyntax}
+			}
+			n *= size / table[base]
+		}
+		return setNumber(v, n, s, r)
+	}, nil
+}
+
+// ErrFraction is the error, wrapped in a *strconv.NumError, for a value
+// with a fractional part that would be lost in an integer field of a
+// Decoder with ExactInts.
+var ErrFraction = errors.New("value has a fractional part")
+
+// isInteger reports whether k is a signed or unsigned integer Kind.
+func isInteger(k reflect.Kind) bool {
+	switch k {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return true
+	}
+	return false
+}
+
+// modCoerceInt returns a function that sets an integer with mod or,
+// if mod cannot parse the cell, with the cell parsed as a float and
+// rounded by r, as by setNumber.
+func modCoerceInt(mod func(*reflect.Value, string) error, r Rounding) func(*reflect.Value, string) error {
+	return func(v *reflect.Value, s string) error {
+		err := mod(v, s)
+		if err == nil {
+			return nil
+		}
+		n, ferr := strconv.ParseFloat(s, 64)
+		if ferr != nil || math.IsNaN(n) || math.IsInf(n, 0) {
+			return err
+		}
+		return setNumber(v, n, s, r)
+	}
+}
+
+// splitUnit splits s into a number and the unit symbol that follows it,
+// which is everything after the number's last digit.
+func splitUnit(s string) (num, sym string) {
+	s = strings.TrimSpace(s)
+	i := strings.LastIndexFunc(s, func(r rune) bool {
+		return unicode.IsDigit(r) || r == '.' || unicode.IsSpace(r)
+	}) + 1
+	return strings.TrimSpace(s[:i]), s[i:]
+}
+
+// setNumber sets the numeric value v to n, rounding it to an integer by
+// r for integer kinds, or returning an ErrFraction error for s instead if
+// r is RoundExact. It returns a range error for s if n does not fit in v.
+func setNumber(v *reflect.Value, n float64, s string, r Rounding) error {
+	switch v.Kind() {
+	case reflect.Float32, reflect.Float64:
+		if v.OverflowFloat(n) {
+			return &strconv.NumError{Func: "ParseFloat", Num: s, Err: strconv.ErrRange}
+		}
+		v.SetFloat(n)
+		return nil
+	}
+	n, ok := r.round(n)
+	if !ok {
+		return &strconv.NumError{Func: "ParseInt", Num: s, Err: ErrFraction}
+	}
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i := int64(n)
+		if math.Abs(n) >= 1<<63 || v.OverflowInt(i) {
+			return &strconv.NumError{Func: "ParseInt", Num: s, Err: strconv.ErrRange}
+		}
+		v.SetInt(i)
+	default:
+		u := uint64(n)
+		if n < 0 || n >= 1<<64 || v.OverflowUint(u) {
+			return &strconv.NumError{Func: "ParseUint", Num: s, Err: strconv.ErrRange}
+		}
+		v.SetUint(u)
+	}
+	return nil
+}
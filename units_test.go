@@ -0,0 +1,250 @@
+// © 2014 Steve McCoy.
+
+package table
+
+import (
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDecodeUnits(t *testing.T) {
+	type X struct {
+		A float64 `table:",unit=m"`
+		B int64   `table:",unit=B"`
+		C int     `table:",unit=ms"`
+		D float32 `table:",unit=s"`
+		E uint8   `table:",unit=furlong"`
+	}
+	RegisterUnits(UnitTable{"furlong": 201.168, "chain": 20.1168})
+	lines := `
+10km,5 MiB,1.5s,250ms,30chain
+`
+	dec := NewDecoder(csv.NewReader(strings.NewReader(lines)))
+	var x X
+	if err := dec.Decode(&x); err != nil {
+		t.Fatal("Expected no error, got", err)
+	}
+	if x.A != 10000 {
+		t.Error("Expected A to be 10000, got", x.A)
+	}
+	if x.B != 5<<20 {
+		t.Error("Expected B to be 5MiB, got", x.B)
+	}
+	if x.C != 1500 {
+		t.Error("Expected C to be 1500, got", x.C)
+	}
+	if x.D != 0.25 {
+		t.Error("Expected D to be 0.25, got", x.D)
+	}
+	if x.E != 3 {
+		t.Error("Expected E to be 3, got", x.E)
+	}
+
+	type Y struct {
+		A float64 `table:",unit=league"`
+	}
+	dec = NewDecoder(csv.NewReader(strings.NewReader("2league\n2league\n")))
+	var y Y
+	dec.Decode(&y) // plans Y before league is registered
+	RegisterUnits(UnitTable{"league": 4828.032})
+	if err := dec.Decode(&y); err != nil || y.A != 2 {
+		t.Error("Expected 2 once league was registered, got", y.A, err)
+	}
+}
+
+func TestDurationUnits(t *testing.T) {
+	type X struct {
+		A time.Duration `table:",unit=ms"`
+		B time.Duration `table:",unit=s"`
+		C time.Duration `table:",unit=min"`
+	}
+	dec := NewDecoder(csv.NewReader(strings.NewReader("250ms,2s,1.5\n250,1500ms,2h\n")), WithStrict())
+	want := []X{
+		{250 * time.Millisecond, 2 * time.Second, 90 * time.Second},
+		{250 * time.Millisecond, 1500 * time.Millisecond, 2 * time.Hour},
+	}
+	xs, err := DecodeAll[X](dec)
+	if err != nil || len(xs) != 2 || xs[0] != want[0] || xs[1] != want[1] {
+		t.Fatal("Expected", want, "got", xs, err)
+	}
+
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	enc := NewEncoder(w)
+	for _, x := range xs {
+		if err := enc.Encode(x); err != nil {
+			t.Fatal("Expected no error, got", err)
+		}
+	}
+	w.Flush()
+	if buf.String() != "250,2,1.5\n250,1.5,120\n" {
+		t.Errorf("Unexpected output: %q", buf.String())
+	}
+
+	type Bad struct {
+		D time.Duration `table:",unit=km"`
+	}
+	if err := Check(Bad{}); err == nil {
+		t.Error("Expected an error for a unit of length on a time.Duration")
+	}
+	enc = NewEncoder(csv.NewWriter(&buf))
+	if err := enc.Encode(Bad{}); ErrorCode(err) != CodeInvalidTag {
+		t.Error("Expected a TagError from encoding, got", err)
+	}
+}
+
+func TestSplitUnit(t *testing.T) {
+	tests := []struct{ s, num, sym string }{
+		{"10km", "10", "km"},
+		{" 5 MiB ", "5", "MiB"},
+		{"1.5e3m", "1.5e3", "m"},
+		{"250µs", "250", "µs"},
+		{"42", "42", ""},
+	}
+	for _, test := range tests {
+		if num, sym := splitUnit(test.s); num != test.num || sym != test.sym {
+			t.Errorf("Expected %q to split into %q, %q; got %q, %q", test.s, test.num, test.sym, num, sym)
+		}
+	}
+}
+
+func TestUnitTagErrors(t *testing.T) {
+	type X struct {
+		A string  `table:",unit=m"`
+		B float64 `table:",unit=parsec"`
+	}
+	errs, ok := Check(X{}).(Errors)
+	if !ok || len(errs) != 2 {
+		t.Fatal("Expected 2 errors, got", errs)
+	}
+}
+
+func TestCoerceAndExactInts(t *testing.T) {
+	type X struct {
+		A int
+		B uint8
+		C int64 `table:",unit=m"`
+	}
+	tests := []struct {
+		line  string
+		opts  []Option
+		want  X
+		field string // the field with an ErrFraction, if any
+	}{
+		{"3.0,1e2,1.5km", []Option{WithCoerceInts()}, X{3, 100, 1500}, ""},
+		{"3.7,2.5,1.5km", []Option{WithCoerceInts()}, X{4, 3, 1500}, ""},
+		{"3,2,2.5m", nil, X{3, 2, 3}, ""},
+		{"3.7,2,1km", []Option{WithCoerceInts(), WithExactInts()}, X{}, "A"},
+		{"3,2,2.5m", []Option{WithExactInts()}, X{3, 2, 0}, "C"},
+		{"3.0,2,1.5km", []Option{WithCoerceInts(), WithExactInts()}, X{3, 2, 1500}, ""},
+	}
+	for _, test := range tests {
+		opts := append([]Option{WithStrict()}, test.opts...)
+		dec := NewDecoder(csv.NewReader(strings.NewReader(test.line+"\n")), opts...)
+		var x X
+		err := dec.Decode(&x)
+		var fe FieldError
+		switch {
+		case test.field == "" && err != nil:
+			t.Errorf("Expected no error for %q, got %v", test.line, err)
+		case test.field != "" && (!errors.As(err, &fe) || fe.Field != test.field || !errors.Is(err, ErrFraction)):
+			t.Errorf("Expected an ErrFraction for %s in %q, got %v", test.field, test.line, err)
+		case x != test.want:
+			t.Errorf("Expected %v for %q, got %v", test.want, test.line, x)
+		}
+	}
+
+	dec := NewDecoder(csv.NewReader(strings.NewReader("3.7,2,1\n")), WithStrict())
+	var x X
+	if err := dec.Decode(&x); !errors.Is(err, strconv.ErrSyntax) {
+		t.Error("Expected a syntax error without CoerceInts, got", err)
+	}
+}
+
+func TestDecodeByteSizes(t *testing.T) {
+	type X struct {
+		A int64         `table:",unit=B"`
+		B int64         `table:",unit=B,binary"`
+		C float64       `table:",unit=KiB,binary"`
+		D uint64        `table:",unit=B"`
+		E time.Duration `table:",unit=s"`
+	}
+	lines := "10KB,10KB,3.5G,512Mi,250ms\n3.5GiB,2M,1024,1.5 G,1.5\n"
+	dec := NewDecoder(csv.NewReader(strings.NewReader(lines)))
+	var x X
+	if err := dec.Decode(&x); err != nil {
+		t.Fatal("Expected no error, got", err)
+	}
+	if x.A != 10000 || x.B != 10<<10 || x.C != 3.5*(1<<20) || x.D != 512<<20 || x.E != 250*time.Millisecond {
+		t.Errorf("Unexpected result: %+v", x)
+	}
+	if err := dec.Decode(&x); err != nil || x.A != 3.5*(1<<30) || x.B != 2<<20 || x.C != 1024 || x.D != 1.5e9 || x.E != 1500*time.Millisecond {
+		t.Errorf("Unexpected result: %+v, %v", x, err)
+	}
+
+	type Bad struct {
+		A float64 `table:",unit=m,binary"`
+		B int     `table:",binary"`
+	}
+	errs, ok := Check(Bad{}).(Errors)
+	if !ok || len(errs) != 2 {
+		t.Fatal("Expected 2 errors, got", errs)
+	}
+}
+
+func TestRounding(t *testing.T) {
+	type X struct {
+		A int
+		B uint8 `table:",round=truncate"`
+		C int64 `table:",unit=m"`
+		D *int  `table:",round=exact"`
+	}
+	tests := []struct {
+		line string
+		opts []Option
+		want X
+	}{
+		{"2.5,2.7,2.5m,3.0", []Option{WithCoerceInts()}, X{3, 2, 3, nil}},
+		{"2.5,2.7,3.5m,3.0", []Option{WithCoerceInts(), WithRounding(RoundHalfEven)}, X{2, 2, 4, nil}},
+		{"-2.5,0.9,-2.5m,3", []Option{WithCoerceInts(), WithRounding(RoundTruncate)}, X{-2, 0, -2, nil}},
+		{"-2.5,2.7,2.1m,3", []Option{WithCoerceInts(), WithRounding(RoundFloor)}, X{-3, 2, 2, nil}},
+		{"-2.5,2.7,2.1m,3", []Option{WithCoerceInts(), WithRounding(RoundCeil)}, X{-2, 2, 3, nil}},
+		{"2,2.7,2.1m,3", nil, X{2, 2, 2, nil}},
+	}
+	for _, test := range tests {
+		opts := append([]Option{WithStrict()}, test.opts...)
+		dec := NewDecoder(csv.NewReader(strings.NewReader(test.line+"\n")), opts...)
+		var x X
+		if err := dec.Decode(&x); err != nil {
+			t.Errorf("Expected no error for %q, got %v", test.line, err)
+			continue
+		}
+		if x.D == nil || *x.D != 3 {
+			t.Errorf("Expected D to be 3 for %q, got %v", test.line, x.D)
+		}
+		x.D = nil
+		if x != test.want {
+			t.Errorf("Expected %v for %q, got %v", test.want, test.line, x)
+		}
+	}
+
+	dec := NewDecoder(csv.NewReader(strings.NewReader("2,2,2m,3.5\n")), WithStrict())
+	var x X
+	var fe FieldError
+	if err := dec.Decode(&x); !errors.As(err, &fe) || fe.Field != "D" || !errors.Is(err, ErrFraction) {
+		t.Error("Expected an ErrFraction for D, got", err)
+	}
+
+	type Bad struct {
+		A float64 `table:",round=floor"`
+		B int     `table:",round=up"`
+	}
+	if errs, ok := Check(Bad{}).(Errors); !ok || len(errs) != 2 {
+		t.Error("Expected 2 errors, got", errs)
+	}
+}
@@ -0,0 +1,164 @@
+// © 2014 Steve McCoy.
+
+package table
+
+import (
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// A viewColumn is a column selected by Encoder.View.
+type viewColumn struct {
+	source string // the field, map key, or Record column it is written from
+	name   string // the name it is written under by WriteHeader
+}
+
+// View returns an Encoder like e that writes only the given columns,
+// in the given order, regardless of the order of a struct's fields or
+// of e.Columns. Each column is the name of a field, as it would be bound
+// by a Decoder with UseHeader, or a map key or Record column. A column of
+// the form "source=name" is written from source but named name by
+// WriteHeader, so that
+//
+//	enc.View("Total", "Name=Customer")
+//
+// writes a struct's Total field and then its Name field, under the
+// header "Total,Customer". A field with a Combiner contributes all of
+// its columns. Map keys and Record columns that are not in the view
+// are not written, and those missing from a value are written empty.
+// Encoding a struct that has no field for a column returns a
+// MissingColumnError.
+func (e *Encoder) View(columns ...string) Encoder {
+	v := *e
+	v.view = make([]viewColumn, len(columns))
+	for i, c := range columns {
+		source, name, ok := strings.Cut(c, "=")
+		if !ok {
+			name = source
+		}
+		v.view[i] = viewColumn{source, name}
+	}
+	return v
+}
+
+// WriteHeader writes a row of column names: the names of e's view, if e
+// is from View, or else e.Columns.
+func (e *Encoder) WriteHeader() error {
+	if e.view == nil {
+		return e.w.Write(e.Columns)
+	}
+	names := make([]string, len(e.view))
+	for i, c := range e.view {
+		names[i] = c.name
+	}
+	return e.w.Write(names)
+}
+
+// writeHeaderOf writes the header for rows like v: that of WriteHeader,
+// with e.Columns set by v if it is a map, or, if e has neither a view nor
+// Columns and v is a struct, or pointer to one, the names of its columns.
+func (e *Encoder) writeHeaderOf(v interface{}) error {
+	switch v.(type) {
+	case map[string]string, map[string]interface{}:
+		e.setColumns(reflect.ValueOf(v))
+	}
+	t := reflect.TypeOf(v)
+	for t != nil && t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if e.view != nil || e.Columns != nil || t == nil || t.Kind() != reflect.Struct {
+		return e.WriteHeader()
+	}
+	return e.w.Write(headerOf(t))
+}
+
+// headerOf returns the names of the columns of the struct type t, as
+// bound by a Decoder with UseHeader. The columns of a field with a
+// Combiner after the first are named as by DuplicateSuffix, as name_2,
+// name_3, and so on. Columns bound to no field are unnamed, and a field
+// bound to the rest of the columns names none of them.
+func headerOf(t reflect.Type) []string {
+	plan, _ := cachedLayout(t, false)
+	var names []string
+	for _, f := range plan {
+		if f.twin || f.rest {
+			continue
+		}
+		for len(names) < f.column+f.width {
+			names = append(names, "")
+		}
+		name := f.columnName()
+		names[f.column] = name
+		for i := 1; i < f.width; i++ {
+			names[f.column+i] = name + "_" + strconv.Itoa(i+1)
+		}
+	}
+	return names
+}
+
+// encodeView writes the values of e's view, as returned by get.
+func (e *Encoder) encodeView(get func(column string) (interface{}, bool)) error {
+	row := make([]string, len(e.view))
+	types := make([]CellType, len(row))
+	for i, c := range e.view {
+		x, ok := get(c.source)
+		if !ok {
+			continue
+		}
+		cell, err := e.formatAny(x)
+		if err != nil {
+			return err
+		}
+		row[i] = cell
+		types[i] = cellType(reflect.ValueOf(x), Tag{})
+	}
+	return e.write(row, types)
+}
+
+// mapGetter returns a function that looks up columns in m,
+// a map with string keys.
+func mapGetter(m reflect.Value) func(string) (interface{}, bool) {
+	return func(column string) (interface{}, bool) {
+		v := m.MapIndex(reflect.ValueOf(column))
+		if !v.IsValid() {
+			return nil, false
+		}
+		return v.Interface(), true
+	}
+}
+
+// project returns the cells of row, encoded according to plan,
+// that belong to the columns of view.
+func project[T any](view []viewColumn, plan []field, row []T) ([]T, error) {
+	var out []T
+	for _, c := range view {
+		f := viewField(plan, c.source)
+		if f == nil {
+			return nil, MissingColumnError{c.source, c.source}
+		}
+		end := f.column + f.width
+		if f.rest {
+			end = len(row)
+		}
+		out = append(out, row[f.column:end]...)
+	}
+	return out, nil
+}
+
+// viewField returns the field of plan named by column, or nil.
+func viewField(plan []field, column string) *field {
+	for i := range plan {
+		f := &plan[i]
+		if !f.twin && f.columnName() == column {
+			return f
+		}
+	}
+	for i := range plan {
+		f := &plan[i]
+		if !f.twin && strings.EqualFold(f.name, column) && f.tag.Name == "" {
+			return f
+		}
+	}
+	return nil
+}